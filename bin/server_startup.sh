@@ -9,8 +9,9 @@
 #   - 后台运行服务器（使用 nohup）
 #   - PID 文件管理（server.pid）
 #   - 日志重定向到 server.log
-# 注意：如果使用 systemd 管理，systemd 会处理进程生命周期，
-#       本脚本的后台运行逻辑更适合手动直接运行。
+#   - 停止旧进程时等待其优雅关闭（STOP_TIMEOUT 秒，默认 35，需大于配置中的 drain_timeout）
+# 注意：由 systemd 启动时（环境变量 INVOCATION_ID 非空），脚本完成配置准备后
+#       通过 exec 在前台运行服务器，不再后台运行，也不写 PID 文件和 server.log。
 # =================================================================
 
 # 1. 确定项目路径
@@ -35,6 +36,10 @@ echo "项目根目录: $PROJECT_ROOT"
 PID_FILE="$PROJECT_ROOT/server.pid"
 LOG_FILE="$PROJECT_ROOT/server.log"
 
+# 停止旧进程时的最长等待时间（秒）
+# 服务器收到 SIGTERM 后会等待正在执行的命令结束（drain_timeout，默认 30 秒）并通知其他节点
+STOP_TIMEOUT=${STOP_TIMEOUT:-35}
+
 # 2. 停止旧进程（如果存在）
 # 检查 PID 文件是否存在
 if [ -f "$PID_FILE" ]; then
@@ -45,8 +50,8 @@ if [ -f "$PID_FILE" ]; then
             echo "发现正在运行的服务器进程 (PID: $OLD_PID)，正在停止..."
             # 尝试优雅停止（发送 SIGTERM）
             kill "$OLD_PID" 2>/dev/null
-            # 等待最多 5 秒
-            for i in {1..5}; do
+            # 等待最多 STOP_TIMEOUT 秒
+            for ((i = 0; i < STOP_TIMEOUT; i++)); do
                 if ! ps -p "$OLD_PID" > /dev/null 2>&1; then
                     echo "服务器进程已停止"
                     break
@@ -149,9 +154,15 @@ fi
 
 echo "正在启动 Shell Executor MCP Server..."
 
+# 由 systemd 启动时（systemd 会设置 INVOCATION_ID），使用 exec 让服务器进程替换本脚本，
+# 成为服务的主进程：停止服务时 SIGTERM 直接发给服务器，由其等待正在执行的命令结束，
+# 日志输出到 systemd journal
+if [ -n "$INVOCATION_ID" ]; then
+    echo "检测到由 systemd 启动，服务器将在前台运行"
+    exec "$SERVER_BIN" run --config "$CONFIG_FILE"
+fi
+
 # 使用 nohup 后台运行服务器，重定向输出到日志文件
-# 注意：如果使用 systemd 管理，systemd 会处理进程生命周期和日志，
-#       本脚本的后台运行逻辑更适合手动直接运行。
 nohup "$SERVER_BIN" run \
     --config "$CONFIG_FILE" \
     >> "$LOG_FILE" 2>&1 &
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AceDarkknight/shell-executor-mcp/cmd/client/render"
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/configs"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// secretMask 输出配置时替换 Token 的掩码
+const secretMask = "********"
+
+// ConfigCmd 表示 config 命令：查看生效的配置，管理配置文件中的上下文
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "View the effective config and manage contexts",
+	Long: `View the effective client config and manage named contexts in the config file.
+
+Each context describes one cluster: its server list, token source and TLS settings.
+Commands use current_context unless --context is given.`,
+}
+
+var useContextCmd = &cobra.Command{
+	Use:     "use-context <name>",
+	Short:   "Set current_context in the config file",
+	Example: "  client config use-context staging",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(useContext(args[0]))
+	},
+}
+
+var viewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Print the effective config with secrets masked",
+	Long: `Print the effective config after merging defaults, the config file, the selected context,
+MCP_CLIENT_* environment variables and command line flags. Tokens are masked.
+
+Use -o yaml to print YAML, otherwise JSON is printed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(viewConfig(cmd.Flags()))
+	},
+}
+
+var getContextsCmd = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "List the contexts in the config file",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(getContexts())
+	},
+}
+
+func init() {
+	ConfigCmd.AddCommand(viewCmd)
+	ConfigCmd.AddCommand(useContextCmd)
+	ConfigCmd.AddCommand(getContextsCmd)
+}
+
+// requireConfigPath 返回正在使用的配置文件路径，没有配置文件时返回错误
+func requireConfigPath() (string, error) {
+	if path := configPath(); path != "" {
+		return path, nil
+	}
+	return "", errors.New("no config file found, use --config to specify one")
+}
+
+// useContext 将配置文件中的 current_context 设置为指定的上下文
+func useContext(name string) error {
+	path, err := requireConfigPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := configs.LoadClientConfig(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.Context(name); !ok {
+		return fmt.Errorf("context %q not found in %s", name, path)
+	}
+
+	if err := configs.SetCurrentContext(path, name); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to context %q.\n", name)
+	return nil
+}
+
+// getContexts 列出配置文件中的上下文，当前上下文以 * 标记
+func getContexts() error {
+	path, err := requireConfigPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := configs.LoadClientConfig(path)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CURRENT\tNAME\tSERVERS\tTOKEN")
+	for _, ctx := range cfg.Contexts {
+		current := ""
+		if ctx.Name == cfg.CurrentContext {
+			current = "*"
+		}
+		urls := make([]string, 0, len(ctx.Servers))
+		for _, server := range ctx.Servers {
+			urls = append(urls, server.URL)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", current, ctx.Name, strings.Join(urls, ","), tokenSource(&ctx))
+	}
+	return tw.Flush()
+}
+
+// tokenSource 描述上下文的 Token 来源，不输出 Token 本身
+func tokenSource(ctx *configs.ContextConfig) string {
+	switch {
+	case ctx.Token != "":
+		return "inline"
+	case ctx.TokenEnv != "":
+		return "env:" + ctx.TokenEnv
+	case ctx.TokenFile != "":
+		return "file:" + ctx.TokenFile
+	case ctx.TokenCommand != "":
+		return "command"
+	}
+	return "none"
+}
+
+// viewConfig 输出合并后生效的配置，Token 以掩码显示
+func viewConfig(flags *pflag.FlagSet) error {
+	cfg, err := resolveConfig(flags)
+	if err != nil {
+		return err
+	}
+	maskSecrets(cfg)
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if viper.GetString("output") == render.FormatYAML {
+		var doc map[string]any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return err
+		}
+	} else {
+		data = append(data, '\n')
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// maskSecrets 将配置（包括所有上下文）中的明文 Token 替换为掩码
+func maskSecrets(cfg *configs.ClientConfig) {
+	if cfg.Token != "" {
+		cfg.Token = secretMask
+	}
+	for i := range cfg.Contexts {
+		if cfg.Contexts[i].Token != "" {
+			cfg.Contexts[i].Token = secretMask
+		}
+	}
+}
+
+// exitOnError 将错误输出到标准错误并以退出码 1 退出
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/AceDarkknight/shell-executor-mcp/cmd/client/render"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/mcpclient"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// exec 命令的退出码，供脚本和 CI 判断集群执行结果
+const (
+	ExitOK                = 0 // 所有节点执行成功
+	ExitError             = 1 // 参数、配置或服务端返回的其他错误
+	ExitNodeFailure       = 2 // 部分或全部节点执行失败、超时或被跳过
+	ExitSecurityViolation = 3 // 命令被服务端安全策略拒绝
+	ExitConnectionFailure = 4 // 无法连接服务器或执行过程中连接中断
+	ExitPending           = 5 // 超过软超时仍有节点在执行，结果不完整
+)
+
+// ExecCmd 表示 exec 命令：执行一次命令后退出
+var ExecCmd = &cobra.Command{
+	Use:   "exec [flags] -- <command>",
+	Short: "Execute a command on the cluster once and exit",
+	Long: `Execute a shell command on the cluster once, print the result to stdout and exit.
+
+Exit status:
+  0  all nodes succeeded
+  1  invalid arguments, configuration or other errors
+  2  the command failed, timed out or was skipped on some nodes
+  3  the command was rejected by the security policy
+  4  could not connect to the server or the connection was lost
+  5  some nodes were still running when the soft timeout expired; the output
+     shows the job ID to poll for the rest`,
+	Example: `  client exec -- uptime
+  client exec --timeout 30s --targets 'web-*' -- systemctl is-active nginx
+  client exec -o table -- cat /etc/os-release
+  client exec --timeout 10m --soft-timeout -1s -- ./long-task.sh`,
+	Args:          cobra.MinimumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		code, err := runExec(cmd, strings.Join(args, " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	ExecCmd.Flags().Duration("timeout", 0, "Per-node execution timeout, e.g. 30s (server default 5s)")
+	ExecCmd.Flags().Duration("soft-timeout", 0, "Return partial results after this long and leave the rest running as a job; negative waits for all nodes (default: server setting)")
+	ExecCmd.Flags().StringSlice("targets", nil, "Node names or URLs to run on, glob patterns such as web-* are allowed (default all nodes)")
+}
+
+// runExec 连接服务器并执行一次命令，返回退出码
+func runExec(cmd *cobra.Command, command string) (int, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := loadConfig(ctx, cmd.Flags())
+	if err != nil {
+		return ExitError, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// 标准输出只输出执行结果，日志只写文件
+	logCfg := cfg.Log.ToLoggerConfig()
+	logCfg.Console = logger.ConsoleNone
+	if err := logger.InitLogger(&logCfg, "client.log"); err != nil {
+		return ExitError, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer logger.Sync()
+
+	if len(cfg.Servers) == 0 {
+		return ExitError, errors.New("no servers configured")
+	}
+
+	renderer, err := render.New(viper.GetString("output"))
+	if err != nil {
+		return ExitError, err
+	}
+
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	softTimeout, _ := cmd.Flags().GetDuration("soft-timeout")
+	targets, _ := cmd.Flags().GetStringSlice("targets")
+	var opts []mcpclient.ExecOption
+	if timeout > 0 {
+		opts = append(opts, mcpclient.WithExecTimeout(timeout))
+	}
+	if softTimeout != 0 {
+		opts = append(opts, mcpclient.WithSoftTimeout(softTimeout))
+	}
+	if len(targets) > 0 {
+		opts = append(opts, mcpclient.WithTargets(targets...))
+	}
+
+	client, err := createAndConnectClient(ctx, cfg)
+	if err != nil {
+		return ExitConnectionFailure, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	logger.Infof("执行命令: %s", command)
+	result, err := client.ExecuteCommand(ctx, command, opts...)
+	if err != nil {
+		if mcpclient.IsConnectionError(err) {
+			return ExitConnectionFailure, err
+		}
+		return ExitError, err
+	}
+
+	if err := printResult(renderer, viper.GetString("output"), result); err != nil {
+		return ExitError, err
+	}
+	return execExitCode(result), nil
+}
+
+// printResult 输出执行结果
+// 错误结果（如安全检查拒绝）输出到标准错误；json / yaml 格式的结果总是输出到标准输出，便于脚本解析
+func printResult(renderer render.Renderer, format string, result *mcpclient.Result) error {
+	w := os.Stdout
+	if result.IsError && format != render.FormatJSON && format != render.FormatYAML {
+		w = os.Stderr
+	}
+	return renderer.Render(w, result)
+}
+
+// execExitCode 根据执行结果计算退出码
+// 已有节点失败时返回 ExitNodeFailure，否则仍有节点在执行（超过软超时）时返回 ExitPending
+func execExitCode(result *mcpclient.Result) int {
+	switch {
+	case result.IsSecurityViolation():
+		return ExitSecurityViolation
+	case result.IsError:
+		return ExitError
+	case len(result.FailedNodes()) > 0:
+		return ExitNodeFailure
+	case len(result.Pending) > 0 || result.JobID != "":
+		return ExitPending
+	default:
+		return ExitOK
+	}
+}
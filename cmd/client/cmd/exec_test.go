@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/mcpclient"
+)
+
+// TestExecExitCode 验证执行结果对应的退出码，超过软超时仍有节点在执行时不返回成功
+func TestExecExitCode(t *testing.T) {
+	success := mcpclient.AggregatedGroup{Status: mcpclient.StatusSuccess, Nodes: []string{"node-01"}}
+	running := mcpclient.AggregatedGroup{Status: mcpclient.StatusRunning, Nodes: []string{"node-02"}}
+	failed := mcpclient.AggregatedGroup{Status: "failed", Nodes: []string{"node-03"}}
+
+	tests := []struct {
+		name   string
+		result *mcpclient.Result
+		want   int
+	}{
+		{"全部成功", &mcpclient.Result{Groups: []mcpclient.AggregatedGroup{success}}, ExitOK},
+		{"节点失败", &mcpclient.Result{Groups: []mcpclient.AggregatedGroup{success, failed}}, ExitNodeFailure},
+		{"仍在执行", &mcpclient.Result{Groups: []mcpclient.AggregatedGroup{success, running}, JobID: "job", Pending: []string{"node-02"}}, ExitPending},
+		{"失败优先于仍在执行", &mcpclient.Result{Groups: []mcpclient.AggregatedGroup{failed, running}, JobID: "job", Pending: []string{"node-02"}}, ExitNodeFailure},
+		{"错误结果", &mcpclient.Result{IsError: true}, ExitError},
+	}
+	for _, tt := range tests {
+		if got := execExitCode(tt.result); got != tt.want {
+			t.Errorf("%s: execExitCode() = %d, 预期 %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// fileHistory 持久化到文件的命令历史记录，实现 term.History 接口
+// 每条记录占一行，新记录追加到文件末尾；加载时只保留最近的 limit 条
+type fileHistory struct {
+	entries []string // 按时间顺序排列，最新的在最后
+	limit   int
+	file    *os.File // 为 nil 时只保存在内存中
+}
+
+// openHistory 加载历史记录文件，文件不存在时创建；path 为空时只在内存中保存历史记录
+func openHistory(path string, limit int) (*fileHistory, error) {
+	h := &fileHistory{limit: limit}
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	// 文件中的记录超过上限时重写文件，避免文件无限增长
+	loaded := len(h.entries)
+	h.trim()
+	if len(h.entries) < loaded {
+		if err := os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	h.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Add 添加一条历史记录，忽略空行和与上一条相同的记录
+func (h *fileHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" {
+		return
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == entry {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	h.trim()
+	if h.file != nil {
+		_, _ = h.file.WriteString(entry + "\n")
+	}
+}
+
+// Len 返回历史记录条数
+func (h *fileHistory) Len() int {
+	return len(h.entries)
+}
+
+// At 返回历史记录，0 表示最新的一条
+func (h *fileHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+// Close 关闭历史记录文件
+func (h *fileHistory) Close() error {
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
+
+// trim 只保留最近的 limit 条记录
+func (h *fileHistory) trim() {
+	if h.limit > 0 && len(h.entries) > h.limit {
+		h.entries = append([]string(nil), h.entries[len(h.entries)-h.limit:]...)
+	}
+}
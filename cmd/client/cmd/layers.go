@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/configs"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultConfigFile = "client_config.json" // 未指定 --config 时，当前目录下存在该文件则使用
+	envPrefix         = "MCP_CLIENT"         // 环境变量前缀
+)
+
+// configOverride 一个可以通过环境变量和命令行参数覆盖的 ClientConfig 字段
+type configOverride struct {
+	key   string // 环境变量为 MCP_CLIENT_<KEY>，命令行参数为 --<key>（_ 换成 -）
+	flag  bool   // 是否有对应的命令行参数，没有时只支持环境变量
+	apply func(cfg *configs.ClientConfig, value string) error
+}
+
+// configOverrides 列出所有可覆盖的配置项
+// Token 的各个来源互斥：覆盖其中一个时清空低优先级层中配置的其他来源
+var configOverrides = []configOverride{
+	{"server", true, func(cfg *configs.ClientConfig, v string) error {
+		cfg.Servers = parseServers(v)
+		return nil
+	}},
+	{"token", true, func(cfg *configs.ClientConfig, v string) error {
+		clearToken(cfg)
+		cfg.Token = v
+		return nil
+	}},
+	{"token_env", true, func(cfg *configs.ClientConfig, v string) error {
+		clearToken(cfg)
+		cfg.TokenEnv = v
+		return nil
+	}},
+	{"token_file", true, func(cfg *configs.ClientConfig, v string) error {
+		clearToken(cfg)
+		cfg.TokenFile = v
+		return nil
+	}},
+	{"token_command", true, func(cfg *configs.ClientConfig, v string) error {
+		clearToken(cfg)
+		cfg.TokenCommand = v
+		return nil
+	}},
+	{"insecure_skip_verify", true, func(cfg *configs.ClientConfig, v string) error {
+		return setBool(&cfg.InsecureSkipVerify, v)
+	}},
+	{"log_level", true, func(cfg *configs.ClientConfig, v string) error {
+		cfg.Log.Level = v
+		return nil
+	}},
+	{"log_dir", true, func(cfg *configs.ClientConfig, v string) error {
+		cfg.Log.LogDir = v
+		return nil
+	}},
+	{"log_max_size", false, func(cfg *configs.ClientConfig, v string) error {
+		return setInt(&cfg.Log.MaxSize, v)
+	}},
+	{"log_max_backups", false, func(cfg *configs.ClientConfig, v string) error {
+		return setInt(&cfg.Log.MaxBackups, v)
+	}},
+	{"log_max_age", false, func(cfg *configs.ClientConfig, v string) error {
+		return setInt(&cfg.Log.MaxAge, v)
+	}},
+	{"log_compress", false, func(cfg *configs.ClientConfig, v string) error {
+		return setBool(&cfg.Log.Compress, v)
+	}},
+}
+
+// configPath 返回使用的配置文件路径：--config 或 MCP_CLIENT_CONFIG 指定的文件，
+// 否则为当前目录下存在的 client_config.json；都没有时返回空字符串
+func configPath() string {
+	if path := viper.GetString("config"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return defaultConfigFile
+	}
+	return ""
+}
+
+// loadConfig 加载客户端配置并解析 Token，flags 为当前命令的参数（包括继承的全局参数）
+func loadConfig(ctx context.Context, flags *pflag.FlagSet) (*configs.ClientConfig, error) {
+	cfg, err := resolveConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Token, err = cfg.ResolveToken(ctx); err != nil {
+		return nil, fmt.Errorf("failed to resolve token: %w", err)
+	}
+	return cfg, nil
+}
+
+// resolveConfig 按 默认值 < 配置文件 < 环境变量 < 命令行参数 的优先级合并配置，不解析 Token
+// 配置文件中定义了上下文时，先使用 --context 或 current_context 指定的上下文，再应用环境变量和命令行参数
+func resolveConfig(flags *pflag.FlagSet) (*configs.ClientConfig, error) {
+	cfg := configs.DefaultClientConfig()
+	if path := configPath(); path != "" {
+		if err := configs.ReadClientConfig(path, cfg); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+	if err := cfg.UseContext(viper.GetString("context")); err != nil {
+		return nil, err
+	}
+
+	if err := applyOverrides(cfg, envOverride); err != nil {
+		return nil, err
+	}
+	if err := applyOverrides(cfg, flagOverride(flags)); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyOverrides 将一层（环境变量或命令行参数）中设置了的配置项覆盖到 cfg 上
+// lookup 返回配置项的值、来源名称（用于错误信息）以及是否设置
+func applyOverrides(cfg *configs.ClientConfig, lookup func(o configOverride) (string, string, bool)) error {
+	for _, o := range configOverrides {
+		value, source, ok := lookup(o)
+		if !ok {
+			continue
+		}
+		if err := o.apply(cfg, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, source, err)
+		}
+	}
+	return nil
+}
+
+// envOverride 从 MCP_CLIENT_<KEY> 环境变量读取配置项，空值视为未设置
+func envOverride(o configOverride) (string, string, bool) {
+	name := envPrefix + "_" + strings.ToUpper(o.key)
+	value := os.Getenv(name)
+	return value, name, value != ""
+}
+
+// flagOverride 返回从命令行中显式指定的参数读取配置项的函数
+func flagOverride(flags *pflag.FlagSet) func(o configOverride) (string, string, bool) {
+	return func(o configOverride) (string, string, bool) {
+		if !o.flag {
+			return "", "", false
+		}
+		name := strings.ReplaceAll(o.key, "_", "-")
+		f := flags.Lookup(name)
+		if f == nil || !f.Changed {
+			return "", "", false
+		}
+		return f.Value.String(), "--" + name, true
+	}
+}
+
+// parseServers 解析逗号分隔的服务器 URL 列表，服务器名称为 URL 本身
+func parseServers(value string) []configs.ServerConfig {
+	var servers []configs.ServerConfig
+	for _, url := range strings.Split(value, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			servers = append(servers, configs.ServerConfig{Name: url, URL: url})
+		}
+	}
+	return servers
+}
+
+// clearToken 清空所有 Token 来源
+func clearToken(cfg *configs.ClientConfig) {
+	cfg.Token = ""
+	cfg.TokenEnv = ""
+	cfg.TokenFile = ""
+	cfg.TokenCommand = ""
+}
+
+// setBool 解析布尔值
+func setBool(dst *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*dst = b
+	return nil
+}
+
+// setInt 解析整数
+func setInt(dst *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/configs"
+
+	"github.com/spf13/pflag"
+)
+
+// TestApplyOverrides 验证环境变量覆盖配置文件、命令行参数覆盖环境变量，且 Token 来源互斥
+func TestApplyOverrides(t *testing.T) {
+	cfg := configs.DefaultClientConfig()
+	cfg.Servers = []configs.ServerConfig{{Name: "file", URL: "http://file/mcp"}}
+	cfg.Token = "from-file"
+	cfg.Log.Level = "debug"
+
+	t.Setenv("MCP_CLIENT_TOKEN_ENV", "PROD_TOKEN")
+	t.Setenv("MCP_CLIENT_LOG_MAX_SIZE", "5")
+	t.Setenv("MCP_CLIENT_LOG_LEVEL", "warn")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("server", "", "")
+	flags.String("token-file", "", "")
+	flags.String("log-level", "", "")
+	flags.Bool("insecure-skip-verify", false, "")
+	if err := flags.Parse([]string{"--server", "http://a/mcp, http://b/mcp", "--token-file", "/etc/token", "--insecure-skip-verify"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyOverrides(cfg, envOverride); err != nil {
+		t.Fatalf("应用环境变量失败: %v", err)
+	}
+	if err := applyOverrides(cfg, flagOverride(flags)); err != nil {
+		t.Fatalf("应用命令行参数失败: %v", err)
+	}
+
+	if len(cfg.Servers) != 2 || cfg.Servers[1].URL != "http://b/mcp" {
+		t.Errorf("Servers = %+v, 预期来自 --server", cfg.Servers)
+	}
+	if cfg.Token != "" || cfg.TokenEnv != "" || cfg.TokenFile != "/etc/token" {
+		t.Errorf("Token 来源 = %q/%q/%q, 预期只有 --token-file", cfg.Token, cfg.TokenEnv, cfg.TokenFile)
+	}
+	if cfg.Log.Level != "warn" || cfg.Log.MaxSize != 5 || cfg.Log.MaxAge != 28 || !cfg.InsecureSkipVerify {
+		t.Errorf("配置合并结果不正确: %+v", cfg)
+	}
+
+	t.Setenv("MCP_CLIENT_LOG_COMPRESS", "maybe")
+	if err := applyOverrides(cfg, envOverride); err == nil {
+		t.Error("无效的布尔值应返回错误")
+	}
+}
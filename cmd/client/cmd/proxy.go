@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/mcpclient"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/spf13/cobra"
+)
+
+// MCPProxyCmd 表示 mcp-proxy 命令：在标准输入输出上提供 MCP 服务，将请求转发到远程集群
+var MCPProxyCmd = &cobra.Command{
+	Use:   "mcp-proxy",
+	Short: "Serve MCP over stdio and forward it to the cluster",
+	Long: `Serve MCP over stdin/stdout for desktop MCP hosts and forward tools/list and tools/call
+to the remote cluster, using the configured servers (with failover), token and TLS settings.
+
+Progress and logging notifications from the cluster are relayed to the host; cancellations and
+logging/setLevel from the host are forwarded to the cluster. Logs are written to the log file
+and stderr, never to stdout.`,
+	Example: `  # MCP host configuration (e.g. claude_desktop_config.json)
+  {"mcpServers": {"cluster": {"command": "client", "args": ["--context", "prod", "mcp-proxy"]}}}`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runMCPProxy(cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// mcpProxy 将本地 MCP 会话的请求转发到远程集群，并将远程的通知转发回本地会话
+type mcpProxy struct {
+	client  *mcpclient.Client
+	session atomic.Pointer[mcp.ServerSession] // 本地（标准输入输出）会话
+}
+
+// runMCPProxy 连接集群并在标准输入输出上提供 MCP 服务，直到标准输入关闭或收到退出信号
+func runMCPProxy(cmd *cobra.Command) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := loadConfig(ctx, cmd.Flags())
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// 标准输出用于 MCP 协议，日志只能写文件和标准错误
+	logCfg := cfg.Log.ToLoggerConfig()
+	logCfg.Console = logger.ConsoleStderr
+	if err := logger.InitLogger(&logCfg, "client.log"); err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer logger.Sync()
+
+	if len(cfg.Servers) == 0 {
+		return errors.New("no servers configured")
+	}
+
+	p := &mcpProxy{}
+	p.client, err = createAndConnectClient(ctx, cfg,
+		mcpclient.WithProgressHandler(p.relayProgress),
+		mcpclient.WithLoggingHandler(p.relayLog),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer p.client.Close()
+
+	server := mcp.NewServer(&mcp.Implementation{
+		Name:    "shell-executor-mcp-proxy",
+		Version: "1.0.0",
+	}, &mcp.ServerOptions{
+		Capabilities: &mcp.ServerCapabilities{
+			Tools:   &mcp.ToolCapabilities{},
+			Logging: &mcp.LoggingCapabilities{},
+		},
+	})
+	server.AddReceivingMiddleware(p.forward)
+
+	session, err := server.Connect(ctx, &mcp.StdioTransport{}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to serve MCP over stdio: %w", err)
+	}
+	p.session.Store(session)
+	logger.Infof("MCP 代理已启动，转发到 %d 个服务器", len(cfg.Servers))
+
+	// 收到退出信号时关闭本地会话
+	go func() {
+		<-ctx.Done()
+		_ = session.Close()
+	}()
+	if err := session.Wait(); err != nil && ctx.Err() == nil {
+		logger.Warnf("本地 MCP 会话异常结束: %v", err)
+	}
+	logger.Info("MCP 代理已退出")
+	return nil
+}
+
+// forward 拦截本地会话的请求：tools/list、tools/call 转发到集群，
+// logging/setLevel 同时在本地会话和集群上生效，其他请求（initialize、ping 等）由本地处理
+func (p *mcpProxy) forward(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		switch method {
+		case "tools/list":
+			params, _ := req.GetParams().(*mcp.ListToolsParams)
+			return p.client.ListTools(ctx, params)
+
+		case "tools/call":
+			params := req.GetParams().(*mcp.CallToolParamsRaw)
+			return p.callTool(ctx, params), nil
+
+		case "logging/setLevel":
+			// 本地会话按级别过滤转发的日志通知，集群按级别决定是否发送
+			result, err := next(ctx, method, req)
+			if err != nil {
+				return nil, err
+			}
+			params := req.GetParams().(*mcp.SetLoggingLevelParams)
+			if err := p.client.SetLoggingLevel(ctx, params.Level); err != nil {
+				logger.Warnf("转发日志级别失败: %v", err)
+			}
+			return result, nil
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// callTool 将 Tool 调用（包括 _meta 中的 progressToken）转发到集群
+// 连接失败等转发错误以错误结果返回，便于 MCP 客户端向用户展示
+func (p *mcpProxy) callTool(ctx context.Context, params *mcp.CallToolParamsRaw) *mcp.CallToolResult {
+	forwarded := &mcp.CallToolParams{Meta: params.Meta, Name: params.Name}
+	if len(params.Arguments) > 0 {
+		forwarded.Arguments = params.Arguments
+	}
+
+	result, err := p.client.CallToolRaw(ctx, forwarded)
+	if err != nil {
+		logger.Errorf("转发 Tool 调用 %s 失败: %v", params.Name, err)
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("failed to reach the cluster: %v", err)}},
+		}
+	}
+	return result
+}
+
+// relayProgress 将集群的进度通知转发到本地会话，progressToken 与本地请求中的相同
+func (p *mcpProxy) relayProgress(ctx context.Context, params *mcp.ProgressNotificationParams) {
+	session := p.session.Load()
+	if session == nil {
+		return
+	}
+	if err := session.NotifyProgress(ctx, params); err != nil {
+		logger.Warnf("转发进度通知失败: %v", err)
+	}
+}
+
+// relayLog 将集群的日志通知转发到本地会话，本地会话按客户端设置的级别过滤
+func (p *mcpProxy) relayLog(ctx context.Context, params *mcp.LoggingMessageParams) {
+	session := p.session.Load()
+	if session == nil {
+		return
+	}
+	if err := session.Log(ctx, params); err != nil {
+		logger.Warnf("转发日志通知失败: %v", err)
+	}
+}
@@ -0,0 +1,519 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/cmd/client/render"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/mcpclient"
+
+	"golang.org/x/term"
+)
+
+// 交互式命令行的默认配置
+const (
+	defaultHistoryFile = ".shell_executor_history" // 位于用户主目录下
+	maxHistoryEntries  = 1000                      // 历史记录最多保留的条数
+	nodesQueryTimeout  = 10 * time.Second          // 查询节点列表的超时时间
+)
+
+// replMetaCommands 交互式命令行支持的元命令及说明
+var replMetaCommands = []struct {
+	name, usage, help string
+}{
+	{":help", ":help", "show this help"},
+	{":nodes", ":nodes", "list cluster nodes"},
+	{":target", ":target [all | <node|pattern>...]", "show or set target nodes, e.g. :target web-*"},
+	{":timeout", ":timeout [default | <seconds|duration>]", "show or set per-node timeout, e.g. :timeout 60"},
+	{":format", ":format [" + strings.Join(render.Formats(), "|") + "]", "show or set output format"},
+	{":server", ":server [name]", "list configured servers or switch to one"},
+	{":quit", ":quit", "exit (also exit, quit or Ctrl-D)"},
+}
+
+// lineReader 逐行读取用户输入
+type lineReader interface {
+	ReadLine() (string, error)
+	SetPrompt(prompt string)
+}
+
+// pipeReader 标准输入不是终端时（如管道、重定向）使用的 lineReader，不显示提示符
+type pipeReader struct {
+	r *bufio.Reader
+}
+
+// ReadLine 读取一行输入，去掉行尾的换行符
+func (p *pipeReader) ReadLine() (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// SetPrompt 非终端输入不显示提示符
+func (p *pipeReader) SetPrompt(string) {}
+
+// repl 交互式命令行
+type repl struct {
+	client   *mcpclient.Client
+	input    lineReader
+	out      io.Writer // 执行结果和提示信息的输出，只在终端的常规模式下写入
+	format   string
+	renderer render.Renderer
+	targets  []string
+	timeout  time.Duration
+	nodes    []string // 节点名称缓存，用于补全
+
+	// 终端模式下，执行命令期间恢复终端的常规模式，使 Ctrl-C 可以取消正在执行的命令
+	fd       int
+	rawState *term.State
+}
+
+// runREPL 运行交互式命令行，直到用户退出或输入结束
+func runREPL(client *mcpclient.Client, format string, historyFile string) error {
+	renderer, err := render.New(format)
+	if err != nil {
+		return err
+	}
+	r := &repl{
+		client:   client,
+		out:      os.Stdout,
+		format:   format,
+		renderer: renderer,
+		fd:       int(os.Stdin.Fd()),
+	}
+
+	fmt.Fprintln(r.out, "Shell Executor MCP Client")
+	fmt.Fprintln(r.out, "Type :help for meta-commands, 'exit' or 'quit' to exit")
+	r.refreshNodes(context.Background())
+
+	if term.IsTerminal(r.fd) {
+		state, err := term.MakeRaw(r.fd)
+		if err != nil {
+			return fmt.Errorf("failed to set terminal raw mode: %w", err)
+		}
+		defer term.Restore(r.fd, state)
+		r.rawState = state
+
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, "")
+		if width, height, err := term.GetSize(r.fd); err == nil && width > 0 {
+			t.SetSize(width, height)
+		}
+		history, err := openHistory(historyFile, maxHistoryEntries)
+		if err != nil {
+			logger.Warnf("打开历史记录文件失败: %v", err)
+		} else {
+			defer history.Close()
+			t.History = history
+		}
+		t.AutoCompleteCallback = r.complete
+		r.input = t
+	} else {
+		r.input = &pipeReader{r: bufio.NewReader(os.Stdin)}
+	}
+	return r.loop()
+}
+
+// loop 读取并执行命令，直到用户退出或输入结束
+func (r *repl) loop() error {
+	for {
+		cmd, err := r.readCommand()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		cmd = strings.TrimSpace(cmd)
+		switch {
+		case cmd == "":
+			continue
+		case cmd == "exit" || cmd == "quit":
+			return nil
+		}
+		logger.Debugf("用户输入: %s", cmd)
+
+		quit := r.withCookedTerminal(func(ctx context.Context) bool {
+			if strings.HasPrefix(cmd, ":") {
+				return r.meta(ctx, cmd)
+			}
+			r.execute(ctx, cmd)
+			return false
+		})
+		if quit {
+			return nil
+		}
+	}
+}
+
+// withCookedTerminal 恢复终端的常规模式后执行 fn，执行期间 Ctrl-C 会取消 fn 使用的 ctx
+func (r *repl) withCookedTerminal(fn func(ctx context.Context) bool) bool {
+	if r.rawState != nil {
+		term.Restore(r.fd, r.rawState)
+		defer term.MakeRaw(r.fd)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return fn(ctx)
+}
+
+// prompt 返回当前的提示符，设置了目标节点时显示在提示符中
+func (r *repl) prompt() string {
+	if len(r.targets) > 0 {
+		return fmt.Sprintf("mcp[%s]> ", strings.Join(r.targets, ","))
+	}
+	return "mcp> "
+}
+
+// readCommand 读取一条完整的命令，支持行尾反斜杠续行和 heredoc 多行输入
+func (r *repl) readCommand() (string, error) {
+	r.input.SetPrompt(r.prompt())
+	line, err := r.input.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	lines := []string{line}
+	for !strings.HasPrefix(strings.TrimSpace(line), ":") && needsContinuation(lines) {
+		r.input.SetPrompt("... ")
+		next, err := r.input.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, next)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// heredocPattern 匹配 heredoc 起始符：<<EOF、<<-EOF、<<'EOF'、<<"EOF"，不匹配 <<< here-string
+var heredocPattern = regexp.MustCompile(`(?:^|[^<])<<(-?)[ \t]*['"]?([A-Za-z_][A-Za-z0-9_]*)['"]?`)
+
+// needsContinuation 判断已输入的行是否还需要继续输入
+// heredoc 没有遇到结束符，或最后一行以反斜杠结尾（续行）时返回 true
+func needsContinuation(lines []string) bool {
+	type heredoc struct {
+		word      string
+		stripTabs bool
+	}
+	var pending []heredoc
+	for _, line := range lines {
+		if len(pending) > 0 {
+			check := line
+			if pending[0].stripTabs {
+				check = strings.TrimLeft(line, "\t")
+			}
+			if check == pending[0].word {
+				pending = pending[1:]
+			}
+			continue
+		}
+		for _, m := range heredocPattern.FindAllStringSubmatch(line, -1) {
+			pending = append(pending, heredoc{word: m[2], stripTabs: m[1] == "-"})
+		}
+	}
+	if len(pending) > 0 {
+		return true
+	}
+
+	// 行尾有奇数个反斜杠表示续行
+	last := lines[len(lines)-1]
+	trailing := len(last) - len(strings.TrimRight(last, `\`))
+	return trailing%2 == 1
+}
+
+// execute 在集群上执行命令并输出结果
+func (r *repl) execute(ctx context.Context, cmd string) {
+	var opts []mcpclient.ExecOption
+	if r.timeout > 0 {
+		opts = append(opts, mcpclient.WithExecTimeout(r.timeout))
+	}
+	if len(r.targets) > 0 {
+		opts = append(opts, mcpclient.WithTargets(r.targets...))
+	}
+
+	logger.Infof("准备执行命令: %s", cmd)
+	result, err := r.client.ExecuteCommand(ctx, cmd, opts...)
+	if err != nil {
+		logger.Errorf("执行命令失败: %v", err)
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+		return
+	}
+	if result.IsError {
+		logger.Warnf("服务器返回错误，命令: %s", cmd)
+	}
+	if err := r.renderer.Render(r.out, result); err != nil {
+		logger.Errorf("输出执行结果失败: %v", err)
+	}
+}
+
+// meta 执行元命令，返回是否退出
+func (r *repl) meta(ctx context.Context, line string) bool {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+	switch name {
+	case ":help":
+		r.printHelp()
+	case ":quit", ":exit":
+		return true
+	case ":nodes":
+		r.listNodes(ctx)
+	case ":target":
+		r.setTargets(args)
+	case ":timeout":
+		r.setTimeout(args)
+	case ":format":
+		r.setFormat(args)
+	case ":server":
+		r.server(ctx, args)
+	default:
+		fmt.Fprintf(r.out, "Unknown command %s, type :help for help\n", name)
+	}
+	return false
+}
+
+// printHelp 输出元命令说明
+func (r *repl) printHelp() {
+	tw := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	for _, c := range replMetaCommands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.usage, c.help)
+	}
+	tw.Flush()
+	fmt.Fprintln(r.out, "Multi-line input: end a line with \\ to continue, or use a heredoc (<<EOF ... EOF).")
+}
+
+// nodeInfo list_nodes tool 结构化输出中的单个节点
+type nodeInfo struct {
+	Name      string            `json:"name"`
+	URL       string            `json:"url"`
+	Labels    map[string]string `json:"labels"`
+	State     string            `json:"state"`
+	Reachable bool              `json:"reachable"`
+	Self      bool              `json:"self"`
+}
+
+// fetchNodes 通过 list_nodes tool 查询集群节点，并更新补全用的节点名称缓存
+func (r *repl) fetchNodes(ctx context.Context) ([]nodeInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, nodesQueryTimeout)
+	defer cancel()
+
+	var out struct {
+		Nodes []nodeInfo `json:"nodes"`
+	}
+	if _, err := r.client.CallTool(ctx, "list_nodes", map[string]any{}, &out); err != nil {
+		return nil, err
+	}
+	r.nodes = r.nodes[:0]
+	for _, n := range out.Nodes {
+		if n.Name != "" {
+			r.nodes = append(r.nodes, n.Name)
+		} else {
+			r.nodes = append(r.nodes, n.URL)
+		}
+	}
+	return out.Nodes, nil
+}
+
+// refreshNodes 刷新节点名称缓存，失败时只记录日志
+func (r *repl) refreshNodes(ctx context.Context) {
+	if _, err := r.fetchNodes(ctx); err != nil {
+		logger.Warnf("查询节点列表失败: %v", err)
+	}
+}
+
+// listNodes 输出集群节点列表
+func (r *repl) listNodes(ctx context.Context) {
+	nodes, err := r.fetchNodes(ctx)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+		return
+	}
+	tw := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tURL\tSTATE\tREACHABLE\tLABELS")
+	for _, n := range nodes {
+		name := n.Name
+		if n.Self {
+			name += " (*)"
+		}
+		var labels []string
+		for k, v := range n.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", name, n.URL, n.State, n.Reachable, strings.Join(labels, ","))
+	}
+	tw.Flush()
+}
+
+// setTargets 显示或设置目标节点，all 表示所有节点
+func (r *repl) setTargets(args []string) {
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "all":
+		r.targets = nil
+	default:
+		r.targets = nil
+		for _, arg := range args {
+			for _, t := range strings.Split(arg, ",") {
+				if t != "" {
+					r.targets = append(r.targets, t)
+				}
+			}
+		}
+	}
+	if len(r.targets) == 0 {
+		fmt.Fprintln(r.out, "Targets: all nodes")
+	} else {
+		fmt.Fprintf(r.out, "Targets: %s\n", strings.Join(r.targets, ", "))
+	}
+}
+
+// setTimeout 显示或设置每个节点的执行超时，纯数字表示秒
+func (r *repl) setTimeout(args []string) {
+	if len(args) > 0 {
+		timeout, err := parseTimeout(args[0])
+		if err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			return
+		}
+		r.timeout = timeout
+	}
+	if r.timeout == 0 {
+		fmt.Fprintln(r.out, "Timeout: server default")
+	} else {
+		fmt.Fprintf(r.out, "Timeout: %s\n", r.timeout)
+	}
+}
+
+// parseTimeout 解析超时参数：default 或 0 表示服务端默认值，纯数字表示秒，也支持 90s、2m 等格式
+func parseTimeout(s string) (time.Duration, error) {
+	if s == "default" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(s); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("invalid timeout %q", s)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	return d, nil
+}
+
+// setFormat 显示或设置输出格式
+func (r *repl) setFormat(args []string) {
+	if len(args) > 0 {
+		renderer, err := render.New(args[0])
+		if err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			return
+		}
+		r.format, r.renderer = args[0], renderer
+	}
+	fmt.Fprintf(r.out, "Format: %s\n", r.format)
+}
+
+// server 列出配置的服务器，或切换到指定服务器
+func (r *repl) server(ctx context.Context, args []string) {
+	if len(args) > 0 {
+		if err := r.client.SwitchServer(ctx, args[0]); err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			return
+		}
+		r.refreshNodes(ctx)
+	}
+
+	current, connected := r.client.CurrentServer()
+	for _, s := range r.client.Servers() {
+		mark := " "
+		if connected && s.Name == current.Name {
+			mark = "*"
+		}
+		fmt.Fprintf(r.out, "%s %s\t%s\n", mark, s.Name, s.URL)
+	}
+}
+
+// complete Tab 补全：元命令名称，以及 :target 的节点名称、:format 的格式和 :server 的服务器名称
+func (r *repl) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	prefix, suffix := line[:pos], line[pos:]
+	completed, ok := r.completePrefix(prefix)
+	if !ok {
+		return "", 0, false
+	}
+	return completed + suffix, len(completed), true
+}
+
+// completePrefix 补全光标前的最后一个单词，没有候选项时返回 false
+func (r *repl) completePrefix(prefix string) (string, bool) {
+	if !strings.HasPrefix(prefix, ":") {
+		return "", false
+	}
+	word := prefix[strings.LastIndexAny(prefix, " \t")+1:]
+
+	var candidates []string
+	if !strings.ContainsAny(prefix, " \t") {
+		for _, c := range replMetaCommands {
+			candidates = append(candidates, c.name)
+		}
+	} else {
+		switch strings.Fields(prefix)[0] {
+		case ":target":
+			candidates = append([]string{"all"}, r.nodes...)
+		case ":format":
+			candidates = render.Formats()
+		case ":server":
+			for _, s := range r.client.Servers() {
+				candidates = append(candidates, s.Name)
+			}
+		}
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(matches) == 1 {
+		common += " "
+	}
+	return prefix[:len(prefix)-len(word)] + common, true
+}
+
+// defaultHistoryPath 返回默认的历史记录文件路径，无法获取主目录时返回空字符串（不保存历史记录）
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultHistoryFile)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/configs"
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/mcpclient"
+)
+
+// TestNeedsContinuation 验证反斜杠续行和 heredoc 的多行输入判断
+func TestNeedsContinuation(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  bool
+	}{
+		{[]string{"echo hi"}, false},
+		{[]string{`echo a \`}, true},
+		{[]string{`echo a \`, "b"}, false},
+		{[]string{`echo a\\`}, false},
+		{[]string{"cat <<EOF"}, true},
+		{[]string{"cat <<EOF", "line", "EOF"}, false},
+		{[]string{"cat <<-'END' > /tmp/x", "\tline", "\tEND"}, false},
+		{[]string{"cat <<A <<B", "A"}, true},
+		{[]string{"cat <<A <<B", "A", "B"}, false},
+		{[]string{"grep x <<< \"$v\""}, false},
+	}
+	for _, tt := range tests {
+		if got := needsContinuation(tt.lines); got != tt.want {
+			t.Errorf("needsContinuation(%q) = %v, 预期 %v", tt.lines, got, tt.want)
+		}
+	}
+}
+
+// TestCompletePrefix 验证元命令、节点名称、输出格式和服务器名称的补全
+func TestCompletePrefix(t *testing.T) {
+	client, err := mcpclient.NewClient(&configs.ClientConfig{
+		Servers: []configs.ServerConfig{
+			{Name: "primary", URL: "http://127.0.0.1:1/mcp"},
+			{Name: "backup", URL: "http://127.0.0.1:2/mcp"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	r := &repl{client: client, nodes: []string{"web-01", "web-02", "db-01"}}
+
+	tests := []struct {
+		prefix, want string
+		ok           bool
+	}{
+		{":no", ":nodes ", true},
+		{":t", ":t", true}, // :target 和 :timeout 没有更长的公共前缀
+		{":ta", ":target ", true},
+		{":target we", ":target web-0", true},
+		{":target web-01 d", ":target web-01 db-01 ", true},
+		{":format ta", ":format table ", true},
+		{":server b", ":server backup ", true},
+		{":target x", "", false},
+		{"ls -l", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := r.completePrefix(tt.prefix)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("completePrefix(%q) = %q, %v, 预期 %q, %v", tt.prefix, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestFileHistory 验证历史记录的持久化、去重和条数上限
+func TestFileHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := openHistory(path, 3)
+	if err != nil {
+		t.Fatalf("openHistory 失败: %v", err)
+	}
+	h.Add("four")
+	h.Add("four")
+	h.Add("  ")
+	if h.Len() != 3 || h.At(0) != "four" || h.At(2) != "two" {
+		t.Errorf("历史记录 = %v, 预期 [two three four]", h.entries)
+	}
+	h.Close()
+
+	// 重新打开时只保留最近的 3 条，并重写文件
+	h, err = openHistory(path, 3)
+	if err != nil {
+		t.Fatalf("重新打开历史记录失败: %v", err)
+	}
+	defer h.Close()
+	if strings.Join(h.entries, ",") != "two,three,four" {
+		t.Errorf("重新加载的历史记录 = %v", h.entries)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "two\nthree\nfour\n" {
+		t.Errorf("历史记录文件内容 = %q", data)
+	}
+}
@@ -1,9 +1,10 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
+	"strings"
 
+	"github.com/AceDarkknight/shell-executor-mcp/cmd/client/render"
 	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
 
 	"github.com/spf13/cobra"
@@ -33,57 +34,37 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
-
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is client_config.json)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is "+defaultConfigFile+" if it exists)")
+	rootCmd.PersistentFlags().String("context", "", "Context (cluster) in the config file to use (default current_context)")
 
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().StringP("server", "s", "", "Complete MCP endpoint URL, e.g. http://localhost:8080/mcp")
-	rootCmd.Flags().String("token", "", "Connection token")
-	rootCmd.Flags().Bool("insecure-skip-verify", false, "Skip TLS verification")
-	rootCmd.Flags().String("log-dir", "", "Log directory")
-	rootCmd.Flags().StringP("log-level", "l", "info", "Log level (debug, info, warn, error)")
+	// 连接相关参数对所有子命令（run、exec）生效，优先级高于环境变量和配置文件，见 loadConfig
+	rootCmd.PersistentFlags().StringP("server", "s", "", "Complete MCP endpoint URL, e.g. http://localhost:8080/mcp (comma separated for failover)")
+	rootCmd.PersistentFlags().String("token", "", "Connection token")
+	rootCmd.PersistentFlags().String("token-env", "", "Read the connection token from this environment variable")
+	rootCmd.PersistentFlags().String("token-file", "", "Read the connection token from this file")
+	rootCmd.PersistentFlags().String("token-command", "", "Credential helper command that prints the connection token")
+	rootCmd.PersistentFlags().Bool("insecure-skip-verify", false, "Skip TLS verification")
+	rootCmd.PersistentFlags().String("log-dir", "", "Log directory (default logs)")
+	rootCmd.PersistentFlags().StringP("log-level", "l", "", "Log level (debug, info, warn, error) (default info)")
+	rootCmd.PersistentFlags().StringP("output", "o", render.FormatText, "Output format: "+strings.Join(render.Formats(), "|"))
 
 	// Bind flags to viper
-	// 环境变量前缀为 MCP_CLIENT_
-	viper.BindPFlag("server", rootCmd.Flags().Lookup("server"))
-	viper.BindPFlag("token", rootCmd.Flags().Lookup("token"))
-	viper.BindPFlag("insecure_skip_verify", rootCmd.Flags().Lookup("insecure-skip-verify"))
-	viper.BindPFlag("log_dir", rootCmd.Flags().Lookup("log-dir"))
-	viper.BindPFlag("log_level", rootCmd.Flags().Lookup("log-level"))
+	// 环境变量前缀为 MCP_CLIENT_，ClientConfig 字段的覆盖不经过 viper，见 configOverrides
+	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	viper.BindPFlag("context", rootCmd.PersistentFlags().Lookup("context"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
 
 	// 设置环境变量前缀
-	viper.SetEnvPrefix("MCP_CLIENT")
+	viper.SetEnvPrefix(envPrefix)
 	viper.AutomaticEnv()
 
 	// 添加子命令
 	rootCmd.AddCommand(RunCmd)
-}
-
-// initConfig reads in config file and ENV variables if set.
-func initConfig() {
-	if cfgFile != "" {
-		// Use config file from the flag.
-		viper.SetConfigFile(cfgFile)
-	} else {
-		// Search config in home directory with name ".k8s-mcp-client" (without extension).
-		viper.AddConfigPath(".")
-		viper.SetConfigName("client_config")
-		viper.SetConfigType("json")
-	}
-
-	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		// 先初始化logger，避免死锁
-		logger.InitLogger(nil, "client.log")
-		logger.Infof("Using config file: %s", viper.ConfigFileUsed())
-	} else {
-		fmt.Printf("Failed to read config file: %v\n", err)
-		os.Exit(1)
-	}
+	rootCmd.AddCommand(ExecCmd)
+	rootCmd.AddCommand(ConfigCmd)
+	rootCmd.AddCommand(MCPProxyCmd)
 }
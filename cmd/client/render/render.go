@@ -0,0 +1,275 @@
+// Package render 将命令执行结果渲染为不同的输出格式，供 exec 子命令和交互式命令行共用
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/mcpclient"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// 输出格式
+const (
+	FormatText  = "text"  // 按组展示的可读格式（默认）
+	FormatJSON  = "json"  // 完整的结构化结果
+	FormatYAML  = "yaml"  // 与 json 内容相同的 YAML 格式
+	FormatTable = "table" // 每个节点一行：状态、退出码、耗时
+	FormatRaw   = "raw"   // 每行输出加上节点名前缀，如 node-01 | ...
+)
+
+// Formats 返回所有支持的输出格式
+func Formats() []string {
+	return []string{FormatText, FormatJSON, FormatYAML, FormatTable, FormatRaw}
+}
+
+// Renderer 将执行结果写入 w
+type Renderer interface {
+	Render(w io.Writer, result *mcpclient.Result) error
+}
+
+// RendererFunc 将普通函数适配为 Renderer
+type RendererFunc func(w io.Writer, result *mcpclient.Result) error
+
+// Render 调用 f(w, result)
+func (f RendererFunc) Render(w io.Writer, result *mcpclient.Result) error {
+	return f(w, result)
+}
+
+// New 返回指定格式的 Renderer，空字符串表示 FormatText
+func New(format string) (Renderer, error) {
+	switch format {
+	case "", FormatText:
+		return RendererFunc(renderText), nil
+	case FormatJSON:
+		return RendererFunc(renderJSON), nil
+	case FormatYAML:
+		return RendererFunc(renderYAML), nil
+	case FormatTable:
+		return RendererFunc(renderTable), nil
+	case FormatRaw:
+		return RendererFunc(renderRaw), nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+	}
+}
+
+// errorMessage 返回错误结果（如安全检查拒绝）的文本
+func errorMessage(result *mcpclient.Result) string {
+	return strings.Join(result.GetTextContents(), "\n")
+}
+
+// renderText 按组展示执行结果，格式与 docs/api.md 中的示例一致
+func renderText(w io.Writer, result *mcpclient.Result) error {
+	if result.IsError {
+		_, err := fmt.Fprintf(w, "Error: %s\n", errorMessage(result))
+		return err
+	}
+
+	var sb strings.Builder
+	if result.Summary != "" {
+		fmt.Fprintf(&sb, "Summary: %s\n\n", result.Summary)
+	}
+	for i, g := range result.Groups {
+		fmt.Fprintf(&sb, "[Group %d] Count: %d | Status: %s", i+1, g.Count, statusTitle(g.Status))
+		if g.ExitCode > 0 {
+			fmt.Fprintf(&sb, " | Exit Code: %d", g.ExitCode)
+		}
+		if g.KilledBy != "" {
+			fmt.Fprintf(&sb, " | Killed By: %s", g.KilledBy)
+		}
+		if g.Sandboxed {
+			sb.WriteString(" | Sandboxed")
+		}
+		if g.Truncated {
+			sb.WriteString(" | Truncated")
+			if g.OutputBytes > 0 || g.ErrorBytes > 0 {
+				fmt.Fprintf(&sb, " (stdout %d bytes, stderr %d bytes)", g.OutputBytes, g.ErrorBytes)
+			}
+		}
+		sb.WriteString("\n")
+		if g.Output != "" {
+			if g.Encoding != "" {
+				fmt.Fprintf(&sb, "Output (%s):\n", g.Encoding)
+			} else {
+				sb.WriteString("Output:\n")
+			}
+			writeBlock(&sb, g.Output)
+		}
+		if g.Error != "" {
+			sb.WriteString("Error: ")
+			writeBlock(&sb, g.Error)
+		}
+		fmt.Fprintf(&sb, "Nodes: %s\n", strings.Join(g.Nodes, ", "))
+		if len(g.Artifacts) > 0 {
+			fmt.Fprintf(&sb, "Artifacts: %s\n", artifactList(g))
+		}
+		sb.WriteString("\n")
+	}
+	if result.JobID != "" {
+		fmt.Fprintf(&sb, "Job: %s (still running on %s)\n", result.JobID, strings.Join(result.Pending, ", "))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// artifactList 按组内节点顺序列出各节点的制品 ID，如 node-1=3f2a...
+func artifactList(g mcpclient.AggregatedGroup) string {
+	var items []string
+	for _, node := range g.Nodes {
+		if id, ok := g.Artifacts[node]; ok {
+			items = append(items, node+"="+id)
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// statusTitle 将状态首字母大写，如 success -> Success
+func statusTitle(status string) string {
+	if status == "" {
+		return status
+	}
+	return strings.ToUpper(status[:1]) + status[1:]
+}
+
+// writeBlock 写入多行文本，确保以换行结尾
+func writeBlock(sb *strings.Builder, text string) {
+	sb.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		sb.WriteString("\n")
+	}
+}
+
+// document json / yaml 格式输出的完整结果
+type document struct {
+	IsError bool        `json:"is_error" yaml:"is_error"`
+	Error   string      `json:"error,omitempty" yaml:"error,omitempty"`
+	Summary string      `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Groups  []groupView `json:"groups" yaml:"groups"`
+	JobID   string      `json:"job_id,omitempty" yaml:"job_id,omitempty"`
+	Pending []string    `json:"pending,omitempty" yaml:"pending,omitempty"`
+}
+
+// groupView 单个分组的输出结构
+type groupView struct {
+	Count       int               `json:"count" yaml:"count"`
+	Status      string            `json:"status" yaml:"status"`
+	ExitCode    int               `json:"exit_code" yaml:"exit_code"`
+	Output      string            `json:"output" yaml:"output"`
+	Error       string            `json:"error" yaml:"error"`
+	Encoding    string            `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	KilledBy    string            `json:"killed_by,omitempty" yaml:"killed_by,omitempty"`
+	Sandboxed   bool              `json:"sandboxed,omitempty" yaml:"sandboxed,omitempty"`
+	Truncated   bool              `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+	OutputBytes int64             `json:"output_bytes,omitempty" yaml:"output_bytes,omitempty"`
+	ErrorBytes  int64             `json:"error_bytes,omitempty" yaml:"error_bytes,omitempty"`
+	Artifacts   map[string]string `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
+	Nodes       []string          `json:"nodes" yaml:"nodes"`
+	DurationsMs map[string]int64  `json:"durations_ms,omitempty" yaml:"durations_ms,omitempty"`
+}
+
+// newDocument 将执行结果转换为 json / yaml 输出结构
+func newDocument(result *mcpclient.Result) document {
+	doc := document{
+		IsError: result.IsError,
+		Summary: result.Summary,
+		Groups:  []groupView{},
+		JobID:   result.JobID,
+		Pending: result.Pending,
+	}
+	if result.IsError {
+		doc.Error = errorMessage(result)
+	}
+	for _, g := range result.Groups {
+		doc.Groups = append(doc.Groups, groupView{
+			Count:       g.Count,
+			Status:      g.Status,
+			ExitCode:    g.ExitCode,
+			Output:      g.Output,
+			Error:       g.Error,
+			Encoding:    g.Encoding,
+			KilledBy:    g.KilledBy,
+			Sandboxed:   g.Sandboxed,
+			Truncated:   g.Truncated,
+			OutputBytes: g.OutputBytes,
+			ErrorBytes:  g.ErrorBytes,
+			Artifacts:   g.Artifacts,
+			Nodes:       g.Nodes,
+			DurationsMs: g.Durations,
+		})
+	}
+	return doc
+}
+
+// renderJSON 输出完整的结构化结果
+func renderJSON(w io.Writer, result *mcpclient.Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newDocument(result))
+}
+
+// renderYAML 以 YAML 格式输出完整的结构化结果
+func renderYAML(w io.Writer, result *mcpclient.Result) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(newDocument(result)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// renderTable 每个节点输出一行：节点、状态、退出码和耗时
+func renderTable(w io.Writer, result *mcpclient.Result) error {
+	if result.IsError {
+		_, err := fmt.Fprintf(w, "Error: %s\n", errorMessage(result))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tSTATUS\tEXIT CODE\tDURATION")
+	for _, n := range result.Nodes() {
+		exitCode := "-"
+		if n.ExitCode >= 0 {
+			exitCode = fmt.Sprint(n.ExitCode)
+		}
+		duration := "-"
+		if n.Duration > 0 {
+			duration = n.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", n.Node, n.Status, exitCode, duration)
+	}
+	return tw.Flush()
+}
+
+// renderRaw 按节点名称顺序逐节点输出，每行加上节点名前缀；错误信息（包括 stderr）排在输出之后
+func renderRaw(w io.Writer, result *mcpclient.Result) error {
+	if result.IsError {
+		_, err := fmt.Fprintf(w, "Error: %s\n", errorMessage(result))
+		return err
+	}
+
+	nodes := result.Nodes()
+	width := 0
+	for _, n := range nodes {
+		width = max(width, len(n.Node))
+	}
+
+	var sb strings.Builder
+	for _, n := range nodes {
+		for _, text := range []string{n.Output, n.Error} {
+			if text == "" {
+				continue
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+				fmt.Fprintf(&sb, "%-*s | %s\n", width, n.Node, line)
+			}
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
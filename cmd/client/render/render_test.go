@@ -0,0 +1,111 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/mcpclient"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// testResult 返回包含成功和失败分组的执行结果
+func testResult() *mcpclient.Result {
+	return &mcpclient.Result{
+		Summary: "Executed on 3 nodes, 2 groups found",
+		Groups: []mcpclient.AggregatedGroup{
+			{Count: 2, Status: "success", Output: "v1\nok\n", Nodes: []string{"node-02", "node-01"},
+				Durations: map[string]int64{"node-01": 12, "node-02": 1500}},
+			{Count: 1, Status: "failed", ExitCode: 2, Error: "not found\n", Nodes: []string{"node-10"},
+				Durations: map[string]int64{"node-10": 3}},
+		},
+	}
+}
+
+// renderString 使用指定格式渲染结果
+func renderString(t *testing.T, format string, result *mcpclient.Result) string {
+	t.Helper()
+	r, err := New(format)
+	if err != nil {
+		t.Fatalf("New(%q) 失败: %v", format, err)
+	}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, result); err != nil {
+		t.Fatalf("渲染 %s 格式失败: %v", format, err)
+	}
+	return buf.String()
+}
+
+// TestRenderText 验证按组展示的格式，节点列表不截断
+func TestRenderText(t *testing.T) {
+	out := renderString(t, FormatText, testResult())
+	for _, want := range []string{
+		"Summary: Executed on 3 nodes, 2 groups found\n",
+		"[Group 1] Count: 2 | Status: Success\nOutput:\nv1\nok\nNodes: node-02, node-01\n",
+		"[Group 2] Count: 1 | Status: Failed | Exit Code: 2\nError: not found\nNodes: node-10\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("text 输出缺少 %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestRenderTable 验证每个节点一行，包含状态、退出码和耗时
+func TestRenderTable(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(renderString(t, FormatTable, testResult())), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("table 输出行数 = %d, 预期 4:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	want := [][]string{
+		{"NODE", "STATUS", "EXIT", "CODE", "DURATION"},
+		{"node-01", "success", "0", "12ms"},
+		{"node-02", "success", "0", "1.5s"},
+		{"node-10", "failed", "2", "3ms"},
+	}
+	for i, line := range lines {
+		if got := strings.Fields(line); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("第 %d 行 = %q, 预期 %v", i, line, want[i])
+		}
+	}
+}
+
+// TestRenderRaw 验证每行输出都带有对齐的节点名前缀
+func TestRenderRaw(t *testing.T) {
+	want := "node-01 | v1\nnode-01 | ok\nnode-02 | v1\nnode-02 | ok\nnode-10 | not found\n"
+	if out := renderString(t, FormatRaw, testResult()); out != want {
+		t.Errorf("raw 输出 = %q, 预期 %q", out, want)
+	}
+}
+
+// TestRenderStructured 验证 json 和 yaml 输出完整的结构化结果
+func TestRenderStructured(t *testing.T) {
+	var doc document
+	if err := json.Unmarshal([]byte(renderString(t, FormatJSON, testResult())), &doc); err != nil {
+		t.Fatalf("解析 json 输出失败: %v", err)
+	}
+	if len(doc.Groups) != 2 || doc.Groups[1].ExitCode != 2 || doc.Groups[0].DurationsMs["node-02"] != 1500 {
+		t.Errorf("json 输出不完整: %+v", doc)
+	}
+
+	var yamlDoc document
+	if err := yaml.Unmarshal([]byte(renderString(t, FormatYAML, testResult())), &yamlDoc); err != nil {
+		t.Fatalf("解析 yaml 输出失败: %v", err)
+	}
+	if yamlDoc.Summary != doc.Summary || len(yamlDoc.Groups) != 2 {
+		t.Errorf("yaml 输出与 json 不一致: %+v", yamlDoc)
+	}
+
+	errResult := &mcpclient.Result{IsError: true, Content: []mcpclient.Content{&mcpclient.TextContent{Text: "security violation: rm"}}}
+	if out := renderString(t, FormatJSON, errResult); !strings.Contains(out, `"error": "security violation: rm"`) {
+		t.Errorf("错误结果的 json 输出 = %s", out)
+	}
+}
+
+// TestNewUnknownFormat 验证未知格式返回错误
+func TestNewUnknownFormat(t *testing.T) {
+	if _, err := New("xml"); err == nil {
+		t.Error("未知格式应返回错误")
+	}
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/config"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// AdminJoinRequest /admin/join 的请求体
+type AdminJoinRequest struct {
+	Seed string `json:"seed"` // 种子节点地址，如 https://10.0.0.1:8080
+}
+
+// AdminPeersResponse /admin/join 和 /admin/leave 的响应体
+type AdminPeersResponse struct {
+	Peers []string `json:"peers"`
+	Error string   `json:"error,omitempty"`
+}
+
+// AdminMembersResponse /admin/members 的响应体
+type AdminMembersResponse struct {
+	Members []cluster.Member `json:"members"`
+}
+
+// requireToken 校验请求头中的 Token，token 为空时不做校验
+func requireToken(header, token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			got := r.Header.Get(header)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				logger.Warnf("%s 校验失败, path=%s, remote=%s", header, r.URL.Path, r.RemoteAddr)
+				http.Error(w, "Unauthorized: invalid token", http.StatusUnauthorized)
+				return
+			}
+			logger.Debugf("%s 校验通过", header)
+		}
+		next(w, r)
+	}
+}
+
+// registerAdminHandlers 注册管理 API，使用 X-Admin-Token 鉴权
+func registerAdminHandlers(mux *http.ServeMux, members *cluster.Membership, adminToken string) {
+	mux.HandleFunc("/admin/join", requireToken("X-Admin-Token", adminToken, adminJoinHandler(members)))
+	mux.HandleFunc("/admin/leave", requireToken("X-Admin-Token", adminToken, adminLeaveHandler(members)))
+	mux.HandleFunc("/admin/members", requireToken("X-Admin-Token", adminToken, adminMembersHandler(members)))
+}
+
+// adminJoinHandler 让本节点通过种子节点加入集群
+func adminJoinHandler(members *cluster.Membership) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req AdminJoinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("收到管理请求: 通过种子节点 %s 加入集群", req.Seed)
+		peers, err := members.JoinSeed(r.Context(), req.Seed)
+		if err != nil {
+			logger.Errorf("加入集群失败: %v", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AdminPeersResponse{Peers: peers})
+	}
+}
+
+// adminLeaveHandler 让本节点通知所有 Peer 后离开集群
+func adminLeaveHandler(members *cluster.Membership) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		logger.Infof("收到管理请求: 离开集群")
+		resp := AdminPeersResponse{Peers: members.Peers()}
+		if err := members.LeaveCluster(r.Context()); err != nil {
+			// 部分 Peer 通知失败不影响本节点离开，仅返回错误信息
+			resp.Error = err.Error()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// adminMembersHandler 探测所有成员并返回成员列表
+func adminMembersHandler(members *cluster.Membership) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		members.Refresh(ctx)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(AdminMembersResponse{Members: members.Members()})
+	}
+}
+
+// peersFile 将运行时的 Peer 列表保存到 peers_file
+// 成员变化回调在节点重复加入、同步相同列表时也会触发，列表没有变化时不写文件
+type peersFile struct {
+	mu    sync.Mutex
+	path  string
+	saved []string // 最近一次写入（或启动时读取）的排序后的列表
+	known bool     // saved 是否与文件内容一致，文件不存在时为 false
+}
+
+// newPeersFile 创建 Peer 状态文件，saved 为启动时从文件读取的列表，exists 表示文件是否存在
+func newPeersFile(path string, saved []string, exists bool) *peersFile {
+	return &peersFile{path: path, saved: slices.Sorted(slices.Values(saved)), known: exists}
+}
+
+// save 在 Peer 列表与上次保存的不同时写入文件
+func (f *peersFile) save(peers []string) error {
+	sorted := slices.Sorted(slices.Values(peers))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.known && slices.Equal(f.saved, sorted) {
+		return nil
+	}
+	if err := config.SavePeers(f.path, sorted); err != nil {
+		return err
+	}
+	f.saved, f.known = sorted, true
+	logger.Debugf("peers 已保存到 %s: %v", f.path, sorted)
+	return nil
+}
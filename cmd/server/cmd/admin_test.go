@@ -0,0 +1,56 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/config"
+)
+
+// TestPeersFile 验证 Peer 列表只在变化时写入状态文件，重启后可以读回
+func TestPeersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.json")
+	if peers, exists, err := config.LoadPeers(path); err != nil || exists || peers != nil {
+		t.Fatalf("LoadPeers(不存在的文件) = %v, %v, %v", peers, exists, err)
+	}
+
+	f := newPeersFile(path, nil, false)
+	if err := f.save([]string{"http://b:8080", "http://a:8080"}); err != nil {
+		t.Fatalf("save 失败: %v", err)
+	}
+	peers, exists, err := config.LoadPeers(path)
+	if err != nil || !exists || !slices.Equal(peers, []string{"http://a:8080", "http://b:8080"}) {
+		t.Fatalf("LoadPeers() = %v, %v, %v", peers, exists, err)
+	}
+
+	// 列表没有变化时不写文件
+	if err := os.WriteFile(path, []byte("sentinel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.save([]string{"http://a:8080", "http://b:8080"}); err != nil {
+		t.Fatalf("save 失败: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "sentinel" {
+		t.Fatalf("列表没有变化时改写了文件: %q", data)
+	}
+
+	// 列表变化时写入；启动时读取到的列表与当前相同时不写入
+	if err := f.save([]string{"http://a:8080"}); err != nil {
+		t.Fatalf("save 失败: %v", err)
+	}
+	peers, exists, _ = config.LoadPeers(path)
+	restarted := newPeersFile(path, peers, exists)
+	if err := os.WriteFile(path, []byte("sentinel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.save([]string{"http://a:8080"}); err != nil {
+		t.Fatalf("save 失败: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "sentinel" {
+		t.Fatalf("列表与启动时读取的相同时改写了文件: %q", data)
+	}
+}
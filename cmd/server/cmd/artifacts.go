@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/artifacts"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// registerArtifactTools 注册读取制品（被截断命令的完整输出）的 MCP Tool
+func registerArtifactTools(mcpServer *mcp.Server, members *cluster.Membership, coordinator *artifacts.Coordinator) {
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "get_artifact",
+		Description: "Read the full output of a truncated command from the node that ran it; artifact IDs are listed per node in the artifacts field of execute_command results. Read large outputs in chunks by passing next_offset back as offset",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true, Title: "Get artifact"},
+	}, handleGetArtifact(members, coordinator))
+}
+
+// GetArtifactInput get_artifact tool 的输入参数
+type GetArtifactInput struct {
+	Node   string `json:"node" jsonschema:"node name or URL that ran the command, as used as the key in artifacts"`
+	ID     string `json:"id" jsonschema:"artifact ID"`
+	Stream string `json:"stream,omitempty" jsonschema:"stdout (default) or stderr"`
+	Offset int64  `json:"offset,omitempty" jsonschema:"byte offset to start reading at"`
+	Length int    `json:"length,omitempty" jsonschema:"maximum number of bytes to return, default 65536, max 1048576"`
+}
+
+// handleGetArtifact 处理 get_artifact tool 的请求
+func handleGetArtifact(members *cluster.Membership, coordinator *artifacts.Coordinator) mcp.ToolHandlerFor[GetArtifactInput, *artifacts.Chunk] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input GetArtifactInput) (*mcp.CallToolResult, *artifacts.Chunk, error) {
+		logger.Debugf("Received get_artifact request: %s, %s", input.Node, input.ID)
+		if input.Node == "" {
+			return nil, nil, fmt.Errorf("node is required")
+		}
+		peers, skipLocal, err := resolveTargets(ctx, members, []string{input.Node})
+		if err != nil {
+			return nil, nil, err
+		}
+		var node string
+		switch {
+		case !skipLocal && len(peers) == 0:
+		case skipLocal && len(peers) == 1:
+			node = peers[0]
+		default:
+			return nil, nil, fmt.Errorf("node %q matches more than one node", input.Node)
+		}
+		chunk, err := coordinator.Read(ctx, node, input.ID, input.Stream, input.Offset, input.Length)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, chunk, nil
+	}
+}
+
+// registerArtifactHandlers 注册 Peer 之间读取制品的 API，Token 校验与其他内部 API 相同
+// 读取不执行命令，关闭流程中仍然可用
+func registerArtifactHandlers(mux *http.ServeMux, store *artifacts.Store, token string) {
+	mux.HandleFunc("/internal/artifacts/{id}", requireToken("X-Cluster-Token", token, internalArtifactHandler(store)))
+}
+
+// internalArtifactHandler 处理 GET /internal/artifacts/{id}?stream=stdout&offset=N&length=N
+func internalArtifactHandler(store *artifacts.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if store == nil {
+			http.Error(w, artifacts.ErrDisabled.Error(), http.StatusNotImplemented)
+			return
+		}
+		query := r.URL.Query()
+		var offset int64
+		var length int
+		var err error
+		if s := query.Get("offset"); s != "" {
+			if offset, err = strconv.ParseInt(s, 10, 64); err != nil {
+				http.Error(w, fmt.Sprintf("invalid offset %q", s), http.StatusBadRequest)
+				return
+			}
+		}
+		if s := query.Get("length"); s != "" {
+			if length, err = strconv.Atoi(s); err != nil {
+				http.Error(w, fmt.Sprintf("invalid length %q", s), http.StatusBadRequest)
+				return
+			}
+		}
+		chunk, err := store.Read(r.PathValue("id"), query.Get("stream"), offset, length)
+		if err != nil {
+			if errors.Is(err, artifacts.ErrNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		writeJSON(w, chunk)
+	}
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+// JoinCmd 表示 join 命令：让正在运行的节点通过种子节点加入集群
+var JoinCmd = &cobra.Command{
+	Use:   "join",
+	Short: "让运行中的节点加入集群",
+	Long:  `通过管理 API 通知运行中的节点向种子节点发起加入请求，种子节点会将新节点同步给集群中的其他节点。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		seed, _ := cmd.Flags().GetString("seed")
+		if seed == "" {
+			exitWithError(fmt.Errorf("--seed is required"))
+		}
+
+		var resp AdminPeersResponse
+		if err := callAdmin(cmd, http.MethodPost, "/admin/join", AdminJoinRequest{Seed: seed}, &resp); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Joined cluster via %s, %d peers:\n", seed, len(resp.Peers))
+		for _, p := range resp.Peers {
+			fmt.Printf("  %s\n", p)
+		}
+	},
+}
+
+// LeaveCmd 表示 leave 命令：让正在运行的节点通知所有 Peer 后离开集群
+var LeaveCmd = &cobra.Command{
+	Use:   "leave",
+	Short: "让运行中的节点优雅地离开集群",
+	Long:  `通过管理 API 通知运行中的节点离开集群，节点会逐一通知所有 Peer 将自己移除。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var resp AdminPeersResponse
+		if err := callAdmin(cmd, http.MethodPost, "/admin/leave", nil, &resp); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("Left cluster, notified %d peers\n", len(resp.Peers))
+		if resp.Error != "" {
+			fmt.Fprintf(os.Stderr, "Warning: some peers were not notified: %s\n", resp.Error)
+		}
+	},
+}
+
+// MembersCmd 表示 members 命令：以表格形式展示集群成员
+var MembersCmd = &cobra.Command{
+	Use:   "members",
+	Short: "查看集群成员",
+	Long:  `通过管理 API 获取运行中节点视角下的集群成员，包括状态、版本、标签和最后通信时间。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var resp AdminMembersResponse
+		if err := callAdmin(cmd, http.MethodGet, "/admin/members", nil, &resp); err != nil {
+			exitWithError(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tURL\tSTATE\tVERSION\tLABELS\tLAST SEEN")
+		for _, m := range resp.Members {
+			name := m.Name
+			if name == "" {
+				name = "-"
+			}
+			if m.Self {
+				name += " (self)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				name, m.URL, m.State, orDash(m.Version), formatLabels(m.Labels), formatLastSeen(m.LastSeen))
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{JoinCmd, LeaveCmd, MembersCmd} {
+		c.Flags().String("node", "", "Admin URL of the running node (default derived from config, e.g. http://127.0.0.1:8080)")
+		c.Flags().String("admin-token", "", "Admin token (default admin_token or cluster_token from config)")
+		c.Flags().Bool("insecure", false, "Skip TLS verification when talking to the node")
+	}
+	JoinCmd.Flags().String("seed", "", "Seed node URL, e.g. https://10.0.0.1:8080")
+
+	rootCmd.AddCommand(JoinCmd, LeaveCmd, MembersCmd)
+}
+
+// callAdmin 调用运行中节点的管理 API
+func callAdmin(cmd *cobra.Command, method, path string, in any, out any) error {
+	nodeURL, token, err := resolveAdminTarget(cmd)
+	if err != nil {
+		return err
+	}
+	insecure, _ := cmd.Flags().GetBool("insecure")
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(nodeURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+
+	client := &http.Client{}
+	if insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request %s failed: %w", nodeURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("node returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// resolveAdminTarget 确定管理 API 地址和 Token
+// 命令行参数优先，否则从配置文件（或 viper）推导
+func resolveAdminTarget(cmd *cobra.Command) (string, string, error) {
+	nodeURL, _ := cmd.Flags().GetString("node")
+	token, _ := cmd.Flags().GetString("admin-token")
+	if nodeURL != "" && token != "" {
+		return nodeURL, token, nil
+	}
+
+	var cfg *config.ServerConfig
+	var err error
+	if cfgFile != "" {
+		cfg, err = config.LoadServerConfig(cfgFile)
+	} else {
+		cfg, err = loadConfigFromViper()
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if nodeURL == "" {
+		scheme := "http"
+		if cfg.TLS.Enabled {
+			scheme = "https"
+		}
+		port := cfg.Port
+		if port == 0 {
+			port = 8080
+		}
+		nodeURL = fmt.Sprintf("%s://127.0.0.1:%d", scheme, port)
+	}
+	if token == "" {
+		token = cfg.GetAdminToken()
+	}
+	return nodeURL, token, nil
+}
+
+// formatLabels 将标签格式化为 k=v,k=v
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// formatLastSeen 将最后通信时间格式化为相对时间
+func formatLastSeen(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return time.Since(t).Truncate(time.Second).String() + " ago"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// exitWithError 输出错误信息并以非 0 状态码退出
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
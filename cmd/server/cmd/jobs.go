@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/dispatch"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/jobs"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/security"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// registerJobTools 注册后台作业相关的 MCP Tools
+func registerJobTools(mcpServer *mcp.Server, guard *security.Guard, members *cluster.Membership, coordinator *jobs.Coordinator) {
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "start_job",
+		Description: "Start a long-running shell command as a background job on the cluster and return its job ID immediately; poll it with get_job",
+	}, handleStartJob(guard, members, coordinator))
+
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "get_job",
+		Description: "Get the per-node status of a background job and its output; pass next_offset of each node back in offsets to only read new output",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true, Title: "Get job"},
+	}, handleGetJob(coordinator))
+
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "cancel_job",
+		Description: "Cancel a background job by killing its process groups on all nodes",
+	}, handleCancelJob(coordinator))
+
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "list_jobs",
+		Description: "List the background jobs of the cluster, newest first, without output",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true, Title: "List jobs"},
+	}, handleListJobs(coordinator))
+}
+
+// StartJobInput start_job tool 的输入参数
+type StartJobInput struct {
+	Command string            `json:"command" jsonschema:"shell command to run in the background"`
+	Timeout int               `json:"timeout,omitempty" jsonschema:"per-node execution timeout in seconds, default 3600, max 86400"`
+	Targets []string          `json:"targets,omitempty" jsonschema:"node names or URLs to run on, glob patterns such as web-* are allowed; empty means all nodes"`
+	Cwd     string            `json:"cwd,omitempty" jsonschema:"absolute working directory on each node, must be under an allowed prefix of the security policy"`
+	Env     map[string]string `json:"env,omitempty" jsonschema:"environment variables merged onto the node's base environment, names must be allowed by the security policy"`
+	Stdin   string            `json:"stdin,omitempty" jsonschema:"data written to the command's standard input; empty means no input"`
+}
+
+// JobIDInput get_job / cancel_job tool 的输入参数
+type JobIDInput struct {
+	ID      string           `json:"id" jsonschema:"job ID returned by start_job"`
+	Offsets map[string]int64 `json:"offsets,omitempty" jsonschema:"byte offset of already read output per node_name, as returned in next_offset; omitted nodes start at 0"`
+}
+
+// ListJobsOutput list_jobs tool 的结构化输出
+type ListJobsOutput struct {
+	Jobs []jobs.Job `json:"jobs"`
+}
+
+// handleStartJob 处理 start_job tool 的请求
+func handleStartJob(guard *security.Guard, members *cluster.Membership, coordinator *jobs.Coordinator) mcp.ToolHandlerFor[StartJobInput, *jobs.Job] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input StartJobInput) (*mcp.CallToolResult, *jobs.Job, error) {
+		logger.Debugf("Received start_job request: %s", input.Command)
+
+		ctx, span := tracing.Start(ctx, "mcp.tool start_job", tracing.KindServer)
+		span.SetAttribute("mcp.tool.name", "start_job")
+		span.SetAttribute("exec.command", input.Command)
+		defer span.End()
+
+		execOpts := executor.Options{Dir: input.Cwd, Env: input.Env, Stdin: input.Stdin}
+		if err := checkCommand(ctx, guard, input.Command, execOpts); err != nil {
+			span.RecordError(err)
+			logger.Warnf("Security violation for job command: %s, error: %v", input.Command, err)
+			return nil, nil, fmt.Errorf("security violation: %v", err)
+		}
+		execOpts = withRule(guard, input.Command, execOpts)
+
+		peers, skipLocal, err := resolveTargets(ctx, members, input.Targets)
+		if err != nil {
+			span.RecordError(err)
+			return nil, nil, err
+		}
+
+		job, err := coordinator.Start(ctx, input.Command, time.Duration(input.Timeout)*time.Second, execOpts, peers, skipLocal)
+		if err != nil {
+			span.RecordError(err)
+			return nil, nil, err
+		}
+		span.SetAttribute("job.id", job.ID)
+		logger.Infof("作业 %s 已在 %d 个节点上启动", job.ID, len(job.Nodes))
+		return nil, job, nil
+	}
+}
+
+// handleGetJob 处理 get_job tool 的请求
+func handleGetJob(coordinator *jobs.Coordinator) mcp.ToolHandlerFor[JobIDInput, *jobs.Job] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input JobIDInput) (*mcp.CallToolResult, *jobs.Job, error) {
+		logger.Debugf("Received get_job request: %s", input.ID)
+		job, err := coordinator.Get(ctx, input.ID, input.Offsets)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, job, nil
+	}
+}
+
+// handleCancelJob 处理 cancel_job tool 的请求
+func handleCancelJob(coordinator *jobs.Coordinator) mcp.ToolHandlerFor[JobIDInput, *jobs.Job] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input JobIDInput) (*mcp.CallToolResult, *jobs.Job, error) {
+		logger.Debugf("Received cancel_job request: %s", input.ID)
+		job, err := coordinator.Cancel(ctx, input.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Infof("作业 %s 已取消，状态: %s", job.ID, job.Status)
+		return nil, job, nil
+	}
+}
+
+// handleListJobs 处理 list_jobs tool 的请求
+func handleListJobs(coordinator *jobs.Coordinator) mcp.ToolHandlerFor[struct{}, ListJobsOutput] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, ListJobsOutput, error) {
+		logger.Debugf("Received list_jobs request")
+		out := ListJobsOutput{Jobs: coordinator.List(ctx)}
+		logger.Infof("list_jobs 返回 %d 个作业", len(out.Jobs))
+		return nil, out, nil
+	}
+}
+
+// registerJobHandlers 注册 Peer 之间的作业 API，Token 校验与其他内部 API 相同
+// 启动作业经过 gate，关闭流程开始后拒绝新作业；查询和取消在关闭期间仍然可用
+func registerJobHandlers(mux *http.ServeMux, gate *drainGate, guard *security.Guard, manager *jobs.Manager, token string) {
+	mux.Handle("/internal/jobs", gate.Wrap(tracing.Middleware(requireToken("X-Cluster-Token", token, internalJobsHandler(guard, manager)))))
+	mux.HandleFunc("/internal/jobs/{id}", requireToken("X-Cluster-Token", token, internalJobHandler(manager)))
+	mux.HandleFunc("/internal/jobs/{id}/cancel", requireToken("X-Cluster-Token", token, internalCancelJobHandler(manager)))
+	mux.HandleFunc("/internal/jobs/{id}/result", requireToken("X-Cluster-Token", token, internalJobResultHandler(manager)))
+}
+
+// internalJobsHandler 处理 /internal/jobs：POST 启动作业，GET 列出本节点的作业
+func internalJobsHandler(guard *security.Guard, manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger.Debugf("收到 /internal/jobs 请求，方法: %s, 远程地址: %s", r.Method, r.RemoteAddr)
+
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, manager.List())
+			return
+		case http.MethodPost:
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req jobs.StartRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Errorf("Failed to decode job request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.ID == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		logger.Infof("收到启动作业请求，id: %s, 命令: %s", req.ID, req.Cmd)
+
+		ctx, span := tracing.Start(r.Context(), "internal.jobs.start", tracing.KindServer)
+		span.SetAttribute("exec.command", req.Cmd)
+		span.SetAttribute("job.id", req.ID)
+		defer span.End()
+
+		if err := checkCommand(ctx, guard, req.Cmd, req.Options); err != nil {
+			span.RecordError(err)
+			logger.Warnf("安全检查失败，作业被拦截: %s, 错误: %v", req.Cmd, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		info, err := manager.Start(req.ID, req.Cmd, time.Duration(req.Timeout)*time.Second, withRule(guard, req.Cmd, req.Options))
+		if err != nil {
+			span.RecordError(err)
+			logger.Warnf("启动作业失败: %v", err)
+			switch {
+			case errors.Is(err, executor.ErrShuttingDown):
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			case errors.Is(err, jobs.ErrExists):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, jobs.ErrTooManyJobs):
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+			default:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		writeJSON(w, info)
+	}
+}
+
+// internalJobHandler 处理 GET /internal/jobs/{id}?offset=N，返回作业状态和从 offset 开始的输出
+func internalJobHandler(manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		offset, err := jobs.ParseOffset(r.URL.Query().Get("offset"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		info, err := manager.Get(r.PathValue("id"), offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, info)
+	}
+}
+
+// internalJobResultHandler 处理 GET /internal/jobs/{id}/result，返回作业状态和与 execute_command 形式相同的执行结果
+func internalJobResultHandler(manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		info, err := manager.Result(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, info)
+	}
+}
+
+// internalCancelJobHandler 处理 POST /internal/jobs/{id}/cancel
+func internalCancelJobHandler(manager *jobs.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		logger.Infof("收到取消作业请求，id: %s", r.PathValue("id"))
+		info, err := manager.Cancel(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, info)
+	}
+}
+
+// writeJSON 以 JSON 格式返回响应
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// executeAsJob 以后台作业执行 execute_command，最多等待 softTimeout
+// 所有节点都在软超时内结束时返回与普通执行相同的结果；否则返回已结束节点的结果、
+// 仍在执行节点目前为止的输出，以及可通过 get_job 查询后续结果的作业 ID
+// 各节点的结果与 Execute 形式相同：标准错误在 Error 中，输出超过上限时截断并保存制品，按 opts.Exec.Normalize 规范化
+func executeAsJob(ctx context.Context, coordinator *jobs.Coordinator, cmd string, opts dispatch.Options, softTimeout time.Duration) (ExecuteCommandOutput, error) {
+	timeout := dispatch.ClampTimeout(opts.Timeout)
+	logger.Infof("以后台作业执行命令: %s, 软超时: %s, 超时: %s", cmd, softTimeout, timeout)
+
+	job, err := coordinator.Start(ctx, cmd, timeout, opts.Exec, opts.Peers, opts.SkipLocal)
+	if err != nil {
+		return ExecuteCommandOutput{}, err
+	}
+	job, err = coordinator.Wait(ctx, job.ID, softTimeout)
+	if err != nil {
+		return ExecuteCommandOutput{}, err
+	}
+
+	results := make([]dispatch.NodeResult, 0, len(job.Nodes))
+	var pending []string
+	for _, n := range job.Nodes {
+		if n.Status == jobs.StatusRunning {
+			pending = append(pending, n.NodeName)
+		}
+		results = append(results, jobNodeResult(n))
+	}
+	groups := dispatch.Aggregate(results)
+	out := ExecuteCommandOutput{
+		Summary: dispatch.Summarize(results, groups),
+		Groups:  groups,
+	}
+	if len(pending) > 0 {
+		out.JobID = job.ID
+		out.Pending = pending
+		out.Summary += fmt.Sprintf("; %d nodes still running as job %s, poll it with get_job", len(pending), job.ID)
+		logger.Infof("命令在软超时内未全部结束，转为后台作业 %s，仍在执行: %v", job.ID, pending)
+	}
+	return out, nil
+}
+
+// jobNodeResult 将作业在节点上的状态转换为执行结果
+// 节点返回了执行结果时，已结束节点的状态与普通执行的判断一致：退出码非 0 或有错误信息视为失败
+func jobNodeResult(n jobs.NodeJob) dispatch.NodeResult {
+	r := dispatch.NodeResult{
+		NodeName:  n.NodeName,
+		Status:    n.Status,
+		ExitCode:  n.ExitCode,
+		Error:     n.Error,
+		KilledBy:  n.KilledBy,
+		Sandboxed: n.Sandboxed,
+		Duration:  time.Duration(n.DurationMs) * time.Millisecond,
+	}
+	res := n.Result
+	if res == nil {
+		return r
+	}
+	r.Output, r.Error, r.Encoding = res.Output, res.Error, res.Encoding
+	r.Truncated, r.OutputBytes, r.ErrorBytes, r.Artifact = res.Truncated, res.OutputBytes, res.ErrorBytes, res.Artifact
+	if r.Status == jobs.StatusSuccess && r.Error != "" {
+		r.Status = jobs.StatusFailed
+	}
+	return r
+}
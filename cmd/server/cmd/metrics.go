@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/config"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// setupMetrics 注册集群相关指标并暴露 /metrics
+// 配置了独立监听地址时启动单独的 HTTP Server 并返回，否则挂载到 mux 上并返回 nil
+func setupMetrics(cfg config.MetricsConfig, mux *http.ServeMux, members *cluster.Membership) *http.Server {
+	metrics.Default.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "shell_executor_membership_size",
+		Help: "Number of cluster members including this node, excluding nodes that left.",
+	}, func() float64 { return float64(len(members.Peers()) + 1) }))
+
+	handler := requireBearer(cfg.Token, metrics.Handler())
+
+	if cfg.Listen == "" {
+		mux.Handle("/metrics", handler)
+		logger.Infof("Metrics endpoint: /metrics")
+		return nil
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", handler)
+	server := &http.Server{
+		Addr:    cfg.Listen,
+		Handler: metricsMux,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("Metrics server failed: %v", err)
+		}
+	}()
+	logger.Infof("Metrics endpoint: http://%s/metrics", cfg.Listen)
+	return server
+}
+
+// requireBearer 校验 Authorization: Bearer <token>，token 为空时不校验
+func requireBearer(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			logger.Warnf("Unauthorized metrics request: remote=%s", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
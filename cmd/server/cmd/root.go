@@ -39,6 +39,7 @@ func init() {
 	// 将对整个应用程序全局有效。
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is server_config.json)")
+	rootCmd.PersistentFlags().String("transport", TransportHTTP, "MCP transport: http (Streamable HTTP, cluster) or stdio (single host, no port)")
 
 	// Cobra 还支持本地标志，这些标志
 	// 仅在直接调用此操作时运行。
@@ -51,6 +52,7 @@ func init() {
 	rootCmd.Flags().String("log-dir", "", "Log directory")
 	rootCmd.Flags().StringP("node-name", "n", "", "Node name (default to hostname)")
 	rootCmd.Flags().StringP("log-level", "l", "info", "Log level (debug, info, warn, error)")
+	rootCmd.Flags().Int("drain-timeout", 30, "Seconds to wait for in-flight commands on shutdown")
 
 	// 将标志绑定到 viper
 	// 环境变量前缀为 MCP_
@@ -63,6 +65,8 @@ func init() {
 	viper.BindPFlag("log_dir", rootCmd.Flags().Lookup("log-dir"))
 	viper.BindPFlag("node_name", rootCmd.Flags().Lookup("node-name"))
 	viper.BindPFlag("log_level", rootCmd.Flags().Lookup("log-level"))
+	viper.BindPFlag("drain_timeout", rootCmd.Flags().Lookup("drain-timeout"))
+	viper.BindPFlag("transport", rootCmd.PersistentFlags().Lookup("transport"))
 
 	// 设置环境变量前缀
 	viper.SetEnvPrefix("MCP")
@@ -85,9 +89,6 @@ func initConfig() {
 	}
 
 	// 如果找到配置文件，则读取它。
-	if err := viper.ReadInConfig(); err == nil {
-		// 先初始化logger，避免死锁
-		logger.InitLogger(nil, "server.log")
-		logger.Infof("Using config file: %s", viper.ConfigFileUsed())
-	}
+	// 这里不初始化 logger：logger 只能初始化一次，由 run 按配置（包括 stdio 模式下的控制台输出）初始化
+	_ = viper.ReadInConfig()
 }
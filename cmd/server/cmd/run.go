@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -9,15 +10,29 @@ import (
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
 	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/AceDarkknight/shell-executor-mcp/internal/artifacts"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/jobs"
+
 	"github.com/AceDarkknight/shell-executor-mcp/internal/security"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/sessions"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/version"
 
 	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
 
@@ -46,29 +61,37 @@ var RunCmd = &cobra.Command{
 func runServer() {
 	// 1. 加载配置
 	// 如果没有指定配置文件，尝试从 viper 读取
+	// 日志初始化之前不能使用 logger（stdio 模式下默认的控制台输出会破坏协议），错误直接输出到标准错误
 	var cfg *config.ServerConfig
 	var err error
 
 	if cfgFile != "" {
 		// 使用指定的配置文件
 		cfg, err = config.LoadServerConfig(cfgFile)
-		if err != nil {
-			logger.Fatalf("Failed to load config: %v", err)
-		}
-		logger.Infof("Loaded config from file: %+v", cfg)
 	} else {
 		// 从 viper 读取配置（可能来自环境变量或默认配置文件）
 		cfg, err = loadConfigFromViper()
-		if err != nil {
-			logger.Fatalf("Failed to load config from viper: %v", err)
-		}
-		logger.Infof("成功从 viper 加载配置")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	transport := viper.GetString("transport")
+	if transport == "" {
+		transport = TransportHTTP
+	}
+	if transport != TransportHTTP && transport != TransportStdio {
+		fmt.Fprintf(os.Stderr, "Invalid transport %q, expected %s or %s\n", transport, TransportHTTP, TransportStdio)
+		os.Exit(1)
 	}
 
 	// 初始化日志（必须在调用任何logger函数之前）
-	logCfg := &cfg.LogConfig
-	if err := logger.InitLogger(logCfg, "server.log"); err != nil {
-		logger.Fatalf("Failed to initialize logger: %v", err)
+	// stdio 模式下标准输出用于 MCP 协议，日志只能写文件和标准错误
+	logCfg := logConfigFor(transport, cfg.LogConfig)
+	if err := logger.InitLogger(&logCfg, "server.log"); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
 	}
 	defer logger.Sync()
 
@@ -78,15 +101,35 @@ func runServer() {
 		logger.Debugf("使用指定的配置文件: %s", cfgFile)
 		logger.Infof("成功加载配置文件: %s", cfgFile)
 	} else {
+		if path := viper.ConfigFileUsed(); path != "" {
+			logger.Infof("Using config file: %s", path)
+		}
 		logger.Debugf("未指定配置文件，从 viper 读取配置")
 		logger.Infof("成功从 viper 加载配置")
 	}
 
 	logger.Infof("========================================")
 	logger.Infof("Server starting as node: %s", cfg.NodeName)
-	logger.Infof("Listening on port: %d", cfg.Port)
+	logger.Infof("Transport: %s", transport)
+	if transport == TransportHTTP {
+		logger.Infof("Listening on port: %d", cfg.Port)
+	}
 	logger.Infof("========================================")
 
+	// 初始化链路追踪
+	exporter, err := tracing.NewExporter(cfg.Tracing)
+	if err != nil {
+		logger.Fatalf("Failed to initialize tracing exporter: %v", err)
+	}
+	tracing.Init(exporter, map[string]string{
+		"service.name":    "shell-executor-mcp",
+		"service.version": version.Version,
+		"node.name":       cfg.NodeName,
+	})
+	if exporter != nil {
+		logger.Infof("链路追踪已启用，导出器: %s", cfg.Tracing.Exporter)
+	}
+
 	// 2. 初始化组件
 	logger.Debugf("初始化安全卫士，黑名单命令: %v", cfg.Security.BlacklistedCommands)
 	logger.Debugf("初始化安全卫士，危险参数正则: %v", cfg.Security.DangerousArgsRegex)
@@ -94,27 +137,108 @@ func runServer() {
 	if err != nil {
 		logger.Fatalf("Failed to initialize security guard: %v", err)
 	}
+	logger.Debugf("初始化执行参数策略，允许的工作目录前缀: %v, 允许的环境变量: %v", cfg.Security.AllowedCwdPrefixes, cfg.Security.AllowedEnv)
+	if err := guard.SetExecPolicy(cfg.Security.AllowedCwdPrefixes, cfg.Security.AllowedEnv); err != nil {
+		logger.Fatalf("Failed to initialize security guard: %v", err)
+	}
+	if err := guard.SetRules(cfg.Security.Rules); err != nil {
+		logger.Fatalf("Failed to initialize security guard: %v", err)
+	}
 	logger.Infof("安全卫士初始化成功")
 
 	logger.Debugf("初始化命令执行器")
 	executor := executor.NewExecutor()
+	// 命令只继承配置中允许的环境变量，避免泄露服务器自身的环境变量
+	baseEnv := cfg.Environment.BaseEnv()
+	executor.SetBaseEnv(baseEnv)
+	logger.Debugf("命令的基础环境变量数量: %d", len(baseEnv))
+	if err := executor.Configure(cfg.Executor); err != nil {
+		logger.Fatalf("Failed to initialize executor: %v", err)
+	}
+	if cfg.Executor.RunAsUser != "" || cfg.Executor.RunAsGroup != "" {
+		logger.Infof("命令以用户 %q、用户组 %q 运行", cfg.Executor.RunAsUser, cfg.Executor.RunAsGroup)
+	}
+	if cfg.Executor.Cgroup != "" {
+		logger.Infof("每次执行使用 cgroup: %s", cfg.Executor.Cgroup)
+	}
+	if cfg.Executor.Sandbox.Enabled {
+		logger.Infof("所有命令在沙箱中运行，允许网络: %v, 可写路径: %v", cfg.Executor.Sandbox.Network, cfg.Executor.Sandbox.WritablePaths)
+	}
+	var artifactStore *artifacts.Store
+	if cfg.Artifacts.Dir != "" {
+		artifactStore, err = artifacts.NewStore(cfg.Artifacts)
+		if err != nil {
+			logger.Fatalf("Failed to initialize artifacts: %v", err)
+		}
+		executor.SetArtifacts(artifactStore)
+		logger.Infof("被截断命令的完整输出保存在: %s", cfg.Artifacts.Dir)
+	}
 	logger.Infof("命令执行器初始化成功")
 
+	jobManager := jobs.NewManager(executor, cfg.Jobs)
+	sessionManager := sessions.NewManager(executor, cfg.Sessions)
+
+	if transport == TransportStdio {
+		serveStdio(cfg, guard, executor, jobManager, sessionManager, artifactStore, &mcp.StdioTransport{})
+		return
+	}
+
+	// 合并 peers_file 中保存的运行时 Peer 列表
+	var peersStore *peersFile
+	if cfg.PeersFile != "" {
+		saved, exists, err := config.LoadPeers(cfg.PeersFile)
+		if err != nil {
+			logger.Fatalf("Failed to load peers file: %v", err)
+		}
+		for _, p := range saved {
+			cfg.AddPeer(p)
+		}
+		peersStore = newPeersFile(cfg.PeersFile, saved, exists)
+		logger.Infof("从 %s 加载了 %d 个 peers", cfg.PeersFile, len(saved))
+	}
+
 	logger.Debugf("初始化集群分发器，peers: %v, token: %s", cfg.GetPeers(), cfg.ClusterToken)
 	dispatcher := dispatch.NewDispatcher(cfg.GetPeers(), cfg.ClusterToken)
+	dispatcher.SetResponseLimit(dispatch.ResponseLimit(cfg.Executor.MaxOutput, cfg.GetMaxResponse()))
 	logger.Infof("集群分发器初始化成功")
 
+	logger.Debugf("初始化集群成员管理，本节点地址: %s", cfg.SelfURL())
+	members := cluster.NewMembership(cluster.NodeInfo{
+		Name:          cfg.NodeName,
+		URL:           cfg.SelfURL(),
+		Version:       version.Version,
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		Labels:        cfg.Labels,
+		PolicyVersion: guard.PolicyVersion(),
+	}, cfg.GetPeers(), cfg.ClusterToken)
+	members.OnChange(func(peers []string) {
+		logger.Infof("集群成员变化，当前 peers: %v", peers)
+		cfg.SetPeers(peers)
+		dispatcher.SetPeers(peers)
+		// 只保存 Peer 列表，不改写运维管理的配置文件
+		if peersStore != nil {
+			if err := peersStore.save(peers); err != nil {
+				logger.Warnf("保存 peers 到 %s 失败: %v", cfg.PeersFile, err)
+			}
+		}
+	})
+	logger.Infof("集群成员管理初始化成功")
+
+	jobCoordinator := jobs.NewCoordinator(jobManager, cfg.NodeName, members.Peers, cfg.ClusterToken)
+	sessionCoordinator := sessions.NewCoordinator(sessionManager, cfg.NodeName, members.Peers, cfg.ClusterToken)
+	artifactCoordinator := artifacts.NewCoordinator(artifactStore, cfg.NodeName, cfg.ClusterToken)
+
 	// 3. 创建 MCP Server
-	logger.Debugf("创建 MCP Server: name=shell-executor-mcp, version=1.0.0")
+	logger.Debugf("创建 MCP Server: name=shell-executor-mcp, version=%s", version.Version)
 	mcpServer := mcp.NewServer(&mcp.Implementation{
 		Name:    "shell-executor-mcp",
-		Version: "1.0.0",
+		Version: version.Version,
 	}, nil)
 	logger.Infof("MCP Server 创建成功")
 
 	// 4. 注册 MCP Tools
 	logger.Debugf("注册 MCP Tools")
-	registerTools(mcpServer, guard, executor, dispatcher, cfg)
+	registerTools(mcpServer, guard, executor, dispatcher, members, jobCoordinator, sessionCoordinator, artifactCoordinator, cfg)
 	logger.Infof("MCP Tools 注册成功")
 
 	// 5. 创建 HTTP Handler (Streamable HTTP)
@@ -133,12 +257,14 @@ func runServer() {
 	// 但 mcpHandler 本身是一个 http.Handler
 	// 我们使用 http.NewServeMux 并将 MCP handler 挂载到 /mcp，内部 API 挂载到 /internal
 	logger.Debugf("创建 HTTP ServeMux 并注册路由")
+	// 关闭流程开始后，/mcp 和 /internal/exec 不再接受新请求
+	gate := &drainGate{}
 	mux := http.NewServeMux()
-	mux.Handle("/mcp", mcpHandler)
+	mux.Handle("/mcp", gate.Wrap(tracing.Middleware(mcpHandler)))
 	logger.Debugf("注册 MCP handler 到 /mcp")
 
 	// 包装内部 API Handler 以确保它们可以被访问
-	mux.HandleFunc("/internal/exec", internalExecHandler(guard, executor, cfg.ClusterToken))
+	mux.Handle("/internal/exec", gate.Wrap(tracing.Middleware(requireToken("X-Cluster-Token", cfg.ClusterToken, internalExecHandler(guard, executor)))))
 	logger.Debugf("注册内部 API: /internal/exec")
 
 	// 健康检查端点
@@ -148,10 +274,30 @@ func runServer() {
 	})
 	logger.Debugf("注册健康检查: /health")
 
-	mux.HandleFunc("/internal/join", internalJoinHandler(cfg, cfgFile))
+	mux.HandleFunc("/internal/join", requireToken("X-Cluster-Token", cfg.ClusterToken, internalJoinHandler(members)))
 	logger.Debugf("注册内部 API: /internal/join")
-	mux.HandleFunc("/internal/sync", internalSyncHandler(cfg, cfgFile))
+	mux.HandleFunc("/internal/sync", requireToken("X-Cluster-Token", cfg.ClusterToken, internalSyncHandler(members)))
 	logger.Debugf("注册内部 API: /internal/sync")
+	mux.HandleFunc("/internal/leave", requireToken("X-Cluster-Token", cfg.ClusterToken, internalLeaveHandler(members)))
+	logger.Debugf("注册内部 API: /internal/leave")
+	mux.HandleFunc("/internal/info", requireToken("X-Cluster-Token", cfg.ClusterToken, internalInfoHandler(members)))
+	logger.Debugf("注册内部 API: /internal/info")
+	registerJobHandlers(mux, gate, guard, jobManager, cfg.ClusterToken)
+	logger.Debugf("注册内部 API: /internal/jobs/...")
+	registerSessionHandlers(mux, gate, guard, executor, sessionManager, cfg.ClusterToken)
+	logger.Debugf("注册内部 API: /internal/sessions/...")
+	registerArtifactHandlers(mux, artifactStore, cfg.ClusterToken)
+	logger.Debugf("注册内部 API: /internal/artifacts/...")
+
+	// 管理 API（供 server join/leave/members 子命令调用）
+	registerAdminHandlers(mux, members, cfg.GetAdminToken())
+	logger.Debugf("注册管理 API: /admin/...")
+
+	// Prometheus 指标
+	var metricsServer *http.Server
+	if cfg.Metrics.Enabled {
+		metricsServer = setupMetrics(cfg.Metrics, mux, members)
+	}
 
 	// 7. 启动 HTTP Server
 	addr := ":" + strconv.Itoa(cfg.Port)
@@ -164,27 +310,56 @@ func runServer() {
 		logger.Infof("MCP endpoint: http://localhost%s/mcp", addr)
 	}
 	logger.Infof("Internal API endpoints: /internal/...")
+	logger.Infof("Admin API endpoints: /admin/...")
 	logger.Infof("========================================")
 	logger.Infof("服务器启动完成，等待请求...")
 
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
 	if cfg.TLS.Enabled {
 		tlsConfig, err := buildTLSConfig(cfg)
 		if err != nil {
 			logger.Fatalf("Failed to build TLS config: %v", err)
 		}
-		server := &http.Server{
-			Addr:      addr,
-			Handler:   mux,
-			TLSConfig: tlsConfig,
-		}
-		// 如果使用自动生成的证书，TLSConfig 已包含证书，传空字符串
-		if err := server.ListenAndServeTLS("", ""); err != nil {
-			logger.Fatalf("HTTPS Server failed: %v", err)
+		server.TLSConfig = tlsConfig
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.TLS.Enabled {
+			// 如果使用自动生成的证书，TLSConfig 已包含证书，传空字符串
+			serveErr <- server.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- server.ListenAndServe()
 		}
-	} else {
-		if err := http.ListenAndServe(addr, mux); err != nil {
+	}()
+
+	// 重启后重新加入集群（上次关闭时已通知 Peer 本节点离开）
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		members.Rejoin(ctx)
+	}()
+
+	// 8. 等待退出信号并优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serveErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Fatalf("Server failed: %v", err)
 		}
+	case <-ctx.Done():
+		logger.Infof("收到退出信号，准备关闭服务器")
+		// 再次收到信号时恢复默认行为，允许用户强制退出
+		stop()
+		gracefulShutdown(server, gate, executor, sessionManager, members, cfg.GetDrainTimeout())
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
 	}
 }
 
@@ -225,6 +400,7 @@ func loadConfigFromViper() (*config.ServerConfig, error) {
 	// 尝试从 viper 读取 peers
 	peers := viper.GetStringSlice("peers")
 	cfg.Peers = peers
+	cfg.PeersFile = viper.GetString("peers_file")
 
 	// TLS 配置
 	cfg.TLS = config.TLSConfig{
@@ -233,11 +409,81 @@ func loadConfigFromViper() (*config.ServerConfig, error) {
 		KeyFile:  viper.GetString("tls_key"),
 	}
 
+	// 配置文件中的字段名为 cluster_token，与命令行参数 token 不同
+	if cfg.ClusterToken == "" {
+		cfg.ClusterToken = viper.GetString("cluster_token")
+	}
+
+	// 集群成员相关配置
+	cfg.AdvertiseURL = viper.GetString("advertise_url")
+	cfg.AdminToken = viper.GetString("admin_token")
+	cfg.Labels = viper.GetStringMapString("labels")
+	cfg.DrainTimeout = viper.GetInt("drain_timeout")
+	cfg.MaxResponse = viper.GetInt("max_response")
+	cfg.Tracing = tracing.Config{
+		Exporter: viper.GetString("tracing.exporter"),
+		Endpoint: viper.GetString("tracing.endpoint"),
+		Headers:  viper.GetStringMapString("tracing.headers"),
+		File:     viper.GetString("tracing.file"),
+	}
+	cfg.Metrics = config.MetricsConfig{
+		Enabled: viper.GetBool("metrics.enabled"),
+		Listen:  viper.GetString("metrics.listen"),
+		Token:   viper.GetString("metrics.token"),
+	}
+	cfg.Jobs = jobs.Config{
+		Retention:   viper.GetInt("jobs.retention"),
+		MaxJobs:     viper.GetInt("jobs.max_jobs"),
+		MaxOutput:   viper.GetInt("jobs.max_output"),
+		SoftTimeout: viper.GetInt("jobs.soft_timeout"),
+	}
+	cfg.Security.AllowedCwdPrefixes = viper.GetStringSlice("security.allowed_cwd_prefixes")
+	cfg.Security.AllowedEnv = viper.GetStringSlice("security.allowed_env")
+	if viper.IsSet("environment.passthrough") {
+		cfg.Environment.Passthrough = viper.GetStringSlice("environment.passthrough")
+	}
+	cfg.Environment.Set = viper.GetStringMapString("environment.set")
+	cfg.Executor = executor.Config{
+		RunAsUser:  viper.GetString("executor.run_as_user"),
+		RunAsGroup: viper.GetString("executor.run_as_group"),
+		Cgroup:     viper.GetString("executor.cgroup"),
+		Limits: executor.Limits{
+			CPUSeconds:   viper.GetUint64("executor.limits.cpu_seconds"),
+			AddressSpace: viper.GetUint64("executor.limits.address_space"),
+			OpenFiles:    viper.GetUint64("executor.limits.open_files"),
+			Processes:    viper.GetUint64("executor.limits.processes"),
+			FileSize:     viper.GetUint64("executor.limits.file_size"),
+			Memory:       viper.GetUint64("executor.limits.memory"),
+			CPUPercent:   viper.GetUint64("executor.limits.cpu_percent"),
+		},
+		Sandbox: executor.SandboxConfig{
+			Enabled:       viper.GetBool("executor.sandbox.enabled"),
+			Network:       viper.GetBool("executor.sandbox.network"),
+			WritablePaths: viper.GetStringSlice("executor.sandbox.writable_paths"),
+		},
+		MaxOutput: viper.GetInt("executor.max_output"),
+		Normalize: executor.NormalizeOptions{
+			StripANSI:  viper.GetBool("executor.normalize.strip_ansi"),
+			CollapseCR: viper.GetBool("executor.normalize.collapse_cr"),
+		},
+	}
+	cfg.Artifacts = artifacts.Config{
+		Dir:       viper.GetString("artifacts.dir"),
+		Retention: viper.GetInt("artifacts.retention"),
+		MaxSize:   viper.GetInt64("artifacts.max_size"),
+	}
+	cfg.Sessions = sessions.Config{
+		IdleTimeout:     viper.GetInt("sessions.idle_timeout"),
+		MaxPerPrincipal: viper.GetInt("sessions.max_per_principal"),
+		MaxOutput:       viper.GetInt("sessions.max_output"),
+	}
+
 	return cfg, nil
 }
 
 // internalExecHandler 处理内部执行请求 (Server -> Server)
-func internalExecHandler(guard *security.Guard, executor *executor.Executor, clusterToken string) http.HandlerFunc {
+// Token 校验由 requireToken 负责
+func internalExecHandler(guard *security.Guard, exec *executor.Executor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger.Debugf("收到 /internal/exec 请求，方法: %s, 远程地址: %s", r.Method, r.RemoteAddr)
 
@@ -247,17 +493,6 @@ func internalExecHandler(guard *security.Guard, executor *executor.Executor, clu
 			return
 		}
 
-		// Token 验证
-		if clusterToken != "" {
-			token := r.Header.Get("X-Cluster-Token")
-			if token != clusterToken {
-				logger.Warnf("Cluster Token 校验失败, remote=%s, token=%s", r.RemoteAddr, token)
-				http.Error(w, "Unauthorized: invalid cluster token", http.StatusUnauthorized)
-				return
-			}
-			logger.Debugf("Cluster Token 校验通过")
-		}
-
 		var req dispatch.DispatchRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			logger.Errorf("Failed to decode request: %v", err)
@@ -267,18 +502,41 @@ func internalExecHandler(guard *security.Guard, executor *executor.Executor, clu
 
 		logger.Infof("收到内部执行请求，命令: %s", req.Cmd)
 
+		// 如果 Coordinator 携带了 traceparent，本节点的 Span 会加入同一个 Trace
+		ctx, span := tracing.Start(r.Context(), "internal.exec", tracing.KindServer)
+		span.SetAttribute("exec.command", req.Cmd)
+		defer span.End()
+
 		// 安全检查
 		logger.Debugf("开始安全检查")
-		if err := guard.CheckCommand(req.Cmd); err != nil {
+		if err := checkCommand(ctx, guard, req.Cmd, req.Options); err != nil {
+			span.RecordError(err)
 			logger.Warnf("安全检查失败，命令被拦截: %s, 错误: %v", req.Cmd, err)
 			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
 		logger.Debugf("安全检查通过")
+		req.Options = withRule(guard, req.Cmd, req.Options)
 
 		// 执行
-		logger.Debugf("开始执行命令，超时: 5s")
-		result, err := executor.Execute(req.Cmd, 5*time.Second)
+		timeout := dispatch.ClampTimeout(time.Duration(req.Timeout) * time.Second)
+		logger.Debugf("开始执行命令，超时: %s", timeout)
+		_, execSpan := tracing.Start(ctx, "exec.local", tracing.KindInternal)
+		result, err := exec.Execute(req.Cmd, timeout, req.Options)
+		if err != nil {
+			execSpan.RecordError(err)
+		} else {
+			execSpan.SetAttribute("exec.exit_code", result.ExitCode)
+			if result.ExitCode != 0 {
+				execSpan.SetStatus(tracing.StatusError, result.Error)
+			}
+		}
+		execSpan.End()
+		if errors.Is(err, executor.ErrShuttingDown) {
+			logger.Warnf("节点正在关闭，拒绝执行命令: %s", req.Cmd)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		if err != nil {
 			logger.Errorf("命令执行失败: %v", err)
 			// 即使有错误，也返回部分结果
@@ -294,7 +552,7 @@ func internalExecHandler(guard *security.Guard, executor *executor.Executor, clu
 }
 
 // internalJoinHandler 处理节点加入请求
-func internalJoinHandler(cfg *config.ServerConfig, configPath string) http.HandlerFunc {
+func internalJoinHandler(members *cluster.Membership) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger.Debugf("收到 /internal/join 请求，方法: %s, 远程地址: %s", r.Method, r.RemoteAddr)
 
@@ -304,37 +562,44 @@ func internalJoinHandler(cfg *config.ServerConfig, configPath string) http.Handl
 			return
 		}
 
-		var req struct {
-			MyAddr string `json:"my_addr"`
-		}
+		var req cluster.JoinRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			logger.Errorf("Failed to decode join request: %v", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		logger.Infof("收到节点加入请求，地址: %s", req.MyAddr)
+		// 兼容旧版本只携带 my_addr 的请求
+		if req.URL == "" {
+			req.URL = req.MyAddr
+		}
+		if req.URL == "" {
+			logger.Warnf("节点加入请求缺少地址, remote=%s", r.RemoteAddr)
+			http.Error(w, "my_addr is required", http.StatusBadRequest)
+			return
+		}
+
+		logger.Infof("收到节点加入请求，地址: %s, 节点: %s", req.URL, req.Name)
 
 		// 添加新节点
-		logger.Debugf("添加新节点到 peers 列表")
-		cfg.AddPeer(req.MyAddr)
-		logger.Infof("当前 peers 数量: %d", len(cfg.GetPeers()))
+		members.Join(req.NodeInfo)
+		logger.Infof("当前 peers 数量: %d", len(members.Peers()))
 
 		// 广播给其他节点 (异步)
 		logger.Debugf("开始广播同步到其他节点")
-		go broadcastSync(cfg, configPath)
+		go members.Broadcast(context.Background())
 
-		// 返回当前所有节点
+		// 返回当前所有节点（含本节点）
 		logger.Debugf("返回当前所有 peers")
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"peers": cfg.GetPeers(),
+		json.NewEncoder(w).Encode(cluster.JoinResponse{
+			Peers: append(members.Peers(), members.Self().URL),
 		})
 	}
 }
 
 // internalSyncHandler 处理同步节点列表请求
-func internalSyncHandler(cfg *config.ServerConfig, configPath string) http.HandlerFunc {
+func internalSyncHandler(members *cluster.Membership) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger.Debugf("收到 /internal/sync 请求，方法: %s, 远程地址: %s", r.Method, r.RemoteAddr)
 
@@ -344,9 +609,7 @@ func internalSyncHandler(cfg *config.ServerConfig, configPath string) http.Handl
 			return
 		}
 
-		var req struct {
-			Peers []string `json:"peers"`
-		}
+		var req cluster.SyncRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			logger.Errorf("Failed to decode sync request: %v", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -355,27 +618,50 @@ func internalSyncHandler(cfg *config.ServerConfig, configPath string) http.Handl
 
 		logger.Infof("收到同步请求，peers: %v", req.Peers)
 
-		// 更新本地 Peers
-		logger.Debugf("更新本地 peers 列表")
-		cfg.SetPeers(req.Peers)
-		logger.Infof("本地 peers 更新完成，数量: %d", len(cfg.GetPeers()))
+		// 更新本地 Peers（持久化由 OnChange 回调完成）
+		members.SetPeers(req.Peers)
+		logger.Infof("本地 peers 更新完成，数量: %d", len(members.Peers()))
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// internalLeaveHandler 处理节点离开请求
+func internalLeaveHandler(members *cluster.Membership) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger.Debugf("收到 /internal/leave 请求，方法: %s, 远程地址: %s", r.Method, r.RemoteAddr)
+
+		if r.Method != http.MethodPost {
+			logger.Warnf("Invalid method for /internal/leave: %s", r.Method)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req cluster.LeaveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			logger.Errorf("Failed to decode leave request: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		// 持久化
-		// 注意：这里需要知道配置文件路径，简化起见暂时跳过
-		// cfg.Save(configPath)
-		logger.Debugf("持久化配置（当前跳过）")
+		logger.Infof("收到节点离开请求，地址: %s", req.MyAddr)
+		members.Leave(req.MyAddr)
 
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-// broadcastSync 将当前的 Peer 列表广播给所有已知节点
-func broadcastSync(cfg *config.ServerConfig, configPath string) {
-	peers := cfg.GetPeers()
-	logger.Infof("开始广播同步到 %d 个 peers", len(peers))
-	// 这里应该使用 HTTP Client 发送 POST /internal/sync
-	// 简化实现，略
-	logger.Debugf("广播同步完成（当前为简化实现）")
+// internalInfoHandler 返回本节点信息，供其他节点探测
+func internalInfoHandler(members *cluster.Membership) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(members.Self())
+	}
 }
 
 // buildTLSConfig 构建 TLS 配置
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/config"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/dispatch"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/security"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/sessions"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// registerSessionTools 注册 Shell 会话相关的 MCP Tools
+func registerSessionTools(mcpServer *mcp.Server, guard *security.Guard, localExecutor *executor.Executor, members *cluster.Membership, coordinator *sessions.Coordinator, cfg *config.ServerConfig) {
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "open_session",
+		Description: "Open a persistent shell session that keeps the working directory, exported variables and activated environments across session_exec calls",
+	}, handleOpenSession(members, coordinator))
+
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "session_exec",
+		Description: "Run a shell command in an open session on every node of the session and return the aggregated output and exit codes",
+	}, handleSessionExec(guard, localExecutor, coordinator, cfg))
+
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "close_session",
+		Description: "Close a shell session and kill its processes on all nodes",
+	}, handleCloseSession(coordinator))
+}
+
+// OpenSessionInput open_session tool 的输入参数
+type OpenSessionInput struct {
+	Targets []string `json:"targets,omitempty" jsonschema:"node names or URLs to open the session on, glob patterns such as web-* are allowed; empty means this node only, use [\"*\"] for all nodes"`
+}
+
+// SessionExecInput session_exec tool 的输入参数
+type SessionExecInput struct {
+	ID      string `json:"id" jsonschema:"session ID returned by open_session"`
+	Command string `json:"command" jsonschema:"shell command to run in the session; its stdin is /dev/null"`
+	Timeout int    `json:"timeout,omitempty" jsonschema:"execution timeout in seconds, default 5, max 3600; the session is closed on nodes where the command times out"`
+}
+
+// SessionIDInput close_session tool 的输入参数
+type SessionIDInput struct {
+	ID string `json:"id" jsonschema:"session ID returned by open_session"`
+}
+
+// SessionExecOutput session_exec tool 的结构化输出
+type SessionExecOutput struct {
+	Summary string                     `json:"summary"`
+	Groups  []dispatch.AggregatedGroup `json:"groups" jsonschema:"results grouped by identical status, exit code and output; stdout and stderr are merged in output"`
+	Closed  []string                   `json:"closed,omitempty" jsonschema:"nodes on which the session ended with this command (timeout, exit or lost), later commands will not run there"`
+}
+
+// errNoPrincipal 通过 HTTP 调用会话 tools 但请求无法识别调用方
+var errNoPrincipal = errors.New("sessions require an identifiable caller: authenticate the request or send an Authorization or X-Cluster-Token header")
+
+// principalOf 返回调用方的标识，会话只能由打开它的调用方使用
+// 优先使用认证得到的用户，其次使用请求携带 Token 的摘要（不记录 Token 本身）；
+// stdio 模式的请求没有 HTTP 信息，唯一的调用方是启动服务器的进程，标识为 local。
+// HTTP 请求无法识别调用方时返回 errNoPrincipal，避免所有匿名调用方共享同一组会话
+func principalOf(req *mcp.CallToolRequest) (string, error) {
+	if req == nil || req.Extra == nil {
+		return "local", nil
+	}
+	if info := req.Extra.TokenInfo; info != nil && info.UserID != "" {
+		return "user:" + info.UserID, nil
+	}
+	for _, name := range []string{"Authorization", "X-Cluster-Token"} {
+		if v := req.Extra.Header.Get(name); v != "" {
+			sum := sha256.Sum256([]byte(v))
+			return "token:" + hex.EncodeToString(sum[:8]), nil
+		}
+	}
+	return "", errNoPrincipal
+}
+
+// handleOpenSession 处理 open_session tool 的请求
+func handleOpenSession(members *cluster.Membership, coordinator *sessions.Coordinator) mcp.ToolHandlerFor[OpenSessionInput, *sessions.Session] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input OpenSessionInput) (*mcp.CallToolResult, *sessions.Session, error) {
+		logger.Debugf("Received open_session request: %v", input.Targets)
+
+		ctx, span := tracing.Start(ctx, "mcp.tool open_session", tracing.KindServer)
+		span.SetAttribute("mcp.tool.name", "open_session")
+		defer span.End()
+
+		principal, err := principalOf(req)
+		if err != nil {
+			span.RecordError(err)
+			return nil, nil, err
+		}
+
+		// 未指定目标节点时只在本节点打开
+		peers, skipLocal := []string{}, false
+		if len(input.Targets) > 0 {
+			var err error
+			if peers, skipLocal, err = resolveTargets(ctx, members, input.Targets); err != nil {
+				span.RecordError(err)
+				return nil, nil, err
+			}
+		}
+
+		sess, err := coordinator.Open(ctx, principal, peers, skipLocal)
+		if err != nil {
+			span.RecordError(err)
+			return nil, nil, err
+		}
+		span.SetAttribute("session.id", sess.ID)
+		logger.Infof("会话 %s 已在 %d 个节点上打开", sess.ID, len(sess.Nodes))
+		return nil, sess, nil
+	}
+}
+
+// handleSessionExec 处理 session_exec tool 的请求
+func handleSessionExec(guard *security.Guard, localExecutor *executor.Executor, coordinator *sessions.Coordinator, cfg *config.ServerConfig) mcp.ToolHandlerFor[SessionExecInput, SessionExecOutput] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SessionExecInput) (*mcp.CallToolResult, SessionExecOutput, error) {
+		logger.Debugf("Received session_exec request: %s, %s", input.ID, input.Command)
+
+		ctx, span := tracing.Start(ctx, "mcp.tool session_exec", tracing.KindServer)
+		span.SetAttribute("mcp.tool.name", "session_exec")
+		span.SetAttribute("session.id", input.ID)
+		span.SetAttribute("exec.command", input.Command)
+		defer span.End()
+
+		principal, err := principalOf(req)
+		if err != nil {
+			span.RecordError(err)
+			return nil, SessionExecOutput{}, err
+		}
+		if err := checkCommand(ctx, guard, input.Command, executor.Options{}); err != nil {
+			span.RecordError(err)
+			logger.Warnf("Security violation for session command: %s, error: %v", input.Command, err)
+			return nil, SessionExecOutput{}, fmt.Errorf("security violation: %v", err)
+		}
+		if err := checkSessionRule(guard, localExecutor, input.Command); err != nil {
+			span.RecordError(err)
+			logger.Warnf("Session command rejected: %s, error: %v", input.Command, err)
+			return nil, SessionExecOutput{}, err
+		}
+
+		timeout := dispatch.ClampTimeout(time.Duration(input.Timeout) * time.Second)
+		nodes, err := coordinator.Exec(ctx, input.ID, principal, input.Command, timeout)
+		if err != nil {
+			span.RecordError(err)
+			return nil, SessionExecOutput{}, err
+		}
+
+		var out SessionExecOutput
+		results := make([]dispatch.NodeResult, 0, len(nodes))
+		for _, n := range nodes {
+			results = append(results, sessionNodeResult(n))
+			if n.Result.Closed || errors.Is(n.Err, sessions.ErrNotFound) {
+				out.Closed = append(out.Closed, n.NodeName)
+			}
+		}
+		out.Groups = dispatch.Aggregate(results)
+		out.Summary = capResponse(out.Groups, dispatch.Summarize(results, out.Groups), cfg)
+		if len(out.Closed) > 0 {
+			out.Summary += fmt.Sprintf("; session closed on %d nodes", len(out.Closed))
+		}
+		span.SetAttribute("dispatch.groups", len(out.Groups))
+		logger.Infof("会话 %s 命令执行完成: %s", input.ID, out.Summary)
+		return nil, out, nil
+	}
+}
+
+// sessionNodeResult 将会话命令在单个节点上的结果转换为与 execute_command 相同的节点结果
+func sessionNodeResult(n sessions.NodeExec) dispatch.NodeResult {
+	if n.Err != nil {
+		return dispatch.NodeResult{
+			NodeName: n.NodeName,
+			Status:   sessions.ExecFailed,
+			ExitCode: dispatch.NoExitCode,
+			Error:    n.Err.Error(),
+		}
+	}
+	return dispatch.NodeResult{
+		NodeName:  n.NodeName,
+		Status:    n.Result.Status,
+		ExitCode:  n.Result.ExitCode,
+		Output:    n.Result.Output,
+		Error:     n.Result.Error,
+		Encoding:  n.Result.Encoding,
+		Truncated: n.Result.Truncated,
+		Duration:  time.Duration(n.Result.DurationMs) * time.Millisecond,
+	}
+}
+
+// handleCloseSession 处理 close_session tool 的请求
+func handleCloseSession(coordinator *sessions.Coordinator) mcp.ToolHandlerFor[SessionIDInput, *sessions.Session] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input SessionIDInput) (*mcp.CallToolResult, *sessions.Session, error) {
+		logger.Debugf("Received close_session request: %s", input.ID)
+		principal, err := principalOf(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		sess, err := coordinator.Close(ctx, input.ID, principal)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Infof("会话 %s 已关闭", sess.ID)
+		return nil, sess, nil
+	}
+}
+
+// registerSessionHandlers 注册 Peer 之间的会话 API，Token 校验与其他内部 API 相同
+// 打开会话和执行命令经过 gate，关闭流程开始后拒绝；关闭会话在关闭期间仍然可用
+func registerSessionHandlers(mux *http.ServeMux, gate *drainGate, guard *security.Guard, localExecutor *executor.Executor, manager *sessions.Manager, token string) {
+	mux.Handle("POST /internal/sessions", gate.Wrap(tracing.Middleware(requireToken("X-Cluster-Token", token, internalOpenSessionHandler(manager)))))
+	mux.Handle("POST /internal/sessions/{id}/exec", gate.Wrap(tracing.Middleware(requireToken("X-Cluster-Token", token, internalSessionExecHandler(guard, localExecutor, manager)))))
+	mux.HandleFunc("POST /internal/sessions/{id}/close", requireToken("X-Cluster-Token", token, internalCloseSessionHandler(manager)))
+}
+
+// internalOpenSessionHandler 处理 POST /internal/sessions，在本节点打开会话
+func internalOpenSessionHandler(manager *sessions.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req sessions.OpenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.ID == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		logger.Infof("收到打开会话请求，id: %s, principal: %s", req.ID, req.Principal)
+
+		info, err := manager.Open(req.ID, req.Principal)
+		if err != nil {
+			logger.Warnf("打开会话失败: %v", err)
+			switch {
+			case errors.Is(err, executor.ErrShuttingDown):
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			case errors.Is(err, sessions.ErrTooManySessions):
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+			default:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		writeJSON(w, info)
+	}
+}
+
+// internalSessionExecHandler 处理 POST /internal/sessions/{id}/exec，在本节点的会话中执行命令
+func internalSessionExecHandler(guard *security.Guard, localExecutor *executor.Executor, manager *sessions.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req sessions.ExecRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id := r.PathValue("id")
+		logger.Infof("收到会话执行请求，id: %s, 命令: %s", id, req.Cmd)
+
+		ctx, span := tracing.Start(r.Context(), "internal.sessions.exec", tracing.KindServer)
+		span.SetAttribute("exec.command", req.Cmd)
+		span.SetAttribute("session.id", id)
+		defer span.End()
+
+		if err := checkCommand(ctx, guard, req.Cmd, executor.Options{}); err != nil {
+			span.RecordError(err)
+			logger.Warnf("安全检查失败，会话命令被拦截: %s, 错误: %v", req.Cmd, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := checkSessionRule(guard, localExecutor, req.Cmd); err != nil {
+			span.RecordError(err)
+			logger.Warnf("会话命令被拒绝: %s, 错误: %v", req.Cmd, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		res, err := manager.Exec(id, req.Principal, req.Cmd, dispatch.ClampTimeout(time.Duration(req.Timeout)*time.Second))
+		if err != nil {
+			span.RecordError(err)
+			switch {
+			case errors.Is(err, sessions.ErrBusy):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, err.Error(), http.StatusNotFound)
+			}
+			return
+		}
+		writeJSON(w, res)
+	}
+}
+
+// internalCloseSessionHandler 处理 POST /internal/sessions/{id}/close
+func internalCloseSessionHandler(manager *sessions.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req sessions.CloseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Infof("收到关闭会话请求，id: %s", r.PathValue("id"))
+		info, err := manager.Close(r.PathValue("id"), req.Principal)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, info)
+	}
+}
+
+// checkSessionRule 会话中的命令在已启动的 Shell 中执行，无法再按策略规则放入沙箱或设置资源限制；
+// 匹配 sandbox 规则的命令只能在所有命令都在沙箱中运行的节点上通过会话执行，
+// 匹配设置了 limits 的规则的命令不能通过会话执行
+func checkSessionRule(guard *security.Guard, localExecutor *executor.Executor, cmd string) error {
+	rule := guard.MatchRule(cmd)
+	if rule == nil {
+		return nil
+	}
+	if rule.Action == security.ActionSandbox && !localExecutor.AlwaysSandboxed() {
+		return fmt.Errorf("command matches sandbox rule '%s' and cannot run in a session", rule.Name)
+	}
+	if rule.Limits != (executor.Limits{}) {
+		return fmt.Errorf("command matches rule '%s' with resource limits and cannot run in a session", rule.Name)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/security"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TestCheckSessionRule 验证会话拒绝匹配 sandbox 规则和设置了 limits 的规则的命令
+func TestCheckSessionRule(t *testing.T) {
+	guard, err := security.NewGuard(nil, nil)
+	if err != nil {
+		t.Fatalf("创建安全卫士失败: %v", err)
+	}
+	err = guard.SetRules([]security.Rule{
+		{Name: "untrusted", Match: `^curl\b`, Action: security.ActionSandbox},
+		{Name: "build", Match: `^make\b`, Limits: executor.Limits{CPUSeconds: 600}},
+		{Name: "plain", Match: `^ls\b`},
+	})
+	if err != nil {
+		t.Fatalf("设置规则失败: %v", err)
+	}
+	exec := executor.NewExecutor()
+
+	tests := []struct {
+		cmd     string
+		wantErr string
+	}{
+		{"curl example.com", "sandbox rule 'untrusted'"},
+		{"make   all", "rule 'build' with resource limits"},
+		{"ls -l", ""},
+		{"echo hello", ""},
+	}
+	for _, tt := range tests {
+		err := checkSessionRule(guard, exec, tt.cmd)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("checkSessionRule(%q) = %v, 预期通过", tt.cmd, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("checkSessionRule(%q) = %v, 预期包含 %q", tt.cmd, err, tt.wantErr)
+		}
+	}
+}
+
+// TestPrincipalOf 验证调用方标识：stdio 请求为 local，HTTP 请求按用户或 Token 区分，
+// 无法识别调用方的 HTTP 请求被拒绝
+func TestPrincipalOf(t *testing.T) {
+	withHeader := func(name, value string) *mcp.CallToolRequest {
+		header := http.Header{}
+		if name != "" {
+			header.Set(name, value)
+		}
+		return &mcp.CallToolRequest{Extra: &mcp.RequestExtra{Header: header}}
+	}
+
+	if p, err := principalOf(&mcp.CallToolRequest{}); err != nil || p != "local" {
+		t.Errorf("stdio 请求的调用方 = %q, %v, 预期 local", p, err)
+	}
+	if _, err := principalOf(withHeader("", "")); !errors.Is(err, errNoPrincipal) {
+		t.Errorf("匿名 HTTP 请求 err = %v, 预期 errNoPrincipal", err)
+	}
+
+	a, err := principalOf(withHeader("Authorization", "Bearer a"))
+	if err != nil || !strings.HasPrefix(a, "token:") || strings.Contains(a, "Bearer") {
+		t.Errorf("Authorization 请求的调用方 = %q, %v, 预期 Token 摘要", a, err)
+	}
+	b, _ := principalOf(withHeader("X-Cluster-Token", "b"))
+	if b == a || !strings.HasPrefix(b, "token:") {
+		t.Errorf("不同 Token 的调用方 = %q 和 %q, 预期不同的 Token 摘要", a, b)
+	}
+
+	req := withHeader("Authorization", "Bearer a")
+	req.Extra.TokenInfo = &auth.TokenInfo{UserID: "alice"}
+	if p, _ := principalOf(req); p != "user:alice" {
+		t.Errorf("认证请求的调用方 = %q, 预期 user:alice", p)
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/sessions"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+)
+
+// leaveTimeout 关闭时通知 Peer 离开的最长等待时间
+const leaveTimeout = 5 * time.Second
+
+// drainGate 在进入关闭流程后拒绝新的请求
+type drainGate struct {
+	draining atomic.Bool
+}
+
+// Close 关闭入口，之后经过 Wrap 的 Handler 均返回 503
+func (g *drainGate) Close() {
+	g.draining.Store(true)
+}
+
+// Wrap 包装 Handler，关闭后返回 503 并要求客户端断开连接
+func (g *drainGate) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.draining.Load() {
+			logger.Debugf("服务器正在关闭，拒绝请求: %s %s", r.Method, r.URL.Path)
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// gracefulShutdown 执行优雅关闭流程：
+//  1. 拒绝新的 /mcp 和 /internal/exec 请求
+//  2. 通知所有 Peer 本节点离开（保留本地 Peer 列表以便重启后重新加入）
+//  3. 在 drainTimeout 内等待正在处理的请求（及其执行的命令）和后台作业结束，请求结束后关闭所有 Shell 会话
+//  4. 超时后终止剩余命令的进程组并强制关闭连接
+func gracefulShutdown(srv *http.Server, gate *drainGate, exec *executor.Executor, sessionManager *sessions.Manager, members *cluster.Membership, drainTimeout time.Duration) {
+	logger.Infof("开始优雅关闭，等待正在执行的命令结束（最长 %s），当前执行中: %d", drainTimeout, exec.Running())
+	gate.Close()
+
+	leaveCtx, cancelLeave := context.WithTimeout(context.Background(), leaveTimeout)
+	if err := members.AnnounceLeave(leaveCtx); err != nil {
+		logger.Warnf("部分 peers 未收到离开通知: %v", err)
+	}
+	cancelLeave()
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	// 命令只会在请求处理过程中执行，等待请求处理结束即等待命令执行结束
+	err := srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		if n := exec.KillAll(); n > 0 {
+			logger.Warnf("等待超时，已强制终止 %d 个仍在执行的命令", n)
+		}
+		// 给被终止命令的请求一点时间返回结果，之后强制关闭剩余连接
+		graceCtx, cancelGrace := context.WithTimeout(context.Background(), time.Second)
+		err = srv.Shutdown(graceCtx)
+		cancelGrace()
+	}
+	if err != nil {
+		logger.Warnf("强制关闭剩余连接: %v", err)
+		srv.Close()
+	}
+
+	// 会话中的 Shell 只在请求处理过程中执行命令，请求结束后即可关闭
+	if n := sessionManager.CloseAll(); n > 0 {
+		logger.Infof("已关闭 %d 个 Shell 会话", n)
+	}
+
+	// 拒绝之后可能出现的执行，并确认没有遗留的命令
+	// 后台作业不属于任何请求，在剩余的等待时间内（至少 1 秒）等待其结束
+	deadline, _ := ctx.Deadline()
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), max(time.Until(deadline), time.Second))
+	if err := exec.Drain(stopCtx); err != nil {
+		logger.Warnf("仍有 %d 个命令未结束，强制终止", exec.KillAll())
+	}
+	cancelStop()
+
+	// 导出剩余的链路追踪数据
+	traceCtx, cancelTrace := context.WithTimeout(context.Background(), leaveTimeout)
+	if err := tracing.Shutdown(traceCtx); err != nil {
+		logger.Warnf("导出剩余链路追踪数据失败: %v", err)
+	}
+	cancelTrace()
+
+	logger.Infof("服务器已关闭")
+}
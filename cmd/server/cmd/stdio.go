@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/artifacts"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/config"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/dispatch"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/jobs"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/security"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/sessions"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/version"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// MCP 传输方式
+const (
+	TransportHTTP  = "http"  // Streamable HTTP，支持集群、TLS 和内部 API
+	TransportStdio = "stdio" // 标准输入输出，单机使用，不监听端口也不加入集群
+)
+
+// logConfigFor 返回按传输方式调整后的日志配置
+// stdio 模式下标准输出用于 MCP 协议，输出到标准输出（包括默认值）的控制台日志改为输出到标准错误
+func logConfigFor(transport string, cfg logger.LogConfig) logger.LogConfig {
+	if transport == TransportStdio && (cfg.Console == "" || cfg.Console == logger.ConsoleStdout) {
+		cfg.Console = logger.ConsoleStderr
+	}
+	return cfg
+}
+
+// serveStdio 通过标准输入输出提供 MCP 服务，直到标准输入关闭或收到退出信号
+// 使用与 HTTP 模式相同的安全检查和执行器，但只在本机执行：没有 Peer，忽略端口、TLS、集群和指标配置
+// transport 通常为 mcp.StdioTransport，测试时使用内存管道
+func serveStdio(cfg *config.ServerConfig, guard *security.Guard, exec *executor.Executor, jobManager *jobs.Manager, sessionManager *sessions.Manager, artifactStore *artifacts.Store, transport mcp.Transport) {
+	dispatcher := dispatch.NewDispatcher(nil, "")
+	members := cluster.NewMembership(cluster.NodeInfo{
+		Name:          cfg.NodeName,
+		Version:       version.Version,
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		Labels:        cfg.Labels,
+		PolicyVersion: guard.PolicyVersion(),
+	}, nil, "")
+	jobCoordinator := jobs.NewCoordinator(jobManager, cfg.NodeName, members.Peers, "")
+	sessionCoordinator := sessions.NewCoordinator(sessionManager, cfg.NodeName, members.Peers, "")
+	artifactCoordinator := artifacts.NewCoordinator(artifactStore, cfg.NodeName, "")
+
+	mcpServer := mcp.NewServer(&mcp.Implementation{
+		Name:    "shell-executor-mcp",
+		Version: version.Version,
+	}, nil)
+	registerTools(mcpServer, guard, exec, dispatcher, members, jobCoordinator, sessionCoordinator, artifactCoordinator, cfg)
+	logger.Infof("MCP Tools 注册成功")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	session, err := mcpServer.Connect(ctx, transport, nil)
+	if err != nil {
+		logger.Fatalf("Failed to serve MCP over stdio: %v", err)
+	}
+	logger.Infof("服务器启动完成，通过标准输入输出等待请求...")
+
+	// 收到退出信号时关闭会话，标准输入关闭时会话自行结束
+	go func() {
+		<-ctx.Done()
+		_ = session.Close()
+	}()
+	if err := session.Wait(); err != nil && ctx.Err() == nil {
+		logger.Warnf("MCP 会话异常结束: %v", err)
+	}
+	stop()
+
+	// 关闭所有 Shell 会话，等待仍在执行的命令结束，超时后强制终止
+	sessionManager.CloseAll()
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.GetDrainTimeout())
+	defer cancel()
+	if err := exec.Drain(drainCtx); err != nil {
+		logger.Warnf("等待超时，已强制终止 %d 个仍在执行的命令", exec.KillAll())
+	}
+
+	// 导出剩余的链路追踪数据
+	traceCtx, cancelTrace := context.WithTimeout(context.Background(), leaveTimeout)
+	if err := tracing.Shutdown(traceCtx); err != nil {
+		logger.Warnf("导出剩余链路追踪数据失败: %v", err)
+	}
+	cancelTrace()
+
+	logger.Infof("服务器已关闭")
+}
@@ -0,0 +1,154 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/config"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/jobs"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/security"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/sessions"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// leakedStdout 记录日志初始化时标准输出收到的内容，stdio 模式下应始终为空
+var leakedStdout lockedBuffer
+
+// TestMain 按 stdio 模式的日志配置显式初始化 logger
+// 初始化期间标准输出替换为管道，日志错误地写到标准输出时会被记录在 leakedStdout 中
+func TestMain(m *testing.M) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		panic(err)
+	}
+	go io.Copy(&leakedStdout, r)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	logCfg := logConfigFor(TransportStdio, logger.LogConfig{Level: "debug", LogDir: os.TempDir()})
+	_ = logger.InitLogger(&logCfg, "cmd_test.log")
+	os.Stdout = stdout
+
+	os.Exit(m.Run())
+}
+
+// lockedBuffer 并发安全的 bytes.Buffer
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// recordingWriter 在写入管道的同时记录服务器写到"标准输出"的全部内容
+type recordingWriter struct {
+	io.WriteCloser
+	record *lockedBuffer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.record.Write(p)
+	return w.WriteCloser.Write(p)
+}
+
+// TestServeStdio 通过内存管道启动 stdio 服务器，验证 tools/list 和 execute_command 可用，
+// 且标准输出只包含 JSON-RPC 消息
+func TestServeStdio(t *testing.T) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	var written lockedBuffer
+
+	exec := executor.NewExecutor()
+	guard, err := security.NewGuard(nil, nil)
+	if err != nil {
+		t.Fatalf("创建安全卫士失败: %v", err)
+	}
+	cfg := &config.ServerConfig{NodeName: "stdio-node"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveStdio(cfg, guard, exec, jobs.NewManager(exec, jobs.Config{}), sessions.NewManager(exec, sessions.Config{}), nil,
+			&mcp.IOTransport{Reader: serverIn, Writer: &recordingWriter{WriteCloser: serverOut, record: &written}})
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	client := mcp.NewClient(&mcp.Implementation{Name: "stdio-test", Version: "test"}, nil)
+	session, err := client.Connect(ctx, &mcp.IOTransport{Reader: clientIn, Writer: clientOut}, nil)
+	if err != nil {
+		t.Fatalf("连接 stdio 服务器失败: %v", err)
+	}
+
+	tools, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatalf("tools/list 失败: %v", err)
+	}
+	var names []string
+	for _, tool := range tools.Tools {
+		names = append(names, tool.Name)
+	}
+	if !strings.Contains(","+strings.Join(names, ",")+",", ",execute_command,") {
+		t.Fatalf("Tool 列表 = %v, 预期包含 execute_command", names)
+	}
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "execute_command", Arguments: map[string]any{"command": "echo hello"}})
+	if err != nil || res.IsError {
+		t.Fatalf("execute_command 失败: %v, %+v", err, res)
+	}
+	data, _ := json.Marshal(res.StructuredContent)
+	var out ExecuteCommandOutput
+	if err := json.Unmarshal(data, &out); err != nil || len(out.Groups) != 1 || out.Groups[0].Output != "hello\n" || out.Groups[0].Nodes[0] != "stdio-node" {
+		t.Fatalf("execute_command 结果 = %s, 预期本节点输出 hello", data)
+	}
+
+	// 关闭输入后服务器结束
+	session.Close()
+	clientOut.Close()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("关闭标准输入后 stdio 服务器没有退出")
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(written.String()))
+	scanner.Buffer(nil, 1<<20)
+	lines := 0
+	for scanner.Scan() {
+		var msg struct {
+			JSONRPC string `json:"jsonrpc"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.JSONRPC != "2.0" {
+			t.Fatalf("标准输出包含非 JSON-RPC 内容: %q", scanner.Text())
+		}
+		lines++
+	}
+	if lines == 0 {
+		t.Fatal("标准输出没有 JSON-RPC 响应")
+	}
+	if leaked := leakedStdout.String(); leaked != "" {
+		t.Fatalf("日志写到了标准输出: %q", leaked)
+	}
+}
@@ -3,6 +3,13 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/artifacts"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/cluster"
 
 	"github.com/AceDarkknight/shell-executor-mcp/internal/security"
 
@@ -12,8 +19,14 @@ import (
 
 	"github.com/AceDarkknight/shell-executor-mcp/internal/dispatch"
 
+	"github.com/AceDarkknight/shell-executor-mcp/internal/jobs"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/sessions"
+
 	"github.com/AceDarkknight/shell-executor-mcp/internal/config"
 
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -24,13 +37,39 @@ func registerTools(
 	guard *security.Guard,
 	executor *executor.Executor,
 	dispatcher *dispatch.Dispatcher,
+	members *cluster.Membership,
+	jobCoordinator *jobs.Coordinator,
+	sessionCoordinator *sessions.Coordinator,
+	artifactCoordinator *artifacts.Coordinator,
 	cfg *config.ServerConfig,
 ) {
 	// 注册 execute_command tool
 	mcp.AddTool(mcpServer, &mcp.Tool{
 		Name:        "execute_command",
 		Description: "Execute a shell command on the cluster",
-	}, handleExecuteCommand(guard, executor, dispatcher, cfg))
+	}, handleExecuteCommand(guard, executor, dispatcher, members, jobCoordinator, cfg))
+
+	// 注册只读的集群查询 tools
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "list_nodes",
+		Description: "List the nodes of the cluster as seen by this coordinator, with labels, version, OS, reachability and last-seen time",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true, Title: "List cluster nodes"},
+	}, handleListNodes(members))
+
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "cluster_status",
+		Description: "Report aggregate cluster health, security policy consistency and membership size",
+		Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true, Title: "Cluster status"},
+	}, handleClusterStatus(members))
+
+	// 注册后台作业 tools
+	registerJobTools(mcpServer, guard, members, jobCoordinator)
+
+	// 注册 Shell 会话 tools
+	registerSessionTools(mcpServer, guard, executor, members, sessionCoordinator, cfg)
+
+	// 注册读取被截断命令完整输出的 tool
+	registerArtifactTools(mcpServer, members, artifactCoordinator)
 
 	// 在此处添加更多 tools...
 	// 示例：
@@ -40,49 +79,268 @@ func registerTools(
 	// }, handlerFunc)
 }
 
+// ExecuteCommandInput execute_command tool 的输入参数
+type ExecuteCommandInput struct {
+	Command  string   `json:"command" jsonschema:"shell command to execute"`
+	Timeout  int      `json:"timeout,omitempty" jsonschema:"per-node execution timeout in seconds, default 5, max 3600"`
+	Targets  []string `json:"targets,omitempty" jsonschema:"node names or URLs to run on, glob patterns such as web-* are allowed; empty means all nodes"`
+	Strategy string   `json:"strategy,omitempty" jsonschema:"parallel (default) runs on all target nodes at once; serial runs one node at a time and skips the rest after the first failure"`
+	// SoftTimeout 为 0 时使用 jobs.soft_timeout 配置，负数表示不使用软超时
+	SoftTimeout int               `json:"soft_timeout,omitempty" jsonschema:"seconds to wait before returning the results gathered so far; nodes still running keep going under timeout as a background job whose job_id can be polled with get_job. Must be less than timeout, not supported with the serial strategy. Omitted or 0 uses the server's default; a negative value disables it and waits for all nodes"`
+	Cwd         string            `json:"cwd,omitempty" jsonschema:"absolute working directory on each node, must be under an allowed prefix of the security policy"`
+	Env         map[string]string `json:"env,omitempty" jsonschema:"environment variables merged onto the node's base environment, names must be allowed by the security policy"`
+	Stdin       string            `json:"stdin,omitempty" jsonschema:"data written to the command's standard input; empty means no input"`
+	// Normalize 为空时各节点使用 executor.normalize 配置
+	Normalize *executor.NormalizeOptions `json:"normalize,omitempty" jsonschema:"output clean-up applied on each node before results are grouped; omitted means the node's configured default. Output that is not valid UTF-8 is always returned base64-encoded with encoding set to base64"`
+}
+
+// ExecuteCommandOutput execute_command tool 的结构化输出
+type ExecuteCommandOutput struct {
+	Summary string                     `json:"summary"`
+	Groups  []dispatch.AggregatedGroup `json:"groups"`
+	JobID   string                     `json:"job_id,omitempty" jsonschema:"set when some nodes did not finish within soft_timeout; poll it with get_job for the rest"`
+	Pending []string                   `json:"pending,omitempty" jsonschema:"nodes still running when the result was returned"`
+}
+
 // handleExecuteCommand 处理 execute_command tool 的请求
 func handleExecuteCommand(
 	guard *security.Guard,
-	executor *executor.Executor,
+	localExecutor *executor.Executor,
 	dispatcher *dispatch.Dispatcher,
+	members *cluster.Membership,
+	jobCoordinator *jobs.Coordinator,
 	cfg *config.ServerConfig,
-) func(ctx context.Context, req *mcp.CallToolRequest, input struct {
-	Command string `json:"command"`
-}) (*mcp.CallToolResult, struct {
-	Summary string                     `json:"summary"`
-	Groups  []dispatch.AggregatedGroup `json:"groups"`
-}, error) {
-	return func(ctx context.Context, req *mcp.CallToolRequest, input struct {
-		Command string `json:"command"`
-	}) (*mcp.CallToolResult, struct {
-		Summary string                     `json:"summary"`
-		Groups  []dispatch.AggregatedGroup `json:"groups"`
-	}, error) {
+) mcp.ToolHandlerFor[ExecuteCommandInput, ExecuteCommandOutput] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input ExecuteCommandInput) (*mcp.CallToolResult, ExecuteCommandOutput, error) {
 		logger.Debugf("Received execute_command request: %s", input.Command)
 
+		ctx, span := tracing.Start(ctx, "mcp.tool execute_command", tracing.KindServer)
+		span.SetAttribute("mcp.tool.name", "execute_command")
+		span.SetAttribute("exec.command", input.Command)
+		defer span.End()
+
 		// 1. 安全检查
-		if err := guard.CheckCommand(input.Command); err != nil {
+		execOpts := executor.Options{Dir: input.Cwd, Env: input.Env, Stdin: input.Stdin, Normalize: input.Normalize}
+		if err := checkCommand(ctx, guard, input.Command, execOpts); err != nil {
+			span.RecordError(err)
 			logger.Warnf("Security violation for command: %s, error: %v", input.Command, err)
-			return nil, struct {
-				Summary string                     `json:"summary"`
-				Groups  []dispatch.AggregatedGroup `json:"groups"`
-			}{
+			return nil, ExecuteCommandOutput{
 				Summary: "Security violation",
 				Groups:  []dispatch.AggregatedGroup{},
 			}, fmt.Errorf("security violation: %v", err)
 		}
+		execOpts = withRule(guard, input.Command, execOpts)
 
-		// 2. 分发执行 (本地 + 集群)
+		// 2. 解析执行参数
+		if !dispatch.ValidStrategy(input.Strategy) {
+			return nil, ExecuteCommandOutput{}, fmt.Errorf("invalid strategy %q, expected %s or %s", input.Strategy, dispatch.StrategyParallel, dispatch.StrategySerial)
+		}
+		peers, skipLocal, err := resolveTargets(ctx, members, input.Targets)
+		if err != nil {
+			span.RecordError(err)
+			return nil, ExecuteCommandOutput{}, err
+		}
+		opts := dispatch.Options{
+			Timeout:   time.Duration(input.Timeout) * time.Second,
+			Peers:     peers,
+			SkipLocal: skipLocal,
+			Strategy:  input.Strategy,
+			Exec:      execOpts,
+		}
+
+		// 设置了软超时时以后台作业执行，超过软超时仍未结束的节点留在作业中继续执行
+		softTimeout := time.Duration(input.SoftTimeout) * time.Second
+		if input.SoftTimeout > 0 && input.Strategy == dispatch.StrategySerial {
+			return nil, ExecuteCommandOutput{}, fmt.Errorf("soft_timeout is not supported with the %s strategy", dispatch.StrategySerial)
+		}
+		if input.SoftTimeout == 0 && input.Strategy != dispatch.StrategySerial {
+			softTimeout = cfg.Jobs.GetSoftTimeout()
+		}
+		if softTimeout > 0 && softTimeout < dispatch.ClampTimeout(opts.Timeout) {
+			out, err := executeAsJob(ctx, jobCoordinator, input.Command, opts, softTimeout)
+			if err != nil {
+				span.RecordError(err)
+				return nil, ExecuteCommandOutput{}, err
+			}
+			out.Summary = capResponse(out.Groups, out.Summary, cfg)
+			span.SetAttribute("dispatch.groups", len(out.Groups))
+			if out.JobID != "" {
+				span.SetAttribute("job.id", out.JobID)
+			}
+			return nil, out, nil
+		}
+
+		// 3. 分发执行 (本地 + 集群)
 		logger.Infof("Dispatching command to cluster: %s", input.Command)
-		groups, summary := dispatcher.Dispatch(executor, cfg.NodeName, input.Command)
+		groups, summary := dispatcher.Dispatch(ctx, localExecutor, cfg.NodeName, input.Command, opts)
 		logger.Infof("Command execution completed: %s", summary)
+		summary = capResponse(groups, summary, cfg)
+		span.SetAttribute("dispatch.groups", len(groups))
 
-		return nil, struct {
-			Summary string                     `json:"summary"`
-			Groups  []dispatch.AggregatedGroup `json:"groups"`
-		}{
+		return nil, ExecuteCommandOutput{
 			Summary: summary,
 			Groups:  groups,
 		}, nil
 	}
 }
+
+// capResponse 将结果组的输出和错误总量限制在 max_response 内，有内容被截断时在摘要中说明
+func capResponse(groups []dispatch.AggregatedGroup, summary string, cfg *config.ServerConfig) string {
+	if dispatch.CapGroups(groups, cfg.GetMaxResponse()) {
+		summary += fmt.Sprintf("; output truncated to fit the %d byte response limit", cfg.GetMaxResponse())
+	}
+	return summary
+}
+
+// resolveTargets 将目标节点（名称或 URL，名称支持 path.Match 通配符）解析为参与执行的 Peer 列表
+// targets 为空时返回 nil，表示所有节点；任一目标没有匹配到节点时返回错误
+// 存在尚未获取到名称的成员时先探测一次成员信息
+func resolveTargets(ctx context.Context, members *cluster.Membership, targets []string) (peers []string, skipLocal bool, err error) {
+	if len(targets) == 0 {
+		return nil, false, nil
+	}
+
+	list := members.Members()
+	for _, m := range list {
+		if m.Name == "" && m.State != cluster.StateLeft {
+			probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+			members.Refresh(probeCtx)
+			cancel()
+			list = members.Members()
+			break
+		}
+	}
+
+	matched := make([]bool, len(targets))
+	peers = []string{}
+	skipLocal = true
+	for _, m := range list {
+		if m.State == cluster.StateLeft {
+			continue
+		}
+		selected := false
+		for i, target := range targets {
+			ok, err := path.Match(target, m.Name)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid target pattern %q: %v", target, err)
+			}
+			if (ok && m.Name != "") || strings.TrimRight(target, "/") == m.URL {
+				matched[i] = true
+				selected = true
+			}
+		}
+		switch {
+		case !selected:
+		case m.Self:
+			skipLocal = false
+		default:
+			peers = append(peers, m.URL)
+		}
+	}
+
+	for i, ok := range matched {
+		if !ok {
+			return nil, false, fmt.Errorf("target %q matches no node", targets[i])
+		}
+	}
+	return peers, skipLocal, nil
+}
+
+// checkCommand 执行安全检查（命令及工作目录、环境变量）并记录 security.check Span
+func checkCommand(ctx context.Context, guard *security.Guard, cmd string, opts executor.Options) error {
+	_, span := tracing.Start(ctx, "security.check", tracing.KindInternal)
+	defer span.End()
+
+	err := guard.CheckCommand(cmd)
+	if err == nil {
+		err = guard.CheckExecOptions(opts.Dir, opts.Env)
+	}
+	span.SetAttribute("security.allowed", err == nil)
+	span.RecordError(err)
+	return err
+}
+
+// withRule 按本节点策略中匹配命令的规则设置执行参数（资源限制、是否在沙箱中运行）
+// 规则只影响本节点的执行，Peer 按各自的策略设置
+func withRule(guard *security.Guard, cmd string, opts executor.Options) executor.Options {
+	if rule := guard.MatchRule(cmd); rule != nil {
+		logger.Debugf("命令匹配策略规则 %s: %s", rule.Name, cmd)
+		opts.Limits = rule.Limits
+		opts.Sandbox = rule.Action == security.ActionSandbox
+	}
+	return opts
+}
+
+// probeTimeout 只读集群查询 tool 探测成员时的超时时间
+const probeTimeout = 5 * time.Second
+
+// ListNodesOutput list_nodes tool 的结构化输出
+type ListNodesOutput struct {
+	Nodes []NodeView `json:"nodes" jsonschema:"nodes of the cluster, this node first"`
+}
+
+// NodeView 单个节点在 list_nodes 中的展示信息
+type NodeView struct {
+	Name          string            `json:"name" jsonschema:"node name"`
+	URL           string            `json:"url" jsonschema:"node address"`
+	Labels        map[string]string `json:"labels,omitempty" jsonschema:"node labels"`
+	Version       string            `json:"version" jsonschema:"server version"`
+	OS            string            `json:"os" jsonschema:"operating system and architecture"`
+	PolicyVersion string            `json:"policy_version,omitempty" jsonschema:"security policy fingerprint"`
+	State         string            `json:"state" jsonschema:"alive, unreachable, unknown or left"`
+	Reachable     bool              `json:"reachable" jsonschema:"whether the node answered the latest probe"`
+	LastSeen      string            `json:"last_seen,omitempty" jsonschema:"RFC3339 time of the last successful contact"`
+	LastError     string            `json:"last_error,omitempty" jsonschema:"error of the latest failed probe"`
+	Self          bool              `json:"self,omitempty" jsonschema:"whether this is the coordinator itself"`
+}
+
+// handleListNodes 处理 list_nodes tool 的请求
+func handleListNodes(members *cluster.Membership) mcp.ToolHandlerFor[struct{}, ListNodesOutput] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, ListNodesOutput, error) {
+		logger.Debugf("Received list_nodes request")
+
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		members.Refresh(probeCtx)
+
+		var out ListNodesOutput
+		for _, m := range members.Members() {
+			if m.State == cluster.StateLeft {
+				continue
+			}
+			view := NodeView{
+				Name:          m.Name,
+				URL:           m.URL,
+				Labels:        m.Labels,
+				Version:       m.Version,
+				OS:            m.OS,
+				PolicyVersion: m.PolicyVersion,
+				State:         m.State,
+				Reachable:     m.State == cluster.StateAlive,
+				LastError:     m.LastError,
+				Self:          m.Self,
+			}
+			if !m.LastSeen.IsZero() {
+				view.LastSeen = m.LastSeen.UTC().Format(time.RFC3339)
+			}
+			out.Nodes = append(out.Nodes, view)
+		}
+		logger.Infof("list_nodes 返回 %d 个节点", len(out.Nodes))
+		return nil, out, nil
+	}
+}
+
+// handleClusterStatus 处理 cluster_status tool 的请求
+func handleClusterStatus(members *cluster.Membership) mcp.ToolHandlerFor[struct{}, cluster.Status] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, cluster.Status, error) {
+		logger.Debugf("Received cluster_status request")
+
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		members.Refresh(probeCtx)
+
+		status := cluster.Summarize(members.Members())
+		logger.Infof("cluster_status: health=%s, size=%d, policy_consistent=%v", status.Health, status.MembershipSize, status.PolicyConsistent)
+		return nil, status, nil
+	}
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"github.com/AceDarkknight/shell-executor-mcp/cmd/server/cmd"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
 )
 
 func main() {
+	// 以设置资源限制的辅助进程启动时，设置限制后直接执行命令，不会返回
+	executor.InitHelper()
 	cmd.Execute()
 }
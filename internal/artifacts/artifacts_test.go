@@ -0,0 +1,154 @@
+//go:build !windows
+
+package artifacts
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// TestMain 先显式初始化 logger，避免懒加载初始化时的死锁
+func TestMain(m *testing.M) {
+	_ = logger.InitLogger(&logger.LogConfig{Level: "error", LogDir: os.TempDir()}, "artifacts_test.log")
+	os.Exit(m.Run())
+}
+
+// newArtifact 创建制品并写入标准输出，keep 决定是否保留
+func newArtifact(t *testing.T, s *Store, stdout string, keep bool) string {
+	t.Helper()
+	a, err := s.Create()
+	if err != nil {
+		t.Fatalf("创建制品失败: %v", err)
+	}
+	a.Stdout().Write([]byte(stdout))
+	if err := a.Close(keep); err != nil {
+		t.Fatalf("关闭制品失败: %v", err)
+	}
+	return a.ID()
+}
+
+// TestStoreReadChunks 验证分段读取不会在 UTF-8 字符中间截断，超过 MaxSize 的部分被丢弃
+func TestStoreReadChunks(t *testing.T) {
+	s, err := NewStore(Config{Dir: t.TempDir(), MaxSize: 10})
+	if err != nil {
+		t.Fatalf("创建制品存储失败: %v", err)
+	}
+	id := newArtifact(t, s, "ab中文cdefgh", true)
+
+	chunk, err := s.Read(id, StreamStdout, 0, 4)
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if chunk.Data != "ab" || chunk.NextOffset != 2 || chunk.EOF || chunk.Complete || chunk.Size != 10 {
+		t.Fatalf("第一段 = %+v", chunk)
+	}
+	chunk, err = s.Read(id, StreamStdout, chunk.NextOffset, 100)
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if chunk.Data != "中文cd" || !chunk.EOF {
+		t.Errorf("第二段 = %+v", chunk)
+	}
+
+	if _, err := s.Read(id, "log", 0, 0); err == nil {
+		t.Error("无效的输出流应返回错误")
+	}
+	for _, bad := range []string{"../" + id, "0123456789abcdef"} {
+		if _, err := s.Read(bad, StreamStdout, 0, 0); err != ErrNotFound {
+			t.Errorf("Read(%q) 错误 = %v, 预期 ErrNotFound", bad, err)
+		}
+	}
+}
+
+// TestStoreReadBinary 验证不是有效 UTF-8 的数据不按字符边界调整，以 base64 返回
+func TestStoreReadBinary(t *testing.T) {
+	s, err := NewStore(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("创建制品存储失败: %v", err)
+	}
+	id := newArtifact(t, s, "\x80\xff\x00bin", true)
+
+	chunk, err := s.Read(id, StreamStdout, 1, 2)
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if chunk.Encoding != EncodingBase64 || chunk.Data != base64.StdEncoding.EncodeToString([]byte("\xff\x00")) ||
+		chunk.Offset != 1 || chunk.NextOffset != 3 {
+		t.Errorf("二进制数据 = %+v", chunk)
+	}
+	chunk, err = s.Read(id, StreamStdout, chunk.NextOffset, 0)
+	if err != nil || chunk.Encoding != "" || chunk.Data != "bin" || !chunk.EOF {
+		t.Errorf("文本数据 = %+v, %v", chunk, err)
+	}
+}
+
+// TestStoreDiscard 验证不保留的制品和上次运行中未完成的制品被删除
+func TestStoreDiscard(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(Config{Dir: dir})
+	if err != nil {
+		t.Fatalf("创建制品存储失败: %v", err)
+	}
+	id := newArtifact(t, s, "discarded", false)
+	if _, err := s.Read(id, StreamStdout, 0, 0); err != ErrNotFound {
+		t.Errorf("不保留的制品读取错误 = %v, 预期 ErrNotFound", err)
+	}
+
+	a, err := s.Create()
+	if err != nil {
+		t.Fatalf("创建制品失败: %v", err)
+	}
+	if _, err := NewStore(Config{Dir: dir}); err != nil {
+		t.Fatalf("重新创建制品存储失败: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, a.ID())); !os.IsNotExist(err) {
+		t.Errorf("未完成的制品应被删除: %v", err)
+	}
+}
+
+// TestCoordinatorReadsPeer 验证 Coordinator 通过内部 API 读取 Peer 上的制品
+func TestCoordinatorReadsPeer(t *testing.T) {
+	s, err := NewStore(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("创建制品存储失败: %v", err)
+	}
+	id := newArtifact(t, s, "peer output", true)
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Cluster-Token") != "secret" || !strings.HasPrefix(r.URL.Path, "/internal/artifacts/") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		chunk, err := s.Read(strings.TrimPrefix(r.URL.Path, "/internal/artifacts/"), r.URL.Query().Get("stream"), 0, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(chunk)
+	}))
+	defer peer.Close()
+
+	c := NewCoordinator(nil, "local", "secret")
+	chunk, err := c.Read(context.Background(), peer.URL, id, "", 0, 0)
+	if err != nil {
+		t.Fatalf("读取 Peer 制品失败: %v", err)
+	}
+	if chunk.Data != "peer output" || chunk.NodeName != peer.URL {
+		t.Errorf("Peer 制品 = %+v", chunk)
+	}
+	if _, err := c.Read(context.Background(), peer.URL, "0123456789abcdef", "", 0, 0); err != ErrNotFound {
+		t.Errorf("不存在的制品错误 = %v, 预期 ErrNotFound", err)
+	}
+	if _, err := c.Read(context.Background(), "", id, "", 0, 0); err != ErrDisabled {
+		t.Errorf("本节点未启用制品时错误 = %v, 预期 ErrDisabled", err)
+	}
+}
@@ -0,0 +1,91 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+)
+
+// peerRequestTimeout 向 Peer 读取制品的超时时间
+const peerRequestTimeout = 10 * time.Second
+
+// Coordinator 读取本节点或 Peer 上的制品
+// 制品只保存在执行命令的节点上，读取时需指定节点
+type Coordinator struct {
+	local      *Store
+	nodeName   string
+	token      string
+	httpClient *http.Client
+}
+
+// NewCoordinator 创建制品协调器
+// local: 本节点的制品存储，为 nil 表示本节点没有启用制品
+// nodeName: 本节点名称
+// token: 集群内部通信 Token
+func NewCoordinator(local *Store, nodeName string, token string) *Coordinator {
+	return &Coordinator{
+		local:      local,
+		nodeName:   nodeName,
+		token:      token,
+		httpClient: &http.Client{},
+	}
+}
+
+// Read 读取节点上制品 id 中 stream 从 offset 开始最多 length 字节的输出
+// node 为空表示本节点，否则为 Peer 地址
+func (c *Coordinator) Read(ctx context.Context, node, id, stream string, offset int64, length int) (*Chunk, error) {
+	if node == "" {
+		if c.local == nil {
+			return nil, ErrDisabled
+		}
+		chunk, err := c.local.Read(id, stream, offset, length)
+		if err != nil {
+			return nil, err
+		}
+		chunk.NodeName = c.nodeName
+		return chunk, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, peerRequestTimeout)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("stream", stream)
+	query.Set("offset", fmt.Sprint(offset))
+	query.Set("length", fmt.Sprint(length))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/internal/artifacts/%s?%s", node, url.PathEscape(id), query.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %v", err)
+	}
+	tracing.Inject(ctx, req.Header)
+	if c.token != "" {
+		req.Header.Set("X-Cluster-Token", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	var chunk Chunk
+	if err := json.NewDecoder(resp.Body).Decode(&chunk); err != nil {
+		return nil, fmt.Errorf("decode response failed: %v", err)
+	}
+	chunk.NodeName = node
+	return &chunk, nil
+}
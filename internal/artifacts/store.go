@@ -0,0 +1,364 @@
+package artifacts
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+const (
+	DefaultRetention  = time.Hour // 制品保留的时间
+	DefaultMaxSize    = 64 << 20  // 每个输出流保存的最大字节数
+	DefaultReadLength = 64 << 10  // 每次读取默认返回的字节数
+	MaxReadLength     = 1 << 20   // 每次读取最多返回的字节数
+)
+
+// 制品中可读取的输出流
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// EncodingBase64 Chunk.Encoding 的取值：读取的数据不是有效的 UTF-8，Data 为原始字节的 base64 编码
+// 与 executor.EncodingBase64 相同
+const EncodingBase64 = "base64"
+
+// metaFile 制品目录中记录元数据的文件，命令结束并决定保留制品后才写入
+const metaFile = "meta.json"
+
+var (
+	// ErrNotFound 制品不存在、仍在写入或已被清理
+	ErrNotFound = errors.New("artifact not found")
+	// ErrDisabled 节点没有配置制品目录
+	ErrDisabled = errors.New("artifacts are not enabled on this node")
+)
+
+// Config 制品配置
+type Config struct {
+	Dir       string `json:"dir"`       // 保存完整输出的目录，为空表示不保存
+	Retention int    `json:"retention"` // 制品保留的秒数，默认 3600
+	MaxSize   int64  `json:"max_size"`  // 每个输出流保存的最大字节数，超出部分丢弃，默认 64 MiB
+}
+
+// retention 返回制品保留的时间
+func (c Config) retention() time.Duration {
+	if c.Retention <= 0 {
+		return DefaultRetention
+	}
+	return time.Duration(c.Retention) * time.Second
+}
+
+// maxSize 返回每个输出流保存的最大字节数
+func (c Config) maxSize() int64 {
+	if c.MaxSize <= 0 {
+		return DefaultMaxSize
+	}
+	return c.MaxSize
+}
+
+// Store 在本节点的目录中保存命令的完整输出
+// 每次执行在 Dir 下创建以制品 ID 命名的子目录，执行器只在输出被截断时保留；
+// 制品保留 Retention 秒，之后在下一次创建或读取时清理
+type Store struct {
+	cfg Config
+	mu  sync.Mutex // 串行化清理
+}
+
+// NewStore 创建制品存储，目录不存在时创建
+// 上次运行中未完成的制品（服务器在命令结束前退出）会被删除
+func NewStore(cfg Config) (*Store, error) {
+	if !filepath.IsAbs(cfg.Dir) {
+		return nil, fmt.Errorf("artifacts dir %q is not an absolute path", cfg.Dir)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create artifacts dir: %v", err)
+	}
+	s := &Store{cfg: cfg}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("read artifacts dir: %v", err)
+	}
+	for _, entry := range entries {
+		if !validID(entry.Name()) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cfg.Dir, entry.Name(), metaFile)); errors.Is(err, os.ErrNotExist) {
+			logger.Debugf("删除未完成的制品: id=%s", entry.Name())
+			os.RemoveAll(filepath.Join(cfg.Dir, entry.Name()))
+		}
+	}
+	return s, nil
+}
+
+// newID 生成随机的制品 ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validID 检查 ID 是否为 newID 生成的格式，避免读取制品目录之外的路径
+func validID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// Create 创建新的制品，调用方写入输出后需调用 Close
+func (s *Store) Create() (*Artifact, error) {
+	s.prune(time.Now())
+
+	id := newID()
+	dir := filepath.Join(s.cfg.Dir, id)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create artifact: %v", err)
+	}
+	a := &Artifact{id: id, dir: dir}
+	var err error
+	if a.stdout, err = s.openStream(dir, StreamStdout); err == nil {
+		a.stderr, err = s.openStream(dir, StreamStderr)
+	}
+	if err != nil {
+		a.Close(false)
+		return nil, fmt.Errorf("create artifact: %v", err)
+	}
+	return a, nil
+}
+
+// openStream 创建制品目录中输出流的文件
+func (s *Store) openStream(dir, stream string) (*spillFile, error) {
+	f, err := os.OpenFile(filepath.Join(dir, stream), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &spillFile{f: f, limit: s.cfg.maxSize()}, nil
+}
+
+// prune 删除超过保留时间的制品
+func (s *Store) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		logger.Warnf("读取制品目录失败: %v", err)
+		return
+	}
+	retention := s.cfg.retention()
+	for _, entry := range entries {
+		if !validID(entry.Name()) {
+			continue
+		}
+		// 仍在写入的制品没有元数据文件，不清理
+		info, err := os.Stat(filepath.Join(s.cfg.Dir, entry.Name(), metaFile))
+		if err != nil || now.Sub(info.ModTime()) <= retention {
+			continue
+		}
+		logger.Debugf("清理过期制品: id=%s", entry.Name())
+		os.RemoveAll(filepath.Join(s.cfg.Dir, entry.Name()))
+	}
+}
+
+// meta 制品的元数据
+type meta struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Complete  map[string]bool `json:"complete"` // 各输出流是否完整保存（未超过 MaxSize 且没有写入错误）
+}
+
+// Chunk 从制品中读取的一段输出
+type Chunk struct {
+	NodeName   string `json:"node_name,omitempty" jsonschema:"node the artifact was read from"`
+	ID         string `json:"id" jsonschema:"artifact ID"`
+	Stream     string `json:"stream" jsonschema:"stdout or stderr"`
+	Size       int64  `json:"size" jsonschema:"number of bytes saved for the stream"`
+	Complete   bool   `json:"complete" jsonschema:"false when the stream exceeded the node's artifact size limit and its end was not saved"`
+	Offset     int64  `json:"offset" jsonschema:"byte offset of data in the stream"`
+	NextOffset int64  `json:"next_offset" jsonschema:"pass back as offset to read the following data"`
+	EOF        bool   `json:"eof" jsonschema:"true when data reaches the end of the saved stream"`
+	Data       string `json:"data" jsonschema:"output starting at offset"`
+	Encoding   string `json:"encoding,omitempty" jsonschema:"base64 when the data is not valid UTF-8 and holds the base64-encoded bytes; omitted for text"`
+}
+
+// Read 读取制品 id 中 stream 从 offset 开始最多 length 字节的输出
+// length <= 0 表示 DefaultReadLength，最多 MaxReadLength；返回的数据不会在 UTF-8 字符中间截断，
+// 不是有效的 UTF-8 时（二进制输出）不按字符边界调整，以 base64 编码返回
+func (s *Store) Read(id, stream string, offset int64, length int) (*Chunk, error) {
+	if stream == "" {
+		stream = StreamStdout
+	}
+	if stream != StreamStdout && stream != StreamStderr {
+		return nil, fmt.Errorf("invalid stream %q, must be %s or %s", stream, StreamStdout, StreamStderr)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("invalid offset %d", offset)
+	}
+	if length <= 0 {
+		length = DefaultReadLength
+	}
+	length = min(length, MaxReadLength)
+
+	s.prune(time.Now())
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	dir := filepath.Join(s.cfg.Dir, id)
+	data, err := os.ReadFile(filepath.Join(dir, metaFile))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var m meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("read artifact metadata: %v", err)
+	}
+	f, err := os.Open(filepath.Join(dir, stream))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	from := min(offset, size)
+	buf := make([]byte, min(int64(length), size-from))
+	n, err := f.ReadAt(buf, from)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read artifact: %v", err)
+	}
+	raw, start := buf[:n], from
+	chunk := raw
+	// 从字符中间开始时跳过残缺的部分
+	for i := 0; from > 0 && i < utf8.UTFMax-1 && len(chunk) > 0 && !utf8.RuneStart(chunk[0]); i++ {
+		chunk = chunk[1:]
+		from++
+	}
+	// 没有读到末尾时不返回末尾不完整的字符，留到下次读取
+	if from+int64(len(chunk)) < size {
+		chunk = chunk[:completeLen(chunk)]
+	}
+	text, encoding := string(chunk), ""
+	if !utf8.Valid(chunk) {
+		chunk, from = raw, start
+		text, encoding = base64.StdEncoding.EncodeToString(chunk), EncodingBase64
+	}
+	next := from + int64(len(chunk))
+	return &Chunk{
+		ID:         id,
+		Stream:     stream,
+		Size:       size,
+		Complete:   m.Complete[stream],
+		Offset:     from,
+		NextOffset: next,
+		EOF:        next == size,
+		Data:       text,
+		Encoding:   encoding,
+	}, nil
+}
+
+// completeLen 返回去掉末尾不完整 UTF-8 字符后的长度
+func completeLen(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
+
+// Artifact 一次执行正在写入的完整输出
+type Artifact struct {
+	id     string
+	dir    string
+	stdout *spillFile
+	stderr *spillFile
+}
+
+// ID 返回制品 ID
+func (a *Artifact) ID() string {
+	return a.id
+}
+
+// Stdout 返回写入标准输出的 Writer，写入总是成功，超过 MaxSize 或写入失败后的内容被丢弃
+func (a *Artifact) Stdout() io.Writer {
+	return a.stdout
+}
+
+// Stderr 返回写入标准错误的 Writer，行为与 Stdout 相同
+func (a *Artifact) Stderr() io.Writer {
+	return a.stderr
+}
+
+// Close 结束写入；keep 为 false 时删除制品，否则写入元数据使其可以被读取
+func (a *Artifact) Close(keep bool) error {
+	for _, w := range []*spillFile{a.stdout, a.stderr} {
+		if w != nil {
+			if err := w.f.Close(); err != nil && w.err == nil {
+				w.err = err
+			}
+		}
+	}
+	if !keep {
+		return os.RemoveAll(a.dir)
+	}
+	m := meta{
+		CreatedAt: time.Now(),
+		Complete: map[string]bool{
+			StreamStdout: a.stdout.complete(),
+			StreamStderr: a.stderr.complete(),
+		},
+	}
+	if err := errors.Join(a.stdout.err, a.stderr.err); err != nil {
+		logger.Warnf("写入制品 %s 失败，只保存了部分输出: %v", a.id, err)
+	}
+	data, _ := json.Marshal(m)
+	if err := os.WriteFile(filepath.Join(a.dir, metaFile), data, 0o600); err != nil {
+		os.RemoveAll(a.dir)
+		return fmt.Errorf("write artifact metadata: %v", err)
+	}
+	return nil
+}
+
+// spillFile 写入制品文件的 Writer，最多写入 limit 字节，之后的内容和写入失败后的内容被丢弃
+// 总是返回写入成功，不影响同时写入的执行结果
+type spillFile struct {
+	f       *os.File
+	limit   int64
+	written int64 // 已写入文件的字节数
+	total   int64 // 收到的总字节数
+	err     error // 第一次写入失败的错误
+}
+
+// Write 实现 io.Writer
+func (w *spillFile) Write(p []byte) (int, error) {
+	w.total += int64(len(p))
+	if w.err == nil && w.written < w.limit {
+		n := min(int64(len(p)), w.limit-w.written)
+		m, err := w.f.Write(p[:n])
+		w.written += int64(m)
+		w.err = err
+	}
+	return len(p), nil
+}
+
+// complete 是否保存了收到的全部内容
+func (w *spillFile) complete() bool {
+	return w.err == nil && w.written == w.total
+}
@@ -0,0 +1,407 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// 成员状态
+const (
+	StateUnknown     = "unknown"     // 尚未探测
+	StateAlive       = "alive"       // 最近一次通信成功
+	StateUnreachable = "unreachable" // 最近一次通信失败
+	StateLeft        = "left"        // 已主动离开集群
+)
+
+// NodeInfo 描述一个节点的基本信息，通过 /internal/info 和 /internal/join 交换
+type NodeInfo struct {
+	Name          string            `json:"node_name"`
+	URL           string            `json:"url"`
+	Version       string            `json:"version"`
+	OS            string            `json:"os"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	PolicyVersion string            `json:"policy_version,omitempty"` // 安全策略指纹
+}
+
+// Member 表示集群中的一个成员及其在本节点视角下的状态
+type Member struct {
+	NodeInfo
+	State     string    `json:"state"`
+	LastSeen  time.Time `json:"last_seen"`
+	LastError string    `json:"last_error,omitempty"`
+	Self      bool      `json:"self,omitempty"`
+}
+
+// JoinRequest /internal/join 的请求体
+// MyAddr 保持与旧版本兼容，其余字段为节点元数据
+type JoinRequest struct {
+	MyAddr string `json:"my_addr"`
+	NodeInfo
+}
+
+// JoinResponse /internal/join 的响应体
+type JoinResponse struct {
+	Peers []string `json:"peers"`
+}
+
+// SyncRequest /internal/sync 的请求体，包含发送方视角下的完整节点列表（含发送方自身）
+type SyncRequest struct {
+	Peers []string `json:"peers"`
+}
+
+// LeaveRequest /internal/leave 的请求体
+type LeaveRequest struct {
+	MyAddr string `json:"my_addr"`
+}
+
+// Membership 维护本节点视角下的集群成员列表
+// Peer 列表变化时通过 OnChange 回调通知调用方（用于更新分发器和保存 Peer 列表）
+type Membership struct {
+	mu         sync.RWMutex
+	self       NodeInfo
+	members    map[string]*Member
+	token      string
+	httpClient *http.Client
+	onChange   func(peers []string)
+}
+
+// NewMembership 创建成员管理实例
+// self: 本节点信息
+// peers: 初始 Peer 地址列表
+// token: 集群内部通信 Token
+func NewMembership(self NodeInfo, peers []string, token string) *Membership {
+	m := &Membership{
+		self:    self,
+		members: make(map[string]*Member),
+		token:   token,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+	for _, p := range peers {
+		p = normalizeURL(p)
+		if p == "" || p == normalizeURL(self.URL) {
+			continue
+		}
+		m.members[p] = &Member{NodeInfo: NodeInfo{URL: p}, State: StateUnknown}
+	}
+	return m
+}
+
+// OnChange 设置 Peer 列表变化时的回调
+func (m *Membership) OnChange(fn func(peers []string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// Self 返回本节点信息
+func (m *Membership) Self() NodeInfo {
+	return m.self
+}
+
+// Peers 返回所有未离开集群的 Peer 地址（不含本节点），按地址排序
+func (m *Membership) Peers() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.peersLocked()
+}
+
+func (m *Membership) peersLocked() []string {
+	peers := make([]string, 0, len(m.members))
+	for url, member := range m.members {
+		if member.State != StateLeft {
+			peers = append(peers, url)
+		}
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+// Members 返回包含本节点在内的成员列表，本节点排在第一位，其余按名称和地址排序
+func (m *Membership) Members() []Member {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	members := make([]Member, 0, len(m.members)+1)
+	for _, member := range m.members {
+		members = append(members, *member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Name != members[j].Name {
+			return members[i].Name < members[j].Name
+		}
+		return members[i].URL < members[j].URL
+	})
+
+	self := Member{NodeInfo: m.self, State: StateAlive, LastSeen: time.Now(), Self: true}
+	return append([]Member{self}, members...)
+}
+
+// Join 处理其他节点的加入请求，记录其元数据并标记为存活
+func (m *Membership) Join(info NodeInfo) {
+	info.URL = normalizeURL(info.URL)
+	if info.URL == "" || info.URL == normalizeURL(m.self.URL) {
+		return
+	}
+
+	m.mu.Lock()
+	m.members[info.URL] = &Member{NodeInfo: info, State: StateAlive, LastSeen: time.Now()}
+	m.mu.Unlock()
+
+	logger.Infof("Membership: 节点加入集群: %s (%s)", info.Name, info.URL)
+	m.notify()
+}
+
+// Leave 将指定节点标记为已离开
+func (m *Membership) Leave(url string) {
+	url = normalizeURL(url)
+
+	m.mu.Lock()
+	member, ok := m.members[url]
+	if ok {
+		member.State = StateLeft
+		member.LastSeen = time.Now()
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		logger.Warnf("Membership: 未知节点请求离开: %s", url)
+		return
+	}
+	logger.Infof("Membership: 节点离开集群: %s", url)
+	m.notify()
+}
+
+// SetPeers 使用其他节点同步过来的完整节点列表更新本地成员
+// 列表中不存在的已知节点将被标记为已离开
+func (m *Membership) SetPeers(peers []string) {
+	selfURL := normalizeURL(m.self.URL)
+	wanted := make(map[string]bool, len(peers))
+	for _, p := range peers {
+		p = normalizeURL(p)
+		if p != "" && p != selfURL {
+			wanted[p] = true
+		}
+	}
+
+	m.mu.Lock()
+	for url := range wanted {
+		member, ok := m.members[url]
+		if !ok {
+			m.members[url] = &Member{NodeInfo: NodeInfo{URL: url}, State: StateUnknown}
+		} else if member.State == StateLeft {
+			member.State = StateUnknown
+		}
+	}
+	for url, member := range m.members {
+		if !wanted[url] && member.State != StateLeft {
+			member.State = StateLeft
+		}
+	}
+	m.mu.Unlock()
+
+	m.notify()
+}
+
+// Refresh 并发探测所有未离开的成员，更新其元数据、状态和最后通信时间
+func (m *Membership) Refresh(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, url := range m.Peers() {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			var info NodeInfo
+			err := m.do(ctx, http.MethodGet, url+"/internal/info", nil, &info)
+			m.markProbe(url, info, err)
+		}(url)
+	}
+	wg.Wait()
+}
+
+// markProbe 记录一次与成员的通信结果
+func (m *Membership) markProbe(url string, info NodeInfo, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	member, ok := m.members[url]
+	if !ok || member.State == StateLeft {
+		return
+	}
+	if err != nil {
+		member.State = StateUnreachable
+		member.LastError = err.Error()
+		return
+	}
+	info.URL = url
+	member.NodeInfo = info
+	member.State = StateAlive
+	member.LastSeen = time.Now()
+	member.LastError = ""
+}
+
+// JoinSeed 通过种子节点加入集群，并使用种子节点返回的节点列表更新本地成员
+func (m *Membership) JoinSeed(ctx context.Context, seed string) ([]string, error) {
+	seed = normalizeURL(seed)
+	if seed == "" {
+		return nil, errors.New("seed address is empty")
+	}
+
+	req := JoinRequest{MyAddr: m.self.URL, NodeInfo: m.self}
+	var resp JoinResponse
+	if err := m.do(ctx, http.MethodPost, seed+"/internal/join", req, &resp); err != nil {
+		return nil, fmt.Errorf("join via seed %s failed: %w", seed, err)
+	}
+
+	peers := resp.Peers
+	if len(peers) == 0 {
+		peers = []string{seed}
+	}
+	m.SetPeers(peers)
+	logger.Infof("Membership: 已通过种子节点 %s 加入集群，peers: %v", seed, m.Peers())
+	return m.Peers(), nil
+}
+
+// LeaveCluster 通知所有 Peer 本节点即将离开，并清空本地 Peer 列表
+// 单个 Peer 通知失败不会中断流程，所有错误合并后返回
+func (m *Membership) LeaveCluster(ctx context.Context) error {
+	err := m.AnnounceLeave(ctx)
+	m.SetPeers(nil)
+	return err
+}
+
+// AnnounceLeave 通知所有 Peer 本节点即将离开，但保留本地 Peer 列表
+// 用于进程关闭：重启后可通过 Rejoin 使用原有 Peer 列表重新加入集群
+func (m *Membership) AnnounceLeave(ctx context.Context) error {
+	peers := m.Peers()
+	req := LeaveRequest{MyAddr: m.self.URL}
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, url := range peers {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			if err := m.do(ctx, http.MethodPost, url+"/internal/leave", req, nil); err != nil {
+				logger.Warnf("Membership: 通知 %s 离开失败: %v", url, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", url, err))
+				mu.Unlock()
+			}
+		}(url)
+	}
+	wg.Wait()
+
+	logger.Infof("Membership: 本节点已离开集群，通知了 %d 个 peers", len(peers))
+	return errors.Join(errs...)
+}
+
+// Rejoin 向所有已知 Peer 重新发送加入请求
+// 节点重启后调用，使此前被标记为已离开的本节点重新被其他节点接纳
+func (m *Membership) Rejoin(ctx context.Context) {
+	peers := m.Peers()
+	req := JoinRequest{MyAddr: m.self.URL, NodeInfo: m.self}
+
+	var wg sync.WaitGroup
+	for _, url := range peers {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			if err := m.do(ctx, http.MethodPost, url+"/internal/join", req, nil); err != nil {
+				logger.Warnf("Membership: 向 %s 重新加入失败: %v", url, err)
+			}
+		}(url)
+	}
+	wg.Wait()
+	logger.Debugf("Membership: 已向 %d 个 peers 发送重新加入请求", len(peers))
+}
+
+// Broadcast 将当前完整节点列表（含本节点）同步给所有 Peer
+func (m *Membership) Broadcast(ctx context.Context) {
+	peers := m.Peers()
+	req := SyncRequest{Peers: append(peers, m.self.URL)}
+	logger.Infof("Membership: 开始广播同步到 %d 个 peers", len(peers))
+
+	var wg sync.WaitGroup
+	for _, url := range peers {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			if err := m.do(ctx, http.MethodPost, url+"/internal/sync", req, nil); err != nil {
+				logger.Warnf("Membership: 同步到 %s 失败: %v", url, err)
+			}
+		}(url)
+	}
+	wg.Wait()
+	logger.Debugf("Membership: 广播同步完成")
+}
+
+// notify 在不持有锁的情况下调用 OnChange 回调
+func (m *Membership) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	peers := m.peersLocked()
+	m.mu.RUnlock()
+
+	if fn != nil {
+		fn(peers)
+	}
+}
+
+// do 发送带 Cluster Token 的 JSON 请求，out 不为 nil 时解析响应体
+func (m *Membership) do(ctx context.Context, method, url string, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshal request failed: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if m.token != "" {
+		req.Header.Set("X-Cluster-Token", m.token)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response failed: %w", err)
+	}
+	return nil
+}
+
+// normalizeURL 去除地址首尾空白和末尾的斜杠
+func normalizeURL(url string) string {
+	return strings.TrimRight(strings.TrimSpace(url), "/")
+}
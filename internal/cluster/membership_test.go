@@ -0,0 +1,148 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// TestMain 先显式初始化 logger，避免懒加载初始化时的死锁
+func TestMain(m *testing.M) {
+	_ = logger.InitLogger(&logger.LogConfig{Level: "error", LogDir: os.TempDir()}, "cluster_test.log")
+	os.Exit(m.Run())
+}
+
+// TestSetPeers 验证同步列表会过滤本节点，并将缺失节点标记为已离开
+func TestSetPeers(t *testing.T) {
+	m := NewMembership(NodeInfo{Name: "self", URL: "http://self:8080"}, []string{"http://a:8080", "http://b:8080/"}, "")
+
+	var changed []string
+	m.OnChange(func(peers []string) { changed = peers })
+
+	m.SetPeers([]string{"http://self:8080", "http://a:8080", "http://c:8080"})
+
+	want := []string{"http://a:8080", "http://c:8080"}
+	if got := m.Peers(); !slices.Equal(got, want) {
+		t.Fatalf("Peers() = %v, 预期 %v", got, want)
+	}
+	if !slices.Equal(changed, want) {
+		t.Fatalf("OnChange 收到 %v, 预期 %v", changed, want)
+	}
+
+	for _, member := range m.Members() {
+		if member.URL == "http://b:8080" && member.State != StateLeft {
+			t.Errorf("节点 b 状态为 %s, 预期 %s", member.State, StateLeft)
+		}
+	}
+}
+
+// TestJoinSeedAndRefresh 验证通过种子节点加入集群并探测成员状态
+func TestJoinSeedAndRefresh(t *testing.T) {
+	var seedURL string
+	seed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Cluster-Token") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/internal/join":
+			var req JoinRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("解析 join 请求失败: %v", err)
+			}
+			if req.MyAddr != "http://self:8080" || req.Name != "self" {
+				t.Errorf("join 请求内容错误: %+v", req)
+			}
+			json.NewEncoder(w).Encode(JoinResponse{Peers: []string{"http://self:8080", seedURL, "http://dead.invalid:1"}})
+		case "/internal/info":
+			json.NewEncoder(w).Encode(NodeInfo{Name: "seed", Version: "9.9.9", Labels: map[string]string{"role": "db"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer seed.Close()
+	seedURL = seed.URL
+
+	m := NewMembership(NodeInfo{Name: "self", URL: "http://self:8080"}, nil, "secret")
+	peers, err := m.JoinSeed(t.Context(), seed.URL)
+	if err != nil {
+		t.Fatalf("JoinSeed 失败: %v", err)
+	}
+	if len(peers) != 2 || !slices.Contains(peers, seed.URL) {
+		t.Fatalf("JoinSeed 返回 peers = %v", peers)
+	}
+
+	m.Refresh(t.Context())
+
+	states := map[string]Member{}
+	for _, member := range m.Members() {
+		states[member.URL] = member
+	}
+	if got := states[seed.URL]; got.State != StateAlive || got.Name != "seed" || got.Labels["role"] != "db" || got.LastSeen.IsZero() {
+		t.Errorf("种子节点状态错误: %+v", got)
+	}
+	if got := states["http://dead.invalid:1"]; got.State != StateUnreachable || got.LastError == "" {
+		t.Errorf("不可达节点状态错误: %+v", got)
+	}
+	if got := states["http://self:8080"]; !got.Self {
+		t.Errorf("成员列表中缺少本节点: %+v", got)
+	}
+}
+
+// TestSummarize 验证集群健康状态和策略一致性判断
+func TestSummarize(t *testing.T) {
+	alive := func(name, policy string) Member {
+		return Member{NodeInfo: NodeInfo{Name: name, Version: "1.0.0", PolicyVersion: policy}, State: StateAlive}
+	}
+
+	tests := []struct {
+		name       string
+		members    []Member
+		health     string
+		consistent bool
+		size       int
+	}{
+		{
+			name:       "全部可达且策略一致",
+			members:    []Member{alive("a", "p1"), alive("b", "p1")},
+			health:     HealthHealthy,
+			consistent: true,
+			size:       2,
+		},
+		{
+			name:       "策略不一致",
+			members:    []Member{alive("a", "p1"), alive("b", "p2")},
+			health:     HealthDegraded,
+			consistent: false,
+			size:       2,
+		},
+		{
+			name:       "少数节点不可达",
+			members:    []Member{alive("a", "p1"), alive("b", "p1"), {State: StateUnreachable}, {State: StateLeft}},
+			health:     HealthDegraded,
+			consistent: true,
+			size:       3,
+		},
+		{
+			name:       "半数节点不可达",
+			members:    []Member{alive("a", "p1"), {State: StateUnreachable}},
+			health:     HealthUnhealthy,
+			consistent: true,
+			size:       2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := Summarize(tt.members)
+			if st.Health != tt.health || st.PolicyConsistent != tt.consistent || st.MembershipSize != tt.size {
+				t.Errorf("Summarize() = %+v, 预期 health=%s consistent=%v size=%d", st, tt.health, tt.consistent, tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package cluster
+
+import "sort"
+
+// 集群健康状态
+const (
+	HealthHealthy   = "healthy"   // 所有成员可达且安全策略一致
+	HealthDegraded  = "degraded"  // 部分成员不可达或安全策略不一致，但多数成员可达
+	HealthUnhealthy = "unhealthy" // 半数及以上成员不可达
+)
+
+// Status 集群整体状态汇总
+type Status struct {
+	Health           string              `json:"health" jsonschema:"overall health: healthy, degraded or unhealthy"`
+	MembershipSize   int                 `json:"membership_size" jsonschema:"number of nodes in the cluster (including this node, excluding nodes that left)"`
+	Reachable        int                 `json:"reachable" jsonschema:"number of reachable nodes"`
+	Unreachable      int                 `json:"unreachable" jsonschema:"number of unreachable nodes"`
+	Left             int                 `json:"left" jsonschema:"number of nodes that have left the cluster"`
+	PolicyConsistent bool                `json:"policy_consistent" jsonschema:"whether all reachable nodes run the same security policy"`
+	PolicyVersions   map[string][]string `json:"policy_versions" jsonschema:"security policy fingerprint to node names"`
+	Versions         map[string][]string `json:"versions" jsonschema:"software version to node names"`
+}
+
+// Summarize 根据成员列表计算集群整体状态
+// 只有可达节点参与策略和版本一致性判断
+func Summarize(members []Member) Status {
+	st := Status{
+		PolicyVersions: make(map[string][]string),
+		Versions:       make(map[string][]string),
+	}
+
+	for _, m := range members {
+		switch m.State {
+		case StateLeft:
+			st.Left++
+			continue
+		case StateAlive:
+			st.Reachable++
+		default:
+			st.Unreachable++
+			st.MembershipSize++
+			continue
+		}
+		st.MembershipSize++
+
+		name := m.Name
+		if name == "" {
+			name = m.URL
+		}
+		if m.PolicyVersion != "" {
+			st.PolicyVersions[m.PolicyVersion] = append(st.PolicyVersions[m.PolicyVersion], name)
+		}
+		if m.Version != "" {
+			st.Versions[m.Version] = append(st.Versions[m.Version], name)
+		}
+	}
+
+	for _, nodes := range st.PolicyVersions {
+		sort.Strings(nodes)
+	}
+	for _, nodes := range st.Versions {
+		sort.Strings(nodes)
+	}
+	st.PolicyConsistent = len(st.PolicyVersions) <= 1
+
+	switch {
+	case st.Unreachable == 0 && st.PolicyConsistent:
+		st.Health = HealthHealthy
+	case st.Reachable*2 > st.MembershipSize:
+		st.Health = HealthDegraded
+	default:
+		st.Health = HealthUnhealthy
+	}
+	return st
+}
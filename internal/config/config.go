@@ -2,23 +2,47 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"slices"
 	"sync"
+	"time"
 
+	"github.com/AceDarkknight/shell-executor-mcp/internal/artifacts"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/dispatch"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/jobs"
 	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/security"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/sessions"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
 )
 
 // ServerConfig 定义服务器的配置结构
 type ServerConfig struct {
-	Port         int              `json:"port"`          // 监听端口
-	NodeName     string           `json:"node_name"`     // 节点名称
-	Peers        []string         `json:"peers"`         // 集群中其他节点的地址列表
-	Security     SecurityConfig   `json:"security"`      // 安全配置
-	ClusterToken string           `json:"cluster_token"` // 集群内部通信Token
-	LogConfig    logger.LogConfig `json:"log_config"`    // 日志配置
-	TLS          TLSConfig        `json:"tls"`           // TLS 配置
-	mu           sync.RWMutex     // 读写锁，用于保护 Peers 的并发修改
+	Port         int               `json:"port"`          // 监听端口
+	NodeName     string            `json:"node_name"`     // 节点名称
+	Peers        []string          `json:"peers"`         // 集群中其他节点的地址列表
+	PeersFile    string            `json:"peers_file"`    // 保存运行时 Peer 列表的状态文件（为空则不保存），启动时与 Peers 合并
+	Security     SecurityConfig    `json:"security"`      // 安全配置
+	ClusterToken string            `json:"cluster_token"` // 集群内部通信Token
+	LogConfig    logger.LogConfig  `json:"log_config"`    // 日志配置
+	TLS          TLSConfig         `json:"tls"`           // TLS 配置
+	AdvertiseURL string            `json:"advertise_url"` // 本节点对其他节点公布的地址，如 http://10.0.0.1:8080（为空则自动推导）
+	AdminToken   string            `json:"admin_token"`   // 管理 API Token（为空则使用 ClusterToken）
+	Labels       map[string]string `json:"labels"`        // 节点标签，如 {"role": "web"}
+	DrainTimeout int               `json:"drain_timeout"` // 优雅关闭时等待正在执行命令结束的秒数，默认 30
+	Metrics      MetricsConfig     `json:"metrics"`       // Prometheus 指标配置
+	Tracing      tracing.Config    `json:"tracing"`       // 链路追踪配置
+	Jobs         jobs.Config       `json:"jobs"`          // 后台作业配置
+	Sessions     sessions.Config   `json:"sessions"`      // Shell 会话配置
+	Environment  EnvironmentConfig `json:"environment"`   // 命令的基础环境变量
+	Executor     executor.Config   `json:"executor"`      // 运行用户和资源限制
+	Artifacts    artifacts.Config  `json:"artifacts"`     // 被截断命令的完整输出
+	MaxResponse  int               `json:"max_response"`  // 聚合结果中所有输出和错误的总字节数上限，默认 4 MiB
+	mu           sync.RWMutex      // 读写锁，用于保护 Peers 的并发修改
 }
 
 // TLSConfig 定义 TLS 相关的配置
@@ -28,10 +52,57 @@ type TLSConfig struct {
 	KeyFile  string `json:"key_file"`  // 私钥文件路径（为空则自动生成自签证书）
 }
 
+// MetricsConfig 定义 Prometheus 指标端点的配置
+type MetricsConfig struct {
+	Enabled bool   `json:"enabled"` // 是否启用 /metrics
+	Listen  string `json:"listen"`  // 独立监听地址，如 ":9090"（为空则挂载在服务端口上）
+	Token   string `json:"token"`   // Bearer Token（为空则不鉴权）
+}
+
 // SecurityConfig 定义安全相关的配置
 type SecurityConfig struct {
-	BlacklistedCommands []string `json:"blacklisted_commands"` // 黑名单命令
-	DangerousArgsRegex  []string `json:"dangerous_args_regex"` // 危险参数正则表达式
+	BlacklistedCommands []string        `json:"blacklisted_commands"` // 黑名单命令
+	DangerousArgsRegex  []string        `json:"dangerous_args_regex"` // 危险参数正则表达式
+	AllowedCwdPrefixes  []string        `json:"allowed_cwd_prefixes"` // 允许的工作目录前缀，为空表示允许任意绝对路径
+	AllowedEnv          []string        `json:"allowed_env"`          // 允许传入的环境变量名（支持通配符，如 APP_*），为空表示不限制
+	Rules               []security.Rule `json:"rules"`                // 按命令匹配的策略规则，可覆盖资源限制
+}
+
+// DefaultPassthroughEnv 未配置 environment.passthrough 时传给命令的服务器环境变量
+var DefaultPassthroughEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_ALL", "LC_CTYPE", "TZ", "TMPDIR", "TERM"}
+
+// EnvironmentConfig 定义命令的基础环境变量
+// 命令不继承服务器进程的全部环境变量，只传入 Passthrough 中列出的变量和 Set 中的固定值，
+// execute_command 的 env 参数合并在其之上
+type EnvironmentConfig struct {
+	Passthrough []string          `json:"passthrough"` // 从服务器进程传给命令的环境变量名，未配置时为 DefaultPassthroughEnv，配置为 [] 表示不传入任何变量
+	Set         map[string]string `json:"set"`         // 固定设置的环境变量，优先于 Passthrough
+}
+
+// BaseEnv 返回命令的基础环境变量（KEY=VALUE 形式）
+func (c EnvironmentConfig) BaseEnv() []string {
+	names := c.Passthrough
+	if names == nil {
+		names = DefaultPassthroughEnv
+	}
+	env := []string{}
+	for _, name := range names {
+		if _, ok := c.Set[name]; ok {
+			continue
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	keys := make([]string, 0, len(c.Set))
+	for name := range c.Set {
+		keys = append(keys, name)
+	}
+	slices.Sort(keys)
+	for _, name := range keys {
+		env = append(env, name+"="+c.Set[name])
+	}
+	return env
 }
 
 // LogConfig 定义日志相关的配置
@@ -59,6 +130,53 @@ func LoadServerConfig(path string) (*ServerConfig, error) {
 	return &cfg, nil
 }
 
+// SelfURL 返回本节点对其他节点公布的地址
+// 优先使用 AdvertiseURL，否则根据主机名、端口和 TLS 配置推导
+func (c *ServerConfig) SelfURL() string {
+	if c.AdvertiseURL != "" {
+		return c.AdvertiseURL
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	scheme := "http"
+	if c.TLS.Enabled {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, host, c.Port)
+}
+
+// GetAdminToken 返回管理 API 使用的 Token
+// 未单独配置 AdminToken 时回退为 ClusterToken
+func (c *ServerConfig) GetAdminToken() string {
+	if c.AdminToken != "" {
+		return c.AdminToken
+	}
+	return c.ClusterToken
+}
+
+// DefaultDrainTimeout 默认的优雅关闭等待时间
+const DefaultDrainTimeout = 30 * time.Second
+
+// GetDrainTimeout 返回优雅关闭时等待正在执行命令结束的时间
+// 未配置或配置非法时返回 DefaultDrainTimeout
+func (c *ServerConfig) GetDrainTimeout() time.Duration {
+	if c.DrainTimeout <= 0 {
+		return DefaultDrainTimeout
+	}
+	return time.Duration(c.DrainTimeout) * time.Second
+}
+
+// GetMaxResponse 返回聚合结果中所有输出和错误的总字节数上限
+// 未配置或配置非法时返回 dispatch.DefaultMaxResponse
+func (c *ServerConfig) GetMaxResponse() int {
+	if c.MaxResponse <= 0 {
+		return dispatch.DefaultMaxResponse
+	}
+	return c.MaxResponse
+}
+
 // GetPeers 线程安全地获取 Peers 列表
 func (c *ServerConfig) GetPeers() []string {
 	c.mu.RLock()
@@ -96,3 +214,48 @@ func (c *ServerConfig) Save(path string) error {
 	}
 	return os.WriteFile(path, data, 0644)
 }
+
+// peersState Peer 状态文件的内容
+type peersState struct {
+	Peers []string `json:"peers"`
+}
+
+// LoadPeers 读取 Peer 状态文件中保存的 Peer 列表，exists 表示文件是否存在
+func LoadPeers(path string) (peers []string, exists bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var state peersState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, false, fmt.Errorf("invalid peers file %s: %v", path, err)
+	}
+	return state.Peers, true, nil
+}
+
+// SavePeers 将 Peer 列表写入状态文件
+// 先写入同目录下的临时文件再重命名，避免写入中断时留下不完整的文件
+func SavePeers(path string, peers []string) error {
+	data, err := json.MarshalIndent(peersState{Peers: peers}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -2,157 +2,414 @@ package dispatch
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/metrics"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
 
 	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
 )
 
+// 分发策略
+const (
+	StrategyParallel = "parallel" // 所有目标节点并发执行（默认）
+	StrategySerial   = "serial"   // 按顺序逐个节点执行，某个节点失败后跳过剩余节点
+)
+
+// 执行超时
+const (
+	DefaultTimeout = 5 * time.Second // 未指定超时时每个节点的执行超时
+	MaxTimeout     = time.Hour       // 允许指定的最大执行超时
+
+	// peerRequestOverhead 向 Peer 发送请求时在执行超时之外预留的网络耗时
+	peerRequestOverhead = 25 * time.Second
+)
+
+// 读取 Peer 响应的大小上限
+const (
+	// maxErrorBody 非 200 响应最多读取的字节数，只用于错误信息
+	maxErrorBody = 4 << 10
+	// responseOverhead 执行结果中输出和错误之外的 JSON 字段预留的字节数
+	responseOverhead = 64 << 10
+)
+
+// ResponseLimit 返回读取 Peer 执行响应的最大字节数
+// 响应中的标准输出和标准错误各自最多 maxOutput 字节，且不超过 maxResponse 时按两者中较大的计算；
+// JSON 转义最多将每个字节扩展为 6 个字节（\u00XX）。参数 <= 0 时使用默认值
+func ResponseLimit(maxOutput, maxResponse int) int64 {
+	if maxOutput <= 0 {
+		maxOutput = executor.DefaultMaxOutput
+	}
+	if maxResponse <= 0 {
+		maxResponse = DefaultMaxResponse
+	}
+	return 6*int64(max(2*maxOutput, maxResponse)) + responseOverhead
+}
+
+// StatusSkipped 串行执行时因前面的节点失败而未执行的节点状态
+const StatusSkipped = "skipped"
+
+// Options 单次分发的执行参数
+type Options struct {
+	Timeout   time.Duration    // 每个节点的执行超时，0 表示 DefaultTimeout，超过 MaxTimeout 时按 MaxTimeout 处理
+	Peers     []string         // 参与执行的 Peer 地址，nil 表示所有 Peer
+	SkipLocal bool             // 是否跳过本节点
+	Strategy  string           // 分发策略，空表示 StrategyParallel
+	Exec      executor.Options // 工作目录、环境变量和标准输入，每个节点相同
+}
+
+// ClampTimeout 将执行超时限制在 (0, MaxTimeout] 范围内，0 或负数返回 DefaultTimeout
+func ClampTimeout(timeout time.Duration) time.Duration {
+	switch {
+	case timeout <= 0:
+		return DefaultTimeout
+	case timeout > MaxTimeout:
+		return MaxTimeout
+	default:
+		return timeout
+	}
+}
+
+// ValidStrategy 判断分发策略是否有效，空字符串表示默认策略
+func ValidStrategy(strategy string) bool {
+	return strategy == "" || strategy == StrategyParallel || strategy == StrategySerial
+}
+
 // Dispatcher 负责将命令分发给集群节点并聚合结果
 type Dispatcher struct {
+	mu         sync.RWMutex // 保护 peers 的并发修改
 	peers      []string
 	token      string
 	httpClient *http.Client
+	maxBody    int64 // 读取 Peer 执行响应的最大字节数，避免异常的 Peer 耗尽本节点内存
 }
 
 // NewDispatcher 创建一个新的分发器实例
+// 向 Peer 发送请求的超时由每次分发的执行超时决定，响应大小上限默认为 ResponseLimit(0, 0)
 func NewDispatcher(peers []string, token string) *Dispatcher {
 	return &Dispatcher{
-		peers: peers,
-		token: token,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second, // 默认5秒超时
-		},
+		peers:      peers,
+		token:      token,
+		httpClient: &http.Client{},
+		maxBody:    ResponseLimit(0, 0),
 	}
 }
 
+// SetResponseLimit 设置读取 Peer 执行响应的最大字节数，通常为 ResponseLimit(max_output, max_response)
+// 超过上限的响应视为该节点执行失败
+func (d *Dispatcher) SetResponseLimit(limit int64) {
+	d.maxBody = limit
+}
+
+// SetPeers 线程安全地更新 Peer 列表（集群成员变化时调用）
+func (d *Dispatcher) SetPeers(peers []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.peers = append([]string(nil), peers...)
+}
+
+// getPeers 线程安全地获取 Peer 列表副本
+func (d *Dispatcher) getPeers() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]string(nil), d.peers...)
+}
+
+// NoExitCode 节点没有退出码（请求失败、被跳过或进程被信号终止）时的 ExitCode
+const NoExitCode = -1
+
 // NodeResult 表示单个节点的执行结果
 type NodeResult struct {
-	NodeName string `json:"node_name"`
-	Status   string `json:"status"` // success, failed, timeout
-	Output   string `json:"output"`
-	Error    string `json:"error"`
+	NodeName  string `json:"node_name"`
+	Status    string `json:"status"` // success, failed, timeout, skipped；提升为后台作业时还可能是 running 等作业状态
+	ExitCode  int    `json:"exit_code"`
+	Output    string `json:"output"`
+	Error     string `json:"error"`
+	Encoding  string `json:"encoding,omitempty"`  // Output 的编码，空表示 UTF-8 文本，见 executor.EncodingBase64
+	KilledBy  string `json:"killed_by,omitempty"` // 因超时或资源限制被终止时的原因
+	Sandboxed bool   `json:"sandboxed,omitempty"` // 是否在沙箱中运行
+	// Truncated 输出超过节点的输出上限或响应大小上限，只保留了开头和结尾
+	Truncated   bool          `json:"truncated,omitempty"`
+	OutputBytes int64         `json:"output_bytes,omitempty"` // 截断前标准输出的字节数
+	ErrorBytes  int64         `json:"error_bytes,omitempty"`  // 截断前标准错误的字节数
+	Artifact    string        `json:"artifact,omitempty"`     // 节点上保存完整输出的制品 ID
+	Duration    time.Duration `json:"-"`                      // 执行耗时，Peer 节点包含网络耗时
 }
 
-// AggregatedGroup 聚合后的结果组
+// AggregatedGroup 聚合后的结果组：状态、退出码、终止原因、是否在沙箱中运行、输出、错误、编码和截断情况完全相同的节点
 type AggregatedGroup struct {
-	Output string   `json:"output"`
-	Error  string   `json:"error"`
-	Status string   `json:"status"`
-	Nodes  []string `json:"nodes"`
-	Count  int      `json:"count"`
+	Output    string `json:"output"`
+	Error     string `json:"error"`
+	Encoding  string `json:"encoding,omitempty" jsonschema:"base64 when the stdout was not valid UTF-8 and output holds its base64-encoded bytes; omitted for text"`
+	Status    string `json:"status"`
+	ExitCode  int    `json:"exit_code" jsonschema:"exit code of the command, -1 when not available"`
+	KilledBy  string `json:"killed_by,omitempty" jsonschema:"set when the command was killed by a limit: timeout, cpu, file_size or memory"`
+	Sandboxed bool   `json:"sandboxed,omitempty" jsonschema:"true when the command ran in the namespace sandbox"`
+	Truncated bool   `json:"truncated,omitempty" jsonschema:"true when output or error was cut to its head and tail because it exceeded the output or response size limit"`
+	// OutputBytes、ErrorBytes 截断前的字节数，只在 Truncated 时设置
+	OutputBytes int64             `json:"output_bytes,omitempty" jsonschema:"size in bytes of the stdout before truncation"`
+	ErrorBytes  int64             `json:"error_bytes,omitempty" jsonschema:"size in bytes of the stderr before truncation"`
+	Artifacts   map[string]string `json:"artifacts,omitempty" jsonschema:"artifact ID of the full output per node, read it with get_artifact"`
+	Nodes       []string          `json:"nodes"`
+	Count       int               `json:"count"`
+	Durations   map[string]int64  `json:"durations_ms,omitempty" jsonschema:"execution time of each node in milliseconds"`
 }
 
 // DispatchRequest 分发请求的 Body 结构
 type DispatchRequest struct {
-	Cmd string `json:"cmd"`
+	Cmd              string `json:"cmd"`
+	Timeout          int    `json:"timeout,omitempty"` // 执行超时（秒），0 表示 DefaultTimeout
+	executor.Options        // 工作目录、环境变量和标准输入（cwd、env、stdin）
 }
 
 // DispatchResponse 分发响应的 Body 结构
 type DispatchResponse struct {
-	ExitCode int    `json:"exit_code"`
-	Output   string `json:"output"`
-	Error    string `json:"error"`
+	ExitCode    int    `json:"exit_code"`
+	Output      string `json:"output"`
+	Error       string `json:"error"`
+	Encoding    string `json:"encoding,omitempty"`
+	KilledBy    string `json:"killed_by,omitempty"`
+	Sandboxed   bool   `json:"sandboxed,omitempty"`
+	Truncated   bool   `json:"truncated,omitempty"`
+	OutputBytes int64  `json:"output_bytes,omitempty"`
+	ErrorBytes  int64  `json:"error_bytes,omitempty"`
+	Artifact    string `json:"artifact,omitempty"`
 }
 
 // Dispatch 执行命令分发和聚合
+// ctx: 用于链路追踪和取消，本地执行和每个 Peer 调用都会创建子 Span
 // localExecutor: 本地执行器
 // nodeName: 当前节点名称
 // cmd: 要执行的命令
-func (d *Dispatcher) Dispatch(localExecutor *executor.Executor, nodeName string, cmd string) ([]AggregatedGroup, string) {
-	// 导入 logger
-	// 这里需要导入 logger 包，但由于代码结构限制，暂时使用 fmt 输出
-	// 在实际使用中，应该在文件顶部导入 logger 包
-
-	logger.Infof("Dispatcher: 开始分发命令: %s, 节点名称: %s\n", cmd, nodeName)
-	logger.Infof("Dispatcher: Peer 节点数量: %d\n", len(d.peers))
-
-	// 用于收集所有结果
-	var results []NodeResult
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// 1. 本地执行
-	logger.Infof("Dispatcher: 开始本地执行\n")
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		logger.Infof("Dispatcher: 执行命令: %s, 超时: 5s\n", cmd)
-		res, err := localExecutor.Execute(cmd, 5*time.Second)
-		if err != nil {
-			logger.Infof("Dispatcher: 本地执行失败: %v\n", err)
-			mu.Lock()
-			results = append(results, NodeResult{
-				NodeName: nodeName,
-				Status:   "failed",
-				Error:    err.Error(),
-			})
-			mu.Unlock()
-			return
-		}
-
-		logger.Infof("Dispatcher: 本地执行成功, 退出码: %d, 输出长度: %d\n", res.ExitCode, len(res.Output))
-		mu.Lock()
-		results = append(results, NodeResult{
-			NodeName: nodeName,
-			Status:   "success",
-			Output:   res.Output,
-			Error:    res.Error,
-		})
-		mu.Unlock()
-	}()
+// opts: 执行超时、目标节点、分发策略和执行参数
+func (d *Dispatcher) Dispatch(ctx context.Context, localExecutor *executor.Executor, nodeName string, cmd string, opts Options) ([]AggregatedGroup, string) {
+	peers := opts.Peers
+	if peers == nil {
+		peers = d.getPeers()
+	}
+	timeout := ClampTimeout(opts.Timeout)
+	logger.Infof("Dispatcher: 开始分发命令: %s, 节点名称: %s, 策略: %s, 超时: %s\n", cmd, nodeName, opts.Strategy, timeout)
+	logger.Infof("Dispatcher: Peer 节点数量: %d\n", len(peers))
+
+	// 每个目标节点对应一个执行任务，本节点排在最前
+	type task struct {
+		name string
+		run  func() NodeResult
+	}
+	var tasks []task
+	if !opts.SkipLocal {
+		tasks = append(tasks, task{name: nodeName, run: func() NodeResult {
+			return d.executeLocal(ctx, localExecutor, nodeName, cmd, timeout, opts.Exec)
+		}})
+	}
+	for _, peer := range peers {
+		tasks = append(tasks, task{name: peer, run: func() NodeResult {
+			return d.executeOnPeer(ctx, peer, cmd, timeout, opts.Exec)
+		}})
+	}
 
-	// 2. 分发给其他节点
-	logger.Infof("Dispatcher: 开始向 %d 个 peer 节点分发\n", len(d.peers))
-	for i, peer := range d.peers {
-		wg.Add(1)
-		go func(peerURL string, index int) {
-			defer wg.Done()
-			logger.Infof("Dispatcher: 向 peer [%d] 发送请求: %s\n", index+1, peerURL)
-			result := d.executeOnPeer(peerURL, cmd)
-			logger.Infof("Dispatcher: peer [%d] 执行完成, 状态: %s\n", index+1, result.Status)
-
-			mu.Lock()
-			results = append(results, result)
-			mu.Unlock()
-		}(peer, i)
-	}
-
-	// 等待所有任务完成
-	logger.Infof("Dispatcher: 等待所有任务完成...\n")
-	wg.Wait()
+	results := make([]NodeResult, len(tasks))
+	if opts.Strategy == StrategySerial {
+		// 串行执行：某个节点失败后，剩余节点标记为 skipped
+		var failedNode string
+		for i, t := range tasks {
+			if failedNode != "" {
+				results[i] = NodeResult{
+					NodeName: t.name,
+					Status:   StatusSkipped,
+					ExitCode: NoExitCode,
+					Error:    fmt.Sprintf("skipped after failure on %s", failedNode),
+				}
+				continue
+			}
+			results[i] = runTimed(t.run)
+			logger.Infof("Dispatcher: 节点 %s 执行完成, 状态: %s\n", t.name, results[i].Status)
+			if results[i].Status != "success" {
+				failedNode = results[i].NodeName
+			}
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i, t := range tasks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = runTimed(t.run)
+				logger.Infof("Dispatcher: 节点 %s 执行完成, 状态: %s\n", t.name, results[i].Status)
+			}()
+		}
+		logger.Infof("Dispatcher: 等待所有任务完成...\n")
+		wg.Wait()
+	}
 	logger.Infof("Dispatcher: 所有任务完成, 结果数量: %d\n", len(results))
 
-	// 3. 聚合结果
+	// 聚合结果
 	logger.Infof("Dispatcher: 开始聚合结果\n")
-	groups := d.aggregateResults(results)
-	summary := fmt.Sprintf("Executed on %d nodes, %d groups found", len(results), len(groups))
+	groups := Aggregate(results)
+	summary := Summarize(results, groups)
 	logger.Infof("Dispatcher: 聚合完成, 组数: %d, 摘要: %s\n", len(groups), summary)
 
 	return groups, summary
 }
 
+// Summarize 生成执行摘要
+func Summarize(results []NodeResult, groups []AggregatedGroup) string {
+	return fmt.Sprintf("Executed on %d nodes, %d groups found", len(results), len(groups))
+}
+
+// DefaultMaxResponse 聚合结果中所有输出和错误的默认总字节数上限
+const DefaultMaxResponse = 4 << 20
+
+// CapGroups 将所有结果组的输出和错误总量限制在约 limit 字节内，返回是否有内容被截断
+// 额度优先分给较短的字段：短于平均额度的字段完整保留，其余字段平分剩余额度，只保留开头和结尾；
+// 被截断的组设置 Truncated，组内还没有截断前大小时记录截断前的字节数。limit <= 0 表示不限制
+// base64 编码的输出按 4 字节对齐截断，开头和结尾可以分别解码
+func CapGroups(groups []AggregatedGroup, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	var fields []*string
+	total := 0
+	for i := range groups {
+		fields = append(fields, &groups[i].Output, &groups[i].Error)
+		total += len(groups[i].Output) + len(groups[i].Error)
+	}
+	if total <= limit {
+		return false
+	}
+
+	order := make([]int, len(fields))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return len(*fields[order[a]]) < len(*fields[order[b]]) })
+	quota := make([]int, len(fields))
+	remaining := limit
+	for k, i := range order {
+		quota[i] = min(len(*fields[i]), remaining/(len(order)-k))
+		if quota[i] == 0 && len(*fields[i]) > 0 {
+			// 额度不足时至少保留 1 字节，避免 Truncate 将 0 视为不限制
+			quota[i] = 1
+		}
+		remaining = max(remaining-quota[i], 0)
+	}
+
+	for i := range groups {
+		g := &groups[i]
+		outBytes, errBytes := int64(len(g.Output)), int64(len(g.Error))
+		if g.Encoding == executor.EncodingBase64 && quota[2*i] < len(g.Output) {
+			// 开头和结尾各为 4 的倍数
+			quota[2*i] = max(quota[2*i]/8*8, 8)
+		}
+		output, outCut := executor.Truncate(g.Output, quota[2*i])
+		errOut, errCut := executor.Truncate(g.Error, quota[2*i+1])
+		if !outCut && !errCut {
+			continue
+		}
+		g.Output, g.Error = output, errOut
+		if !g.Truncated {
+			g.Truncated = true
+			g.OutputBytes, g.ErrorBytes = outBytes, errBytes
+		}
+	}
+	logger.Infof("CapGroups: 输出总量 %d 字节超过上限 %d，已截断", total, limit)
+	return true
+}
+
+// runTimed 执行任务并记录耗时
+func runTimed(run func() NodeResult) NodeResult {
+	start := time.Now()
+	res := run()
+	res.Duration = time.Since(start)
+	return res
+}
+
+// failedResult 返回请求未能完成的节点结果
+func failedResult(nodeName string, format string, args ...any) NodeResult {
+	return NodeResult{
+		NodeName: nodeName,
+		Status:   "failed",
+		ExitCode: NoExitCode,
+		Error:    fmt.Sprintf(format, args...),
+	}
+}
+
+// executeLocal 在本节点执行命令
+func (d *Dispatcher) executeLocal(ctx context.Context, localExecutor *executor.Executor, nodeName string, cmd string, timeout time.Duration, execOpts executor.Options) NodeResult {
+	logger.Infof("Dispatcher: 本地执行命令: %s, 超时: %s\n", cmd, timeout)
+	_, span := tracing.Start(ctx, "exec.local", tracing.KindInternal)
+	span.SetAttribute("node.name", nodeName)
+	defer span.End()
+
+	res, err := localExecutor.Execute(cmd, timeout, execOpts)
+	if err != nil {
+		logger.Infof("Dispatcher: 本地执行失败: %v\n", err)
+		span.RecordError(err)
+		return failedResult(nodeName, "%v", err)
+	}
+
+	logger.Infof("Dispatcher: 本地执行成功, 退出码: %d, 输出长度: %d\n", res.ExitCode, len(res.Output))
+	span.SetAttribute("exec.exit_code", res.ExitCode)
+
+	// 与 Peer 结果的判断保持一致：退出码非 0 或有错误信息视为失败
+	status := "success"
+	if res.ExitCode != 0 || res.Error != "" {
+		status = "failed"
+		span.SetStatus(tracing.StatusError, res.Error)
+	}
+	return NodeResult{
+		NodeName:    nodeName,
+		Status:      status,
+		ExitCode:    res.ExitCode,
+		Output:      res.Output,
+		Error:       res.Error,
+		KilledBy:    res.KilledBy,
+		Encoding:    res.Encoding,
+		Sandboxed:   res.Sandboxed,
+		Truncated:   res.Truncated,
+		OutputBytes: res.OutputBytes,
+		ErrorBytes:  res.ErrorBytes,
+		Artifact:    res.Artifact,
+	}
+}
+
 // executeOnPeer 在指定的 Peer 节点上执行命令
-func (d *Dispatcher) executeOnPeer(peerURL string, cmd string) NodeResult {
+// 请求头携带 traceparent，使 Peer 端的 Span 加入同一个 Trace
+// timeout 为 Peer 端的执行超时，HTTP 请求额外预留 peerRequestOverhead
+func (d *Dispatcher) executeOnPeer(ctx context.Context, peerURL string, cmd string, timeout time.Duration, execOpts executor.Options) (result NodeResult) {
 	logger.Infof("executeOnPeer: 开始向 peer 执行命令, peerURL: %s, cmd: %s\n", peerURL, cmd)
 
-	reqBody := DispatchRequest{Cmd: cmd}
+	ctx, span := tracing.Start(ctx, "dispatch.peer", tracing.KindClient)
+	span.SetAttribute("peer.url", peerURL)
+	defer func() {
+		if result.Status != "success" {
+			span.SetStatus(tracing.StatusError, result.Error)
+		}
+		span.End()
+	}()
+
+	start := time.Now()
+	defer func() {
+		metrics.DispatchDuration.WithLabelValues(peerURL).Observe(time.Since(start).Seconds())
+	}()
+
+	// 超时按秒向上取整传给 Peer
+	reqBody := DispatchRequest{Cmd: cmd, Timeout: int((timeout + time.Second - 1) / time.Second), Options: execOpts}
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		logger.Infof("executeOnPeer: 序列化请求失败: %v\n", err)
-		return NodeResult{
-			NodeName: peerURL,
-			Status:   "failed",
-			Error:    fmt.Sprintf("marshal request failed: %v", err),
-		}
+		return failedResult(peerURL, "marshal request failed: %v", err)
 	}
 	logger.Infof("executeOnPeer: 请求体序列化成功\n")
 
@@ -160,17 +417,16 @@ func (d *Dispatcher) executeOnPeer(peerURL string, cmd string) NodeResult {
 	// peerURL 应该是完整的 http://host:port
 	url := fmt.Sprintf("%s/internal/exec", peerURL)
 	logger.Infof("executeOnPeer: 构建请求 URL: %s\n", url)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	ctx, cancel := context.WithTimeout(ctx, timeout+peerRequestOverhead)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		logger.Infof("executeOnPeer: 创建请求失败: %v\n", err)
-		return NodeResult{
-			NodeName: peerURL,
-			Status:   "failed",
-			Error:    fmt.Sprintf("create request failed: %v", err),
-		}
+		return failedResult(peerURL, "create request failed: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
 	if d.token != "" {
 		req.Header.Set("X-Cluster-Token", d.token)
 		logger.Infof("executeOnPeer: 设置 Cluster Token\n")
@@ -180,33 +436,36 @@ func (d *Dispatcher) executeOnPeer(peerURL string, cmd string) NodeResult {
 	resp, err := d.httpClient.Do(req)
 	if err != nil {
 		logger.Infof("executeOnPeer: HTTP 请求失败: %v\n", err)
-		return NodeResult{
-			NodeName: peerURL,
-			Status:   "failed",
-			Error:    fmt.Sprintf("request failed: %v", err),
-		}
+		metrics.DispatchErrors.WithLabelValues(peerURL, "request").Inc()
+		return failedResult(peerURL, "request failed: %v", err)
 	}
 	defer resp.Body.Close()
 	logger.Infof("executeOnPeer: HTTP 请求成功, 状态码: %d\n", resp.StatusCode)
+	span.SetAttribute("http.status_code", resp.StatusCode)
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
 		logger.Infof("executeOnPeer: 服务器返回错误状态码, body: %s\n", string(body))
-		return NodeResult{
-			NodeName: peerURL,
-			Status:   "failed",
-			Error:    fmt.Sprintf("server returned %d: %s", resp.StatusCode, string(body)),
-		}
+		metrics.DispatchErrors.WithLabelValues(peerURL, "status").Inc()
+		return failedResult(peerURL, "server returned %d: %s", resp.StatusCode, string(body))
 	}
 
+	body, err := io.ReadAll(io.LimitReader(resp.Body, d.maxBody+1))
+	if err != nil {
+		logger.Infof("executeOnPeer: 读取响应失败: %v\n", err)
+		metrics.DispatchErrors.WithLabelValues(peerURL, "request").Inc()
+		return failedResult(peerURL, "read response failed: %v", err)
+	}
+	if int64(len(body)) > d.maxBody {
+		logger.Warnf("executeOnPeer: Peer %s 的响应超过 %d 字节，丢弃", peerURL, d.maxBody)
+		metrics.DispatchErrors.WithLabelValues(peerURL, "too_large").Inc()
+		return failedResult(peerURL, "response exceeds %d bytes", d.maxBody)
+	}
 	var respData DispatchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+	if err := json.Unmarshal(body, &respData); err != nil {
 		logger.Infof("executeOnPeer: 解析响应失败: %v\n", err)
-		return NodeResult{
-			NodeName: peerURL,
-			Status:   "failed",
-			Error:    fmt.Sprintf("decode response failed: %v", err),
-		}
+		metrics.DispatchErrors.WithLabelValues(peerURL, "decode").Inc()
+		return failedResult(peerURL, "decode response failed: %v", err)
 	}
 	logger.Infof("executeOnPeer: 响应解析成功, 退出码: %d, 输出长度: %d\n", respData.ExitCode, len(respData.Output))
 
@@ -218,60 +477,94 @@ func (d *Dispatcher) executeOnPeer(peerURL string, cmd string) NodeResult {
 
 	logger.Infof("executeOnPeer: peer 执行完成\n")
 	return NodeResult{
-		NodeName: peerURL,
-		Status:   status,
-		Output:   respData.Output,
-		Error:    respData.Error,
+		NodeName:    peerURL,
+		Status:      status,
+		ExitCode:    respData.ExitCode,
+		Output:      respData.Output,
+		Error:       respData.Error,
+		KilledBy:    respData.KilledBy,
+		Encoding:    respData.Encoding,
+		Sandboxed:   respData.Sandboxed,
+		Truncated:   respData.Truncated,
+		OutputBytes: respData.OutputBytes,
+		ErrorBytes:  respData.ErrorBytes,
+		Artifact:    respData.Artifact,
 	}
 }
 
-// aggregateResults 将结果按输出内容进行分组压缩
-func (d *Dispatcher) aggregateResults(results []NodeResult) []AggregatedGroup {
-	logger.Infof("aggregateResults: 开始聚合结果, 结果数量: %d\n", len(results))
+// Aggregate 将结果按输出内容进行分组压缩
+func Aggregate(results []NodeResult) []AggregatedGroup {
+	logger.Infof("Aggregate: 开始聚合结果, 结果数量: %d\n", len(results))
 
 	groupsMap := make(map[string]*AggregatedGroup)
 
 	for i, res := range results {
-		logger.Infof("aggregateResults: 处理结果 [%d], 节点: %s, 状态: %s\n", i, res.NodeName, res.Status)
+		logger.Infof("Aggregate: 处理结果 [%d], 节点: %s, 状态: %s\n", i, res.NodeName, res.Status)
 
-		// 计算指纹: Output + Error + Status
+		// 计算指纹: Output + Error + Status + ExitCode + KilledBy + Sandboxed + Encoding + 截断情况
+		// 输出已由各节点规范化，只有颜色、进度条等差异的输出分到同一组
 		// 简单起见，直接拼接字符串作为 Key
-		key := d.calculateFingerprint(res)
-		logger.Infof("aggregateResults: 计算指纹: %s\n", key)
+		key := calculateFingerprint(res)
+		logger.Infof("Aggregate: 计算指纹: %s\n", key)
 
 		if _, exists := groupsMap[key]; !exists {
-			logger.Infof("aggregateResults: 创建新组\n")
+			logger.Infof("Aggregate: 创建新组\n")
 			groupsMap[key] = &AggregatedGroup{
-				Output: res.Output,
-				Error:  res.Error,
-				Status: res.Status,
-				Nodes:  []string{res.NodeName},
-				Count:  1,
+				Output:      res.Output,
+				Error:       res.Error,
+				Status:      res.Status,
+				ExitCode:    res.ExitCode,
+				KilledBy:    res.KilledBy,
+				Encoding:    res.Encoding,
+				Sandboxed:   res.Sandboxed,
+				Truncated:   res.Truncated,
+				OutputBytes: res.OutputBytes,
+				ErrorBytes:  res.ErrorBytes,
+				Nodes:       []string{res.NodeName},
+				Count:       1,
+				Durations:   make(map[string]int64),
 			}
 		} else {
-			logger.Infof("aggregateResults: 添加到现有组\n")
+			logger.Infof("Aggregate: 添加到现有组\n")
 			groupsMap[key].Nodes = append(groupsMap[key].Nodes, res.NodeName)
 			groupsMap[key].Count++
 		}
+		groupsMap[key].Durations[res.NodeName] = res.Duration.Milliseconds()
+		if res.Artifact != "" {
+			if groupsMap[key].Artifacts == nil {
+				groupsMap[key].Artifacts = make(map[string]string)
+			}
+			groupsMap[key].Artifacts[res.NodeName] = res.Artifact
+		}
 	}
 
 	// 将 Map 转换为 Slice
 	var groups []AggregatedGroup
 	for k, g := range groupsMap {
-		logger.Infof("aggregateResults: 组 [%s], 节点数: %d\n", k, g.Count)
+		logger.Infof("Aggregate: 组 [%s], 节点数: %d\n", k, g.Count)
 		groups = append(groups, *g)
 	}
 
-	logger.Infof("aggregateResults: 聚合完成, 组数: %d\n", len(groups))
+	logger.Infof("Aggregate: 聚合完成, 组数: %d\n", len(groups))
 	return groups
 }
 
 // calculateFingerprint 计算结果的指纹
-func (d *Dispatcher) calculateFingerprint(res NodeResult) string {
+func calculateFingerprint(res NodeResult) string {
 	// 使用 SHA256 计算哈希
 	h := sha256.New()
 	h.Write([]byte(res.Output))
 	h.Write([]byte(res.Error))
 	h.Write([]byte(res.Status))
+	h.Write([]byte(strconv.Itoa(res.ExitCode)))
+	h.Write([]byte(res.KilledBy))
+	if res.Sandboxed {
+		h.Write([]byte("sandboxed"))
+	}
+	h.Write([]byte(res.Encoding))
+	// 截断前的大小不同的输出即使截断后相同也分到不同的组
+	if res.Truncated {
+		fmt.Fprintf(h, "truncated:%d:%d", res.OutputBytes, res.ErrorBytes)
+	}
 	return hex.EncodeToString(h.Sum(nil))
 }
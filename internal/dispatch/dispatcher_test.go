@@ -0,0 +1,168 @@
+package dispatch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// TestMain 先显式初始化 logger，避免懒加载初始化时的死锁
+func TestMain(m *testing.M) {
+	_ = logger.InitLogger(&logger.LogConfig{Level: "error", LogDir: os.TempDir()}, "dispatch_test.log")
+	os.Exit(m.Run())
+}
+
+// newTestPeer 启动一个模拟的 Peer 节点，记录收到的请求并返回指定退出码
+func newTestPeer(t *testing.T, exitCode int, received *[]DispatchRequest, mu *sync.Mutex) string {
+	t.Helper()
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req DispatchRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		*received = append(*received, req)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(DispatchResponse{ExitCode: exitCode, Output: "peer\n"})
+	}))
+	t.Cleanup(peer.Close)
+	return peer.URL
+}
+
+// TestDispatchOptions 验证执行超时和执行参数会传给 Peer，串行策略在失败后跳过剩余节点
+func TestDispatchOptions(t *testing.T) {
+	var mu sync.Mutex
+	var received []DispatchRequest
+	failing := newTestPeer(t, 1, &received, &mu)
+	skipped := newTestPeer(t, 0, &received, &mu)
+
+	d := NewDispatcher([]string{failing, skipped}, "")
+	groups, _ := d.Dispatch(t.Context(), executor.NewExecutor(), "local", "echo hi", Options{
+		Timeout:   1500 * time.Millisecond,
+		SkipLocal: true,
+		Strategy:  StrategySerial,
+		Exec:      executor.Options{Dir: "/tmp", Env: map[string]string{"APP_ENV": "test"}, Stdin: "data"},
+	})
+
+	if len(received) != 1 || received[0].Timeout != 2 {
+		t.Fatalf("Peer 收到的请求 = %+v, 预期只有第一个 Peer 收到 timeout=2 的请求", received)
+	}
+	if got := received[0].Options; got.Dir != "/tmp" || got.Env["APP_ENV"] != "test" || got.Stdin != "data" {
+		t.Errorf("Peer 收到的执行参数 = %+v, 预期包含工作目录、环境变量和标准输入", got)
+	}
+
+	statuses := map[string]string{}
+	exitCodes := map[string]int{}
+	for _, g := range groups {
+		for _, node := range g.Nodes {
+			statuses[node] = g.Status
+			exitCodes[node] = g.ExitCode
+			if _, ok := g.Durations[node]; !ok {
+				t.Errorf("节点 %s 缺少执行耗时", node)
+			}
+		}
+	}
+	if statuses[failing] != "failed" || statuses[skipped] != StatusSkipped {
+		t.Errorf("节点状态 = %v, 预期 %s 失败、%s 被跳过", statuses, failing, skipped)
+	}
+	if exitCodes[failing] != 1 || exitCodes[skipped] != NoExitCode {
+		t.Errorf("节点退出码 = %v, 预期 %s 为 1、%s 为 %d", exitCodes, failing, skipped, NoExitCode)
+	}
+	if _, ok := statuses["local"]; ok {
+		t.Error("SkipLocal 时不应在本节点执行")
+	}
+}
+
+// TestDispatchResponseLimit 验证 Peer 响应超过大小上限时该节点标记为失败，不会读入整个响应
+func TestDispatchResponseLimit(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DispatchResponse{Output: strings.Repeat("x", 4096)})
+	}))
+	t.Cleanup(peer.Close)
+
+	d := NewDispatcher([]string{peer.URL}, "")
+	d.SetResponseLimit(1024)
+	groups, _ := d.Dispatch(t.Context(), executor.NewExecutor(), "local", "echo hi", Options{SkipLocal: true})
+
+	if len(groups) != 1 || groups[0].Status != "failed" || !strings.Contains(groups[0].Error, "response exceeds 1024 bytes") {
+		t.Fatalf("分组 = %+v, 预期 Peer 因响应超过上限而失败", groups)
+	}
+}
+
+// TestClampTimeout 验证执行超时的默认值和上限
+func TestClampTimeout(t *testing.T) {
+	tests := []struct {
+		in, want time.Duration
+	}{
+		{0, DefaultTimeout},
+		{-time.Second, DefaultTimeout},
+		{time.Minute, time.Minute},
+		{2 * MaxTimeout, MaxTimeout},
+	}
+	for _, tt := range tests {
+		if got := ClampTimeout(tt.in); got != tt.want {
+			t.Errorf("ClampTimeout(%v) = %v, 预期 %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestCapGroups 验证响应大小上限优先完整保留较短的字段，其余字段截断并记录原始字节数
+func TestCapGroups(t *testing.T) {
+	long := strings.Repeat("x", 1000)
+	groups := []AggregatedGroup{
+		{Output: "short", Error: ""},
+		{Output: long, Error: long},
+	}
+	if !CapGroups(groups, 405) {
+		t.Fatal("超过上限时应返回 true")
+	}
+	if groups[0].Output != "short" || groups[0].Truncated {
+		t.Errorf("较短的组不应被截断: %+v", groups[0])
+	}
+	g := groups[1]
+	if !g.Truncated || g.OutputBytes != 1000 || g.ErrorBytes != 1000 {
+		t.Errorf("截断的组 = truncated:%v output_bytes:%d error_bytes:%d", g.Truncated, g.OutputBytes, g.ErrorBytes)
+	}
+	if !strings.Contains(g.Output, "[800 bytes truncated]") || !strings.Contains(g.Error, "[800 bytes truncated]") {
+		t.Errorf("截断后的输出 = %q, 错误 = %q", g.Output, g.Error)
+	}
+
+	if CapGroups(groups, 0) || CapGroups([]AggregatedGroup{{Output: "ok"}}, 10) {
+		t.Error("不限制或未超过上限时应返回 false")
+	}
+}
+
+// TestEncodingGroupsAndCap 验证编码不同的结果分到不同的组，base64 输出按 4 字节对齐截断
+func TestEncodingGroupsAndCap(t *testing.T) {
+	groups := Aggregate([]NodeResult{
+		{NodeName: "a", Status: "success", Output: "AAAA"},
+		{NodeName: "b", Status: "success", Output: "AAAA", Encoding: executor.EncodingBase64},
+	})
+	if len(groups) != 2 {
+		t.Fatalf("编码不同的结果应分到不同的组: %+v", groups)
+	}
+
+	raw := []byte(strings.Repeat("\xff\x00\x01", 100))
+	encoded := base64.StdEncoding.EncodeToString(raw)
+	groups = []AggregatedGroup{{Output: encoded, Encoding: executor.EncodingBase64}}
+	if !CapGroups(groups, 101) {
+		t.Fatal("超过上限时应返回 true")
+	}
+	head, tail, ok := strings.Cut(groups[0].Output, "\n... [")
+	if !ok {
+		t.Fatalf("截断后的输出 = %q", groups[0].Output)
+	}
+	tail = tail[strings.Index(tail, "] ...\n")+len("] ...\n"):]
+	for _, part := range []string{head, tail} {
+		if _, err := base64.StdEncoding.DecodeString(part); err != nil || part == "" {
+			t.Errorf("截断后的开头或结尾 %q 无法单独解码: %v", part, err)
+		}
+	}
+}
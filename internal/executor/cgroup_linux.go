@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// cpuPeriod cgroup cpu.max 的周期（微秒）
+const cpuPeriod = 100000
+
+// cgroupRemoveTimeout 命令结束后等待 cgroup 中剩余进程退出并删除目录的最长时间
+const cgroupRemoveTimeout = 2 * time.Second
+
+// prepareCgroup 创建 cgroup v2 父目录，并为其子目录启用 memory 和 cpu 控制器
+// 父目录本身不能包含进程（cgroup v2 的 no internal processes 规则）
+func prepareCgroup(parent string) error {
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return fmt.Errorf("create cgroup %s: %v", parent, err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return fmt.Errorf("%s is not a cgroup v2 directory", parent)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+memory +cpu"), 0); err != nil {
+		return fmt.Errorf("enable memory and cpu controllers in %s: %v", parent, err)
+	}
+	return nil
+}
+
+// cgroup 一次执行独立的 cgroup v2 子目录
+type cgroup struct {
+	dir string
+	fd  *os.File // 子目录的文件描述符，用于在启动时直接将命令放入该 cgroup
+}
+
+// newCgroup 在 parent 下创建子目录并写入内存和 CPU 配额
+func newCgroup(parent string, limits Limits) (*cgroup, error) {
+	dir, err := os.MkdirTemp(parent, "exec-")
+	if err != nil {
+		return nil, fmt.Errorf("create cgroup: %v", err)
+	}
+	cg := &cgroup{dir: dir}
+	if limits.Memory != 0 {
+		if err := cg.write("memory.max", strconv.FormatUint(limits.Memory, 10)); err != nil {
+			cg.release()
+			return nil, err
+		}
+		// 不使用 swap，使内存上限立即生效；未启用 swap 控制时忽略
+		cg.write("memory.swap.max", "0")
+	}
+	if limits.CPUPercent != 0 {
+		quota := limits.CPUPercent * cpuPeriod / 100
+		if err := cg.write("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			cg.release()
+			return nil, err
+		}
+	}
+	if cg.fd, err = os.Open(dir); err != nil {
+		cg.release()
+		return nil, fmt.Errorf("open cgroup: %v", err)
+	}
+	return cg, nil
+}
+
+// write 写入 cgroup 接口文件
+func (c *cgroup) write(name, value string) error {
+	if err := os.WriteFile(filepath.Join(c.dir, name), []byte(value), 0); err != nil {
+		return fmt.Errorf("write cgroup %s: %v", name, err)
+	}
+	return nil
+}
+
+// apply 使命令在启动时直接进入该 cgroup（clone3 CLONE_INTO_CGROUP），不存在先启动后移动的竞争
+func (c *cgroup) apply(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.fd.Fd())
+}
+
+// started 命令已启动，关闭子目录的文件描述符
+func (c *cgroup) started() {
+	if c != nil && c.fd != nil {
+		c.fd.Close()
+		c.fd = nil
+	}
+}
+
+// release 终止 cgroup 中剩余的进程并删除子目录，返回是否发生过 OOM 终止
+func (c *cgroup) release() (oomKilled bool) {
+	if c == nil {
+		return false
+	}
+	c.started()
+	if data, err := os.ReadFile(filepath.Join(c.dir, "memory.events")); err == nil {
+		oomKilled = eventCount(data, "oom_kill") > 0
+	}
+
+	// 命令结束后仍在后台运行的进程一并终止，cgroup.kill 需要 Linux 5.14
+	c.write("cgroup.kill", "1")
+	deadline := time.Now().Add(cgroupRemoveTimeout)
+	for {
+		err := os.Remove(c.dir)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if time.Now().After(deadline) {
+			logger.Warnf("Executor: 删除 cgroup %s 失败: %v", c.dir, err)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return oomKilled
+}
+
+// eventCount 读取 memory.events 等文件中指定事件的计数
+func eventCount(data []byte, name string) uint64 {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if ok && key == name {
+			n, _ := strconv.ParseUint(value, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
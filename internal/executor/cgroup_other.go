@@ -0,0 +1,27 @@
+//go:build !linux
+
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// prepareCgroup 只有 Linux 支持 cgroup v2
+func prepareCgroup(parent string) error {
+	return fmt.Errorf("cgroup is only supported on linux")
+}
+
+// cgroup 非 Linux 平台不使用 cgroup
+type cgroup struct{}
+
+// newCgroup 只有 Linux 支持 cgroup v2
+func newCgroup(parent string, limits Limits) (*cgroup, error) {
+	return nil, fmt.Errorf("cgroup is only supported on linux")
+}
+
+func (c *cgroup) apply(cmd *exec.Cmd) {}
+
+func (c *cgroup) started() {}
+
+func (c *cgroup) release() bool { return false }
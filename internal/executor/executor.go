@@ -1,27 +1,80 @@
 package executor
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/AceDarkknight/shell-executor-mcp/internal/artifacts"
 	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/metrics"
 )
 
+// ErrShuttingDown 表示执行器正在关闭，不再接受新的命令
+var ErrShuttingDown = errors.New("executor is shutting down")
+
 // Executor 负责执行本地 Shell 命令
 type Executor struct {
 	// 可以在这里添加执行超时配置等
 	timeout time.Duration
+	baseEnv []string      // 命令的基础环境变量，nil 表示继承服务器进程的环境变量
+	runAs   *identity     // 运行命令的用户和用户组，nil 表示与服务器相同
+	limits  Limits        // 默认资源限制
+	cgroup  string        // cgroup v2 父目录，为空表示不使用
+	sandbox SandboxConfig // 沙箱配置
+	// maxOutput Execute 每个输出流保留的最大字节数，0 表示 DefaultMaxOutput
+	maxOutput int
+	// artifacts 保存被截断命令完整输出的制品存储，nil 表示不保存
+	artifacts *artifacts.Store
+	// normalize 请求没有指定时使用的输出规范化选项
+	normalize NormalizeOptions
+
+	// 优雅关闭相关字段
+	mu       sync.Mutex         // 保护 running 和 draining
+	running  map[*exec.Cmd]bool // 正在执行的命令，值表示是否已被 KillAll 终止
+	inflight sync.WaitGroup     // 正在执行的命令计数
+	draining bool               // 是否已进入关闭流程
 }
 
 // Result 表示命令执行的结果
 type Result struct {
-	ExitCode int    `json:"exit_code"`
-	Output   string `json:"output"`
-	Error    string `json:"error"`
+	ExitCode  int    `json:"exit_code"` // 进程退出码，未能启动或被信号终止时为 -1
+	Output    string `json:"output"`
+	Error     string `json:"error"`
+	Encoding  string `json:"encoding,omitempty"`  // Output 的编码，空表示 UTF-8 文本，见 EncodingBase64
+	KilledBy  string `json:"killed_by,omitempty"` // 因超时或资源限制被终止时的原因，见 KilledBy* 常量
+	Sandboxed bool   `json:"sandboxed,omitempty"` // 是否在沙箱中运行
+	// Truncated 标准输出或标准错误超过输出上限，只保留了开头和结尾
+	Truncated bool `json:"truncated,omitempty"`
+	// OutputBytes、ErrorBytes 截断前标准输出和标准错误的字节数，只在 Truncated 时设置
+	OutputBytes int64 `json:"output_bytes,omitempty"`
+	ErrorBytes  int64 `json:"error_bytes,omitempty"`
+	// Artifact 保存完整输出的制品 ID，只在 Truncated 且启用了制品时设置
+	Artifact string `json:"artifact,omitempty"`
+}
+
+// Options 单次执行的工作目录、环境变量和标准输入
+// JSON 字段名与 execute_command 的参数一致，可直接嵌入节点之间的请求
+type Options struct {
+	Dir   string            `json:"cwd,omitempty"`   // 工作目录，空表示服务器进程的工作目录
+	Env   map[string]string `json:"env,omitempty"`   // 合并到基础环境变量之上的环境变量
+	Stdin string            `json:"stdin,omitempty"` // 标准输入内容，空表示没有输入
+	// Limits 覆盖默认资源限制的字段（如策略规则中的限制），由各节点按本节点的策略设置，不随请求发送
+	Limits Limits `json:"-"`
+	// Sandbox 是否强制在沙箱中运行（如匹配 sandbox 策略规则），同样由各节点按本节点的策略设置
+	Sandbox bool `json:"-"`
+	// Normalize Execute 结果的输出规范化选项，nil 表示使用执行节点的默认选项
+	Normalize *NormalizeOptions `json:"normalize,omitempty"`
 }
 
 // NewExecutor 创建一个新的执行器实例
@@ -33,20 +86,91 @@ func (e *Executor) SetTimeout(timeout time.Duration) {
 	e.timeout = timeout
 }
 
+// SetArtifacts 设置保存被截断命令完整输出的制品存储
+func (e *Executor) SetArtifacts(store *artifacts.Store) {
+	e.artifacts = store
+}
+
+// SetBaseEnv 设置命令的基础环境变量（KEY=VALUE 形式），之后启动的命令不再继承服务器进程的环境变量
+func (e *Executor) SetBaseEnv(env []string) {
+	e.baseEnv = append([]string{}, env...)
+}
+
+// environ 返回命令的环境变量：基础环境变量加上 extra，同名时 extra 优先
+// 未设置基础环境变量且没有 extra 时返回 nil，即继承服务器进程的环境变量
+func (e *Executor) environ(extra map[string]string) []string {
+	base := e.baseEnv
+	if base == nil {
+		if len(extra) == 0 {
+			return nil
+		}
+		base = os.Environ()
+	}
+	env := make([]string, 0, len(base)+len(extra))
+	for _, kv := range base {
+		name, _, _ := strings.Cut(kv, "=")
+		if _, ok := extra[name]; !ok {
+			env = append(env, kv)
+		}
+	}
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+extra[name])
+	}
+	return env
+}
+
 // Execute 执行指定的 Shell 命令
 // cmd: 要执行的命令字符串
 // timeout: 执行超时时间，0 表示不限制
-func (e *Executor) Execute(cmd string, timeout time.Duration) (*Result, error) {
+// opts: 工作目录、环境变量和标准输入，需由调用方先经过安全策略检查
+// 标准输出和标准错误各自超过输出上限时只保留开头和结尾，启用制品时完整输出保存在制品中
+// 保留的输出按 opts.Normalize 规范化，制品中保存的是原始输出
+func (e *Executor) Execute(cmd string, timeout time.Duration, opts Options) (*Result, error) {
 	logger.Debugf("Executor: 开始执行命令: %s, 超时: %v\n", cmd, timeout)
 
+	capture := e.NewCapture()
+	proc, err := e.Start(cmd, timeout, opts, capture.Stdout(), capture.Stderr())
+	if err != nil {
+		capture.Discard()
+		return nil, err
+	}
+	result := proc.Wait()
+	capture.Finish(result, e.Normalization(opts.Normalize))
+
+	logger.Debugf("Executor: 命令执行完成, 退出码: %d\n", result.ExitCode)
+	return result, nil
+}
+
+// Process 表示一个由 Start 启动的命令
+type Process struct {
+	e         *Executor
+	command   *exec.Cmd
+	timeout   time.Duration
+	start     time.Time
+	timedOut  atomic.Bool // 是否因超时被终止
+	cancelled atomic.Bool // 是否被 Kill 终止
+	limits    Limits      // 本次执行的资源限制
+	cgroup    *cgroup     // 本次执行的 cgroup，未使用时为 nil
+	oomKilled bool        // cgroup 中是否发生过 OOM 终止
+	sandboxed bool        // 是否在沙箱中运行
+	done      chan struct{}
+	result    Result
+}
+
+// Start 在后台启动命令，标准输出和标准错误分别写入 stdout 和 stderr（可以是同一个 Writer）
+// 命令无法启动时同样返回 Process，Wait 得到的结果中包含启动失败的原因
+// 返回的错误只表示命令为空或执行器正在关闭
+func (e *Executor) Start(cmd string, timeout time.Duration, opts Options, stdout, stderr io.Writer) (*Process, error) {
 	if cmd == "" {
 		logger.Debugf("Executor: 命令为空")
 		return nil, fmt.Errorf("command is empty")
 	}
 
-	// 创建输出缓冲区用于捕获标准输出和标准错误
-	var stdout, stderr bytes.Buffer
-
 	var command *exec.Cmd
 	// 检测是否为 Windows 系统
 	if isWindows() {
@@ -57,60 +181,249 @@ func (e *Executor) Execute(cmd string, timeout time.Duration) (*Result, error) {
 		command = exec.Command("/bin/sh", "-c", cmd)
 	}
 
-	// 设置命令的输出缓冲区
-	command.Stdout = &stdout
-	command.Stderr = &stderr
+	command.Dir = opts.Dir
+	command.Env = e.environ(opts.Env)
+	if opts.Stdin != "" {
+		command.Stdin = strings.NewReader(opts.Stdin)
+	}
+
+	// 设置命令的输出
+	command.Stdout = stdout
+	command.Stderr = stderr
+	return e.start(command, timeout, e.limits.Override(opts.Limits), e.sandbox.Enabled || opts.Sandbox)
+}
+
+// StartShell 在后台启动一个从 stdin 读取命令的 /bin/sh，标准输出和标准错误都写入 out
+// 用于持久化的 Shell 会话，不设执行超时，由调用方负责终止；Windows 上不支持
+func (e *Executor) StartShell(stdin io.Reader, out io.Writer) (*Process, error) {
+	if isWindows() {
+		return nil, fmt.Errorf("shell sessions are not supported on windows")
+	}
+	command := exec.Command("/bin/sh")
+	command.Env = e.environ(nil)
+	command.Stdin = stdin
+	command.Stdout = out
+	command.Stderr = out
+	// Shell 退出后不等待仍持有输出的后台进程
+	command.WaitDelay = time.Second
+	return e.start(command, 0, e.limits, e.sandbox.Enabled)
+}
+
+// start 登记并以指定的资源限制启动命令，sandboxed 表示在沙箱中运行；执行器正在关闭时返回 ErrShuttingDown
+func (e *Executor) start(command *exec.Cmd, timeout time.Duration, limits Limits, sandboxed bool) (*Process, error) {
+	if !e.acquire() {
+		logger.Debugf("Executor: 执行器正在关闭，拒绝执行命令")
+		return nil, ErrShuttingDown
+	}
+
+	// 在独立进程组中运行，超时或关闭时可终止整个进程树
+	setProcessGroup(command)
+
+	p := &Process{
+		e:         e,
+		command:   command,
+		timeout:   timeout,
+		limits:    limits,
+		sandboxed: sandboxed,
+		start:     time.Now(),
+		done:      make(chan struct{}),
+	}
+
+	logger.Debugf("Executor: 开始运行命令...\n")
+	metrics.ActiveExecutions.Inc()
+	if err := e.prepareLimits(p); err != nil {
+		logger.Warnf("Executor: 设置运行用户、资源限制或沙箱失败: %v", err)
+		p.finish(err, false)
+		return p, nil
+	}
+	err := command.Start()
+	p.cgroup.started()
+	if err != nil {
+		p.oomKilled = p.cgroup.release()
+		p.finish(err, false)
+		return p, nil
+	}
+	p.result.Sandboxed = sandboxed
+	e.track(command)
+	go p.wait()
+	return p, nil
+}
 
-	// 设置超时
-	var timer *time.Timer
-	if timeout > 0 {
-		logger.Debugf("Executor: 设置超时定时器: %v\n", timeout)
-		timer = time.AfterFunc(timeout, func() {
-			if command.Process != nil {
-				logger.Debugf("Executor: 命令执行超时，终止进程\n")
-				command.Process.Kill()
-			}
+// wait 等待命令结束，超时后终止整个进程组
+func (p *Process) wait() {
+	if p.timeout > 0 {
+		logger.Debugf("Executor: 设置超时定时器: %v\n", p.timeout)
+		timer := time.AfterFunc(p.timeout, func() {
+			logger.Debugf("Executor: 命令执行超时，终止进程组\n")
+			p.timedOut.Store(true)
+			killProcessGroup(p.command)
 		})
-		// 确保在函数返回前停止 timer，避免资源泄漏
+		// 确保命令结束后停止 timer，避免资源泄漏
 		defer timer.Stop()
 	}
 
-	logger.Debugf("Executor: 开始运行命令...\n")
-	err := command.Run()
+	err := p.command.Wait()
+	p.oomKilled = p.cgroup.release()
+	p.finish(err, p.e.untrack(p.command))
+}
+
+// finish 记录执行结果和指标，并释放执行器中的登记
+func (p *Process) finish(err error, killed bool) {
+	defer p.e.inflight.Done()
+	defer close(p.done)
+	metrics.ActiveExecutions.Dec()
+	status := "success"
 
-	result := &Result{
-		Output: stdout.String(),
+	// 使用进程的真实退出码，未能启动或被信号终止时为 -1
+	exitCode := -1
+	if p.command.ProcessState != nil {
+		exitCode = p.command.ProcessState.ExitCode()
 	}
 
 	if err != nil {
 		// 命令执行失败
 		logger.Debugf("Executor: 命令执行失败, 错误: %v\n", err)
-		result.Error = err.Error()
-		result.ExitCode = -1
+		p.result.Error = err.Error()
+		p.result.ExitCode = exitCode
 
-		// 如果是超时导致的错误
-		if timeout > 0 && strings.Contains(err.Error(), "signal: killed") {
+		// 如果是关闭、取消或超时导致的错误
+		status = "failed"
+		switch {
+		case killed:
+			logger.Debugf("Executor: 命令因服务器关闭被终止\n")
+			p.result.Error = "killed on shutdown"
+			status = "killed"
+		case p.cancelled.Load():
+			logger.Debugf("Executor: 命令被取消\n")
+			p.result.Error = "cancelled"
+			status = "cancelled"
+		case p.timedOut.Load():
 			logger.Debugf("Executor: 命令执行超时\n")
-			result.Error = "execution timeout"
+			p.result.Error = "execution timeout"
+			p.result.KilledBy = KilledByTimeout
+			status = "timeout"
+		default:
+			p.result.KilledBy = limitKilledBy(p.command.ProcessState, p.limits)
 		}
-	} else {
-		// 命令执行成功，设置退出码为 0
-		result.ExitCode = 0
+	}
+	// 命令中的某个进程被 OOM 终止时，即使命令本身正常退出也需要报告
+	if p.oomKilled && p.result.KilledBy == "" && !killed && !p.cancelled.Load() {
+		p.result.KilledBy = KilledByMemory
+	}
+	if p.result.KilledBy != "" && p.result.KilledBy != KilledByTimeout {
+		logger.Debugf("Executor: 命令超过资源限制被终止: %s\n", p.result.KilledBy)
+		p.result.Error = "resource limit exceeded: " + p.result.KilledBy
+		status = "limit"
 	}
 
-	logger.Debugf("Executor: 标准输出长度: %d\n", len(result.Output))
+	metrics.Executions.WithLabelValues(status, strconv.Itoa(exitCode)).Inc()
+	metrics.ExecutionDuration.WithLabelValues(status).Observe(time.Since(p.start).Seconds())
+}
 
-	// 合并 stderr 到 error 字段，如果存在
-	if stderr.Len() > 0 {
-		if result.Error != "" {
-			result.Error += "\n"
-		}
-		result.Error += stderr.String()
-		logger.Debugf("Executor: 合并标准错误到错误字段\n")
+// Sandboxed 返回命令是否在沙箱中运行，命令结束前也可以调用
+func (p *Process) Sandboxed() bool {
+	return p.sandboxed
+}
+
+// Wait 等待命令结束并返回执行结果，Output 为空，输出已写入 Start 传入的 Writer
+func (p *Process) Wait() *Result {
+	<-p.done
+	result := p.result
+	return &result
+}
+
+// Done 返回命令结束时关闭的 channel
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Kill 终止命令所在的整个进程组，命令结果的错误信息为 cancelled
+func (p *Process) Kill() {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+	p.cancelled.Store(true)
+	if p.command.Process != nil {
+		logger.Debugf("Executor: 取消命令，终止进程组, pid=%d", p.command.Process.Pid)
+		killProcessGroup(p.command)
+	}
+}
+
+// acquire 登记一次新的执行，执行器关闭后返回 false
+func (e *Executor) acquire() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.draining {
+		return false
 	}
+	e.inflight.Add(1)
+	return true
+}
 
-	logger.Debugf("Executor: 命令执行完成, 退出码: %d\n", result.ExitCode)
-	return result, nil
+// track 记录正在运行的命令
+func (e *Executor) track(cmd *exec.Cmd) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running == nil {
+		e.running = make(map[*exec.Cmd]bool)
+	}
+	e.running[cmd] = false
+}
+
+// untrack 移除已结束的命令，返回该命令是否被 KillAll 终止
+func (e *Executor) untrack(cmd *exec.Cmd) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	killed := e.running[cmd]
+	delete(e.running, cmd)
+	return killed
+}
+
+// Running 返回当前正在执行的命令数量
+func (e *Executor) Running() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.running)
+}
+
+// Drain 停止接受新命令，并等待正在执行的命令结束
+// ctx 超时或取消时返回 ctx.Err()，此时仍在执行的命令不会被终止，需调用 KillAll
+func (e *Executor) Drain(ctx context.Context) error {
+	e.mu.Lock()
+	e.draining = true
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// KillAll 终止所有正在执行命令的进程组，返回被终止的命令数量
+func (e *Executor) KillAll() int {
+	e.mu.Lock()
+	cmds := make([]*exec.Cmd, 0, len(e.running))
+	for cmd := range e.running {
+		e.running[cmd] = true
+		cmds = append(cmds, cmd)
+	}
+	e.mu.Unlock()
+
+	for _, cmd := range cmds {
+		logger.Warnf("Executor: 强制终止进程组, pid=%d", cmd.Process.Pid)
+		killProcessGroup(cmd)
+	}
+	return len(cmds)
 }
 
 // isWindows 检测当前操作系统是否为 Windows
@@ -0,0 +1,294 @@
+//go:build !windows
+
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/artifacts"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// TestMain 先显式初始化 logger，避免懒加载初始化时的死锁
+// 资源限制和沙箱通过重新执行测试程序自身设置，需先调用 InitHelper
+func TestMain(m *testing.M) {
+	InitHelper()
+	_ = logger.InitLogger(&logger.LogConfig{Level: "error", LogDir: os.TempDir()}, "executor_test.log")
+	os.Exit(m.Run())
+}
+
+// waitRunning 等待执行器中出现 n 个正在执行的命令
+func waitRunning(t *testing.T, e *Executor, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for e.Running() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待 %d 个命令开始执行超时，当前: %d", n, e.Running())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestDrainWaitsForInflight 验证 Drain 等待正在执行的命令结束，并拒绝新命令
+func TestDrainWaitsForInflight(t *testing.T) {
+	e := NewExecutor()
+
+	done := make(chan *Result, 1)
+	go func() {
+		res, _ := e.Execute("sleep 0.3; echo ok", 5*time.Second, Options{})
+		done <- res
+	}()
+	waitRunning(t, e, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := e.Drain(ctx); err != nil {
+		t.Fatalf("Drain 失败: %v", err)
+	}
+	if res := <-done; res.Output != "ok\n" || res.ExitCode != 0 {
+		t.Errorf("正在执行的命令结果错误: %+v", res)
+	}
+
+	if _, err := e.Execute("echo late", time.Second, Options{}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("关闭后执行命令应返回 ErrShuttingDown, 实际: %v", err)
+	}
+}
+
+// TestKillAllKillsProcessGroup 验证 Drain 超时后 KillAll 会终止整个进程组
+func TestKillAllKillsProcessGroup(t *testing.T) {
+	e := NewExecutor()
+
+	done := make(chan *Result, 1)
+	go func() {
+		// 管道使 sh 派生子进程，仅终止 sh 时 cat 会继续持有输出管道
+		res, _ := e.Execute("sleep 30 | cat", 0, Options{})
+		done <- res
+	}()
+	waitRunning(t, e, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := e.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain 应超时, 实际: %v", err)
+	}
+	if n := e.KillAll(); n != 1 {
+		t.Fatalf("KillAll 返回 %d, 预期 1", n)
+	}
+
+	select {
+	case res := <-done:
+		if res.Error != "killed on shutdown" || res.ExitCode != -1 {
+			t.Errorf("被终止命令的结果错误: %+v", res)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("KillAll 后命令未结束")
+	}
+}
+
+// TestExecuteOptions 验证工作目录、标准输入和环境变量，设置基础环境变量后不继承服务器进程的环境变量
+func TestExecuteOptions(t *testing.T) {
+	t.Setenv("EXECUTOR_TEST_SECRET", "leaked")
+	e := NewExecutor()
+	e.SetBaseEnv([]string{"PATH=" + os.Getenv("PATH"), "GREETING=base"})
+
+	res, err := e.Execute(`pwd; cat; echo "$GREETING $NAME ${EXECUTOR_TEST_SECRET:-unset}"`, 5*time.Second, Options{
+		Dir:   "/tmp",
+		Env:   map[string]string{"GREETING": "hello", "NAME": "world"},
+		Stdin: "from stdin\n",
+	})
+	if err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if want := "/tmp\nfrom stdin\nhello world unset\n"; res.Output != want {
+		t.Fatalf("输出 = %q, 预期 %q", res.Output, want)
+	}
+}
+
+// TestExecuteLimits 验证资源限制：策略覆盖默认限制，超过 CPU 时间和文件大小限制时报告终止原因
+func TestExecuteLimits(t *testing.T) {
+	e := NewExecutor()
+	if err := e.Configure(Config{Limits: Limits{OpenFiles: 64, CPUSeconds: 30}}); err != nil {
+		t.Fatalf("配置执行器失败: %v", err)
+	}
+
+	res, err := e.Execute("ulimit -n; ulimit -t", 5*time.Second, Options{Limits: Limits{CPUSeconds: 1}})
+	if err != nil || res.Output != "64\n1\n" {
+		t.Fatalf("执行结果 = %+v, %v, 预期打开文件数 64、CPU 时间被覆盖为 1", res, err)
+	}
+
+	res, _ = e.Execute("while :; do :; done", 10*time.Second, Options{Limits: Limits{CPUSeconds: 1}})
+	if res.KilledBy != KilledByCPU {
+		t.Errorf("超过 CPU 时间的结果 = %+v, 预期 killed_by=%s", res, KilledByCPU)
+	}
+
+	file := t.TempDir() + "/big"
+	res, _ = e.Execute("head -c 10000 /dev/zero > "+file, 5*time.Second, Options{Limits: Limits{FileSize: 1000}})
+	if res.KilledBy != KilledByFileSize {
+		t.Errorf("超过文件大小的结果 = %+v, 预期 killed_by=%s", res, KilledByFileSize)
+	}
+
+	res, _ = e.Execute("sleep 5", 100*time.Millisecond, Options{})
+	if res.KilledBy != KilledByTimeout {
+		t.Errorf("超时的结果 = %+v, 预期 killed_by=%s", res, KilledByTimeout)
+	}
+}
+
+// TestExecuteRunAs 验证以其他用户运行命令，需要 root 权限
+func TestExecuteRunAs(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("需要 root 权限")
+	}
+	e := NewExecutor()
+	if err := e.Configure(Config{RunAsUser: "65534", RunAsGroup: "65534"}); err != nil {
+		t.Fatalf("配置执行器失败: %v", err)
+	}
+	// 分别验证直接切换用户和由设置资源限制的辅助进程切换用户
+	for _, limits := range []Limits{{}, {Processes: 1000}} {
+		res, err := e.Execute("id -u; id -g; id -G", 5*time.Second, Options{Limits: limits})
+		if err != nil || res.Output != "65534\n65534\n65534\n" {
+			t.Fatalf("限制 %+v 的执行结果 = %+v, %v, 预期以 65534 运行且没有附加用户组", limits, res, err)
+		}
+	}
+	if err := e.Configure(Config{RunAsUser: "no-such-user-for-test"}); err == nil {
+		t.Error("不存在的用户应返回错误")
+	}
+}
+
+// TestExecuteSandbox 验证沙箱中根文件系统只读、/tmp 私有、可写路径可写、PID 和网络隔离且没有能力
+func TestExecuteSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("沙箱只支持 Linux")
+	}
+	// 可写路径不能位于沙箱私有的 /tmp 下，在当前目录中创建
+	dir, err := os.MkdirTemp(".", "sandbox-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	writable, _ := filepath.Abs(dir)
+	outside := filepath.Join(writable, "..", filepath.Base(writable)+".outside")
+	t.Cleanup(func() { os.Remove(outside) })
+
+	e := NewExecutor()
+	if err := e.Configure(Config{Sandbox: SandboxConfig{WritablePaths: []string{writable}}}); err != nil {
+		t.Fatalf("配置执行器失败: %v", err)
+	}
+	cmd := "echo $$; touch " + outside + " 2>/dev/null && echo rw || echo ro; " +
+		"touch " + writable + "/f && echo writable; ls -A /tmp | wc -l; grep -c : /proc/net/dev; grep CapEff /proc/self/status"
+	res, err := e.Execute(cmd, 5*time.Second, Options{Sandbox: true})
+	if err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if res.ExitCode == helperFailedExitCode && strings.Contains(res.Error, "sandbox") {
+		t.Skipf("当前环境不支持沙箱: %s", res.Error)
+	}
+	want := "1\nro\nwritable\n0\n1\nCapEff:\t0000000000000000\n"
+	if res.Output != want || !res.Sandboxed {
+		t.Fatalf("沙箱执行结果 = %+v, 预期输出 %q 且 Sandboxed 为 true", res, want)
+	}
+	if _, err := os.Stat(filepath.Join(writable, "f")); err != nil {
+		t.Errorf("可写路径中创建的文件应在沙箱外可见: %v", err)
+	}
+
+	res, err = e.Execute("echo $$", 5*time.Second, Options{})
+	if err != nil || res.Sandboxed || res.Output == "1\n" {
+		t.Errorf("未要求沙箱的执行结果 = %+v, %v, 预期不在沙箱中运行", res, err)
+	}
+	if err := e.Configure(Config{Sandbox: SandboxConfig{WritablePaths: []string{"/tmp/x"}}}); err == nil {
+		t.Error("/tmp 下的可写路径应返回错误")
+	}
+}
+
+// TestExecuteTruncatesOutput 验证超过输出上限时只保留开头和结尾，报告原始字节数，并在制品中保存完整输出
+func TestExecuteTruncatesOutput(t *testing.T) {
+	store, err := artifacts.NewStore(artifacts.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("创建制品存储失败: %v", err)
+	}
+	e := NewExecutor()
+	if err := e.Configure(Config{MaxOutput: 1000}); err != nil {
+		t.Fatalf("配置执行器失败: %v", err)
+	}
+	e.SetArtifacts(store)
+
+	res, err := e.Execute("printf BEGIN; head -c 5000 /dev/zero | tr '\\0' a; printf END; echo err >&2", 5*time.Second, Options{})
+	if err != nil {
+		t.Fatalf("执行失败: %v", err)
+	}
+	if !res.Truncated || res.OutputBytes != 5008 || res.ErrorBytes != 4 || res.Artifact == "" {
+		t.Fatalf("截断结果 = truncated:%v output_bytes:%d error_bytes:%d artifact:%q", res.Truncated, res.OutputBytes, res.ErrorBytes, res.Artifact)
+	}
+	if !strings.HasPrefix(res.Output, "BEGIN") || !strings.HasSuffix(res.Output, "END") ||
+		!strings.Contains(res.Output, "[4008 bytes truncated]") || res.Error != "err\n" {
+		t.Errorf("截断后的输出 = %q, 错误 = %q", res.Output, res.Error)
+	}
+	chunk, err := store.Read(res.Artifact, artifacts.StreamStdout, 0, 0)
+	if err != nil {
+		t.Fatalf("读取制品失败: %v", err)
+	}
+	if chunk.Size != 5008 || !chunk.Complete || !chunk.EOF || !strings.HasSuffix(chunk.Data, "aaaEND") {
+		t.Errorf("制品内容 = size:%d complete:%v eof:%v", chunk.Size, chunk.Complete, chunk.EOF)
+	}
+
+	res, err = e.Execute("echo short", 5*time.Second, Options{})
+	if err != nil || res.Truncated || res.Artifact != "" || res.Output != "short\n" {
+		t.Errorf("未超过上限的执行结果 = %+v, %v", res, err)
+	}
+}
+
+// TestTruncateUTF8 验证截断不会产生不完整的 UTF-8 字符
+func TestTruncateUTF8(t *testing.T) {
+	s := strings.Repeat("中", 100)
+	out, truncated := Truncate(s, 31)
+	if !truncated || !utf8.ValidString(out) {
+		t.Fatalf("Truncate 结果 = %q, %v", out, truncated)
+	}
+	if want := strings.Repeat("中", 5) + truncationMarker(270) + strings.Repeat("中", 5); out != want {
+		t.Errorf("Truncate 结果 = %q, 预期 %q", out, want)
+	}
+	if out, truncated := Truncate(s, len(s)); truncated || out != s {
+		t.Errorf("不超过上限时应原样返回")
+	}
+}
+
+// TestNormalize 验证去掉转义序列、折叠回车，以及无效 UTF-8 输出的 base64 编码
+func TestNormalize(t *testing.T) {
+	n := NormalizeOptions{StripANSI: true, CollapseCR: true}
+	in := "\x1b[32mok\x1b[0m\x07\n 10%\r 50%\r100%\x1b[K\r\n\x1b]0;title\x07done\ttab\r\n"
+	if out, enc := n.Apply(in); out != "ok\n100%\ndone\ttab\n" || enc != "" {
+		t.Errorf("Apply(%q) = %q, %q", in, out, enc)
+	}
+	if out, _ := (NormalizeOptions{}).Apply(in); out != in {
+		t.Errorf("未启用任何选项时应原样返回: %q", out)
+	}
+
+	bin := "\xff\x00\x1b[1mbin"
+	if out, enc := n.Apply(bin); enc != EncodingBase64 || out != base64.StdEncoding.EncodeToString([]byte(bin)) {
+		t.Errorf("二进制输出 Apply = %q, %q", out, enc)
+	}
+	if out := n.Text("bad\xff\x1b[31m!"); out != "bad�!" {
+		t.Errorf("Text = %q", out)
+	}
+
+	e := NewExecutor()
+	if err := e.Configure(Config{Normalize: NormalizeOptions{StripANSI: true}}); err != nil {
+		t.Fatalf("配置执行器失败: %v", err)
+	}
+	res, err := e.Execute(`printf '\033[1mbold\033[0m\n'; printf '\377\376' >&2`, 5*time.Second, Options{})
+	if err != nil || res.Output != "bold\n" || res.Encoding != "" || res.Error != "�" {
+		t.Errorf("默认选项的执行结果 = %+v, %v", res, err)
+	}
+	res, err = e.Execute(`printf 'a\033[1m\377'`, 5*time.Second, Options{Normalize: &NormalizeOptions{}})
+	if err != nil || res.Encoding != EncodingBase64 || res.Output != base64.StdEncoding.EncodeToString([]byte("a\x1b[1m\xff")) {
+		t.Errorf("二进制输出的执行结果 = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,166 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// 命令因超时或资源限制被终止时 Result.KilledBy 的取值
+const (
+	KilledByTimeout  = "timeout"   // 超过执行超时
+	KilledByCPU      = "cpu"       // 超过 CPU 时间限制（RLIMIT_CPU）
+	KilledByFileSize = "file_size" // 写入的文件超过大小限制（RLIMIT_FSIZE）
+	KilledByMemory   = "memory"    // cgroup 内存超过 memory.max 被 OOM 终止
+)
+
+// Limits 命令的资源限制，0 表示不限制
+// CPU 时间、地址空间、打开文件数、进程数和文件大小通过 setrlimit 设置，对命令启动的每个进程分别生效；
+// 内存和 CPU 配额通过每次执行独立的 cgroup v2 子目录设置，对整个命令生效，需要配置 Config.Cgroup
+type Limits struct {
+	CPUSeconds   uint64 `json:"cpu_seconds,omitempty"`   // 每个进程的 CPU 时间（秒）
+	AddressSpace uint64 `json:"address_space,omitempty"` // 每个进程的虚拟地址空间（字节）
+	OpenFiles    uint64 `json:"open_files,omitempty"`    // 每个进程的最大打开文件数
+	Processes    uint64 `json:"processes,omitempty"`     // 运行用户的最大进程数，对 root 不生效
+	FileSize     uint64 `json:"file_size,omitempty"`     // 可写入的最大文件大小（字节）
+	Memory       uint64 `json:"memory,omitempty"`        // 整个命令的内存上限（字节），cgroup memory.max
+	CPUPercent   uint64 `json:"cpu_percent,omitempty"`   // 整个命令的 CPU 配额，100 表示一个 CPU，cgroup cpu.max
+}
+
+// Override 返回用 o 中非 0 的字段覆盖后的限制
+func (l Limits) Override(o Limits) Limits {
+	override := func(dst *uint64, v uint64) {
+		if v != 0 {
+			*dst = v
+		}
+	}
+	override(&l.CPUSeconds, o.CPUSeconds)
+	override(&l.AddressSpace, o.AddressSpace)
+	override(&l.OpenFiles, o.OpenFiles)
+	override(&l.Processes, o.Processes)
+	override(&l.FileSize, o.FileSize)
+	override(&l.Memory, o.Memory)
+	override(&l.CPUPercent, o.CPUPercent)
+	return l
+}
+
+// hasRlimits 是否需要通过 setrlimit 设置限制
+func (l Limits) hasRlimits() bool {
+	return l.CPUSeconds != 0 || l.AddressSpace != 0 || l.OpenFiles != 0 || l.Processes != 0 || l.FileSize != 0
+}
+
+// hasCgroupLimits 是否设置了需要 cgroup 的限制
+func (l Limits) hasCgroupLimits() bool {
+	return l.Memory != 0 || l.CPUPercent != 0
+}
+
+// Config 执行器配置
+type Config struct {
+	RunAsUser  string        `json:"run_as_user"`  // 运行命令的用户（名称或 UID），为空表示与服务器相同；服务器需以 root 运行
+	RunAsGroup string        `json:"run_as_group"` // 运行命令的用户组（名称或 GID），为空表示 run_as_user 的主组
+	Limits     Limits        `json:"limits"`       // 默认资源限制，可被策略规则覆盖
+	Cgroup     string        `json:"cgroup"`       // cgroup v2 父目录（如 /sys/fs/cgroup/shell-executor），每次执行在其下创建子目录，为空表示不使用
+	Sandbox    SandboxConfig `json:"sandbox"`      // 命名空间沙箱，仅 Linux 支持
+	MaxOutput  int           `json:"max_output"`   // execute_command 每个输出流保留的最大字节数，超出时只保留开头和结尾，默认 1 MiB
+	// Normalize 请求没有指定 normalize 时使用的输出规范化选项，默认不做处理
+	Normalize NormalizeOptions `json:"normalize"`
+}
+
+// identity 运行命令的用户和用户组
+type identity struct {
+	UID uint32 `json:"uid"`
+	GID uint32 `json:"gid"`
+}
+
+// Configure 设置运行命令的用户、默认资源限制、cgroup、沙箱、输出上限和默认的输出规范化选项
+// 用户或用户组不存在、cgroup 目录不可用、沙箱的可写路径无效或当前平台不支持时返回错误
+func (e *Executor) Configure(cfg Config) error {
+	if cfg.RunAsUser != "" || cfg.RunAsGroup != "" {
+		id, err := lookupIdentity(cfg.RunAsUser, cfg.RunAsGroup)
+		if err != nil {
+			return err
+		}
+		e.runAs = id
+	}
+	if cfg.Limits.hasRlimits() && !rlimitSupported {
+		return fmt.Errorf("resource limits are not supported on this platform")
+	}
+	if cfg.Cgroup != "" {
+		if err := prepareCgroup(cfg.Cgroup); err != nil {
+			return err
+		}
+	} else if cfg.Limits.hasCgroupLimits() {
+		return fmt.Errorf("memory and cpu_percent limits require cgroup")
+	}
+	if cfg.Sandbox.Enabled && !sandboxSupported {
+		return fmt.Errorf("sandbox is only supported on linux")
+	}
+	if err := cfg.Sandbox.validate(); err != nil {
+		return err
+	}
+	e.limits = cfg.Limits
+	e.cgroup = cfg.Cgroup
+	e.sandbox = cfg.Sandbox
+	e.maxOutput = cfg.MaxOutput
+	e.normalize = cfg.Normalize
+	return nil
+}
+
+// helperSpec 传给辅助进程的资源限制、运行用户和沙箱参数
+type helperSpec struct {
+	Limits  Limits       `json:"limits"`
+	RunAs   *identity    `json:"run_as,omitempty"`  // 不使用沙箱时切换的用户，沙箱中的用户由用户命名空间映射
+	Sandbox *sandboxSpec `json:"sandbox,omitempty"` // 为 nil 表示不使用沙箱
+}
+
+// selfPath 返回服务器可执行文件的路径，用于以辅助进程的方式设置资源限制
+var selfPath = sync.OnceValues(os.Executable)
+
+// warnNoCgroup 策略规则设置了内存或 CPU 配额但没有配置 cgroup 时只提示一次
+var warnNoCgroup sync.Once
+
+// prepareLimits 为命令设置运行用户、资源限制、沙箱和 cgroup，cgroup 需在命令结束后释放
+// 需要 setrlimit 时由辅助进程先设置限制再切换用户，服务器可执行文件不需要对 run_as_user 可执行；
+// 使用沙箱时辅助进程已经以 run_as_user 的身份运行在用户命名空间中，服务器可执行文件需要对其可执行
+func (e *Executor) prepareLimits(p *Process) error {
+	var sandbox *sandboxSpec
+	if p.sandboxed {
+		if !sandboxSupported {
+			return fmt.Errorf("sandbox is only supported on linux")
+		}
+		sandbox = &sandboxSpec{WritablePaths: e.sandbox.WritablePaths, Dir: p.command.Dir}
+		applySandbox(p.command, e.sandbox, e.runAs)
+	}
+	if p.limits.hasRlimits() || sandbox != nil {
+		self, err := selfPath()
+		if err != nil {
+			return fmt.Errorf("resource limits: %v", err)
+		}
+		spec := helperSpec{Limits: p.limits, Sandbox: sandbox}
+		if sandbox == nil {
+			spec.RunAs = e.runAs
+		}
+		if err := wrapWithHelper(p.command, self, spec); err != nil {
+			return err
+		}
+	} else if e.runAs != nil {
+		setIdentity(p.command, e.runAs)
+	}
+	if e.cgroup == "" {
+		if p.limits.hasCgroupLimits() {
+			warnNoCgroup.Do(func() {
+				logger.Warnf("Executor: 未配置 cgroup，忽略内存和 CPU 配额限制")
+			})
+		}
+		return nil
+	}
+	cg, err := newCgroup(e.cgroup, p.limits)
+	if err != nil {
+		return err
+	}
+	cg.apply(p.command)
+	p.cgroup = cg
+	return nil
+}
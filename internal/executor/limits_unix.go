@@ -0,0 +1,189 @@
+//go:build !windows
+
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// rlimitSupported 当前平台是否支持 setrlimit
+const rlimitSupported = true
+
+// helperArg 作为第一个参数时，表示当前进程是执行器为设置资源限制或沙箱启动的辅助进程
+const helperArg = "__shell_executor_rlimit"
+
+// helperFailedExitCode 辅助进程无法设置资源限制、沙箱或执行命令时的退出码
+const helperFailedExitCode = 126
+
+// InitHelper 如果当前进程是执行器启动的辅助进程，设置资源限制和沙箱后执行命令，不会返回；否则直接返回
+// 资源限制和沙箱的文件系统只能由命令自身的进程设置，执行器以 "<服务器> __shell_executor_rlimit <限制、用户和沙箱> /bin/sh -c <命令>"
+// 的方式启动服务器自身，因此需在 main 函数开始处调用（使用资源限制或沙箱的测试需在 TestMain 中调用）
+func InitHelper() {
+	if len(os.Args) < 4 || os.Args[1] != helperArg {
+		return
+	}
+	if err := runHelper(os.Args[2], os.Args[3:]); err != nil {
+		fmt.Fprintf(os.Stderr, "shell-executor: %v\n", err)
+		os.Exit(helperFailedExitCode)
+	}
+}
+
+// runHelper 设置沙箱的文件系统、资源限制、切换运行用户（或放弃沙箱中的能力）后以 argv 替换当前进程
+func runHelper(data string, argv []string) error {
+	var spec helperSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		return fmt.Errorf("invalid limits: %v", err)
+	}
+	// 能力和安全位只作用于当前线程，需在同一线程上设置并执行命令
+	runtime.LockOSThread()
+	if spec.Sandbox != nil {
+		if err := enterSandbox(spec.Sandbox); err != nil {
+			return fmt.Errorf("sandbox: %v", err)
+		}
+	}
+	for _, rl := range rlimits(spec.Limits) {
+		if err := unix.Setrlimit(rl.resource, &unix.Rlimit{Cur: rl.soft, Max: rl.hard}); err != nil {
+			return fmt.Errorf("set %s limit: %v", rl.name, err)
+		}
+	}
+	if id := spec.RunAs; id != nil {
+		if err := syscall.Setgroups([]int{}); err != nil {
+			return fmt.Errorf("setgroups: %v", err)
+		}
+		if err := syscall.Setgid(int(id.GID)); err != nil {
+			return fmt.Errorf("setgid %d: %v", id.GID, err)
+		}
+		if err := syscall.Setuid(int(id.UID)); err != nil {
+			return fmt.Errorf("setuid %d: %v", id.UID, err)
+		}
+	}
+	if spec.Sandbox != nil {
+		if err := dropCapabilities(); err != nil {
+			return fmt.Errorf("sandbox: %v", err)
+		}
+	}
+	return syscall.Exec(argv[0], argv, os.Environ())
+}
+
+// rlimit 单项 setrlimit 限制
+type rlimit struct {
+	name       string
+	resource   int
+	soft, hard uint64
+}
+
+// rlimits 将 Limits 转换为需要设置的 setrlimit 限制
+// CPU 时间的硬限制比软限制多 1 秒，进程先收到 SIGXCPU，忽略该信号时再被 SIGKILL 终止
+func rlimits(l Limits) []rlimit {
+	var list []rlimit
+	add := func(name string, resource int, soft, hard uint64) {
+		if soft != 0 {
+			list = append(list, rlimit{name: name, resource: resource, soft: soft, hard: hard})
+		}
+	}
+	add("cpu", unix.RLIMIT_CPU, l.CPUSeconds, l.CPUSeconds+1)
+	add("address space", unix.RLIMIT_AS, l.AddressSpace, l.AddressSpace)
+	add("open files", unix.RLIMIT_NOFILE, l.OpenFiles, l.OpenFiles)
+	add("processes", unix.RLIMIT_NPROC, l.Processes, l.Processes)
+	add("file size", unix.RLIMIT_FSIZE, l.FileSize, l.FileSize)
+	return list
+}
+
+// wrapWithHelper 改为通过服务器自身的辅助进程启动命令，由辅助进程设置资源限制、运行用户和沙箱
+func wrapWithHelper(cmd *exec.Cmd, self string, spec helperSpec) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	cmd.Args = append([]string{self, helperArg, string(data), cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = self
+	return nil
+}
+
+// lookupIdentity 解析运行命令的用户和用户组，支持名称或数字 ID
+// 只指定用户时使用该用户的主组；只指定用户组时用户与服务器相同
+func lookupIdentity(userName, groupName string) (*identity, error) {
+	id := &identity{UID: uint32(os.Getuid()), GID: uint32(os.Getgid())}
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			if u, err = user.LookupId(userName); err != nil {
+				return nil, fmt.Errorf("run_as_user %q: %v", userName, err)
+			}
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("run_as_user %q: invalid uid %s", userName, u.Uid)
+		}
+		gid, err := strconv.ParseUint(u.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("run_as_user %q: invalid gid %s", userName, u.Gid)
+		}
+		id.UID, id.GID = uint32(uid), uint32(gid)
+	}
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			if g, err = user.LookupGroupId(groupName); err != nil {
+				return nil, fmt.Errorf("run_as_group %q: %v", groupName, err)
+			}
+		}
+		gid, err := strconv.ParseUint(g.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("run_as_group %q: invalid gid %s", groupName, g.Gid)
+		}
+		id.GID = uint32(gid)
+	}
+	return id, nil
+}
+
+// setIdentity 以指定的用户和用户组运行命令，不保留服务器的附加用户组
+func setIdentity(cmd *exec.Cmd, id *identity) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: id.UID, Gid: id.GID, Groups: []uint32{}}
+}
+
+// limitKilledBy 根据进程的结束状态判断是否因 setrlimit 限制被终止
+// 命令由 sh 执行时，子进程被信号终止表现为 sh 以 128+信号值退出；
+// 被终止的进程不是命令中最后执行的进程时，命令的退出状态无法反映该信号，不会报告
+func limitKilledBy(state *os.ProcessState, limits Limits) string {
+	if state == nil {
+		return ""
+	}
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return ""
+	}
+	var sig syscall.Signal
+	switch {
+	case ws.Signaled():
+		sig = ws.Signal()
+	case ws.Exited() && ws.ExitStatus() > 128:
+		sig = syscall.Signal(ws.ExitStatus() - 128)
+	default:
+		return ""
+	}
+
+	switch {
+	case limits.CPUSeconds != 0 && sig == syscall.SIGXCPU:
+		return KilledByCPU
+	case limits.CPUSeconds != 0 && sig == syscall.SIGKILL &&
+		state.UserTime()+state.SystemTime() >= time.Duration(limits.CPUSeconds)*time.Second:
+		return KilledByCPU
+	case limits.FileSize != 0 && sig == syscall.SIGXFSZ:
+		return KilledByFileSize
+	}
+	return ""
+}
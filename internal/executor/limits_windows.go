@@ -0,0 +1,33 @@
+//go:build windows
+
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// rlimitSupported Windows 不支持 setrlimit
+const rlimitSupported = false
+
+// InitHelper Windows 下不使用辅助进程
+func InitHelper() {}
+
+// wrapWithHelper Windows 下不支持资源限制
+func wrapWithHelper(cmd *exec.Cmd, self string, spec helperSpec) error {
+	return fmt.Errorf("resource limits are not supported on windows")
+}
+
+// lookupIdentity Windows 下不支持以其他用户运行命令
+func lookupIdentity(userName, groupName string) (*identity, error) {
+	return nil, fmt.Errorf("run_as_user and run_as_group are not supported on windows")
+}
+
+// setIdentity Windows 下不支持以其他用户运行命令
+func setIdentity(cmd *exec.Cmd, id *identity) {}
+
+// limitKilledBy Windows 下没有 setrlimit 限制
+func limitKilledBy(state *os.ProcessState, limits Limits) string {
+	return ""
+}
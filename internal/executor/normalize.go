@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// EncodingBase64 Result.Encoding 的取值：标准输出不是有效的 UTF-8，Output 为原始字节的 base64 编码
+// Encoding 为空表示 Output 是 UTF-8 文本
+const EncodingBase64 = "base64"
+
+// NormalizeOptions 输出规范化选项，去掉只对终端有意义、对调用方没有用处的内容
+// JSON 字段名与 execute_command 的 normalize 参数一致
+type NormalizeOptions struct {
+	StripANSI  bool `json:"strip_ansi,omitempty" jsonschema:"remove ANSI escape sequences (colors, cursor movement) and control characters other than newline, tab and carriage return"`
+	CollapseCR bool `json:"collapse_cr,omitempty" jsonschema:"keep only the text after the last carriage return of each line, so progress bars show their final state; CRLF line endings become LF"`
+}
+
+// ansiPattern 匹配 ANSI 转义序列：CSI（ESC [ ... 终止字节）、OSC（ESC ] ... BEL 或 ESC \）和其他以 ESC 开头的序列
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)?|\x1b[ -/]*[0-~]`)
+
+// Normalization 返回一次执行使用的规范化选项，o 为 nil 时使用 Config.Normalize 中本节点的默认选项
+func (e *Executor) Normalization(o *NormalizeOptions) NormalizeOptions {
+	if o != nil {
+		return *o
+	}
+	return e.normalize
+}
+
+// Apply 规范化标准输出，返回规范化后的输出和编码
+// 输出不是有效的 UTF-8（二进制输出）时不做其他处理，整体编码为 base64 并返回 EncodingBase64，
+// 避免 JSON 编码时被替换为 U+FFFD；否则按选项去掉转义序列、折叠回车，返回空编码
+func (n NormalizeOptions) Apply(s string) (string, string) {
+	if !utf8.ValidString(s) {
+		return base64.StdEncoding.EncodeToString([]byte(s)), EncodingBase64
+	}
+	return n.text(s), ""
+}
+
+// Text 规范化错误信息等只作为文本展示的内容，连续的无效 UTF-8 字节替换为一个 U+FFFD
+func (n NormalizeOptions) Text(s string) string {
+	return n.text(strings.ToValidUTF8(s, "�"))
+}
+
+// text 按选项规范化有效的 UTF-8 文本
+func (n NormalizeOptions) text(s string) string {
+	if n.StripANSI {
+		s = stripANSI(s)
+	}
+	if n.CollapseCR {
+		s = collapseCR(s)
+	}
+	return s
+}
+
+// stripANSI 去掉 ANSI 转义序列，以及除换行、制表符和回车（由 collapseCR 处理）之外的控制字符
+func stripANSI(s string) string {
+	if !strings.ContainsFunc(s, isStripped) {
+		return s
+	}
+	s = ansiPattern.ReplaceAllString(s, "")
+	return strings.Map(func(r rune) rune {
+		if isStripped(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// isStripped 是否为 stripANSI 需要去掉的控制字符（C0、DEL 和 C1）
+func isStripped(r rune) bool {
+	switch r {
+	case '\n', '\t', '\r':
+		return false
+	}
+	return r < 0x20 || (r >= 0x7f && r < 0xa0)
+}
+
+// collapseCR 每行只保留最后一段非空的回车分隔内容，即终端上最终显示的进度条状态
+// 行尾的 \r\n 视为换行
+func collapseCR(s string) string {
+	if !strings.Contains(s, "\r") {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "\r") {
+			continue
+		}
+		segments := strings.Split(line, "\r")
+		last := ""
+		for _, seg := range segments {
+			if seg != "" {
+				last = seg
+			}
+		}
+		lines[i] = last
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/artifacts"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// DefaultMaxOutput 每个输出流（标准输出、标准错误）默认保留的最大字节数
+const DefaultMaxOutput = 1 << 20
+
+// Capture 收集 Start 启动的命令的标准输出和标准错误，生成与 Execute 形式相同的结果：
+// 每个输出流超过输出上限时只保留开头和结尾，启用制品时完整输出保存在制品中
+// 命令执行期间可以通过 Snapshot 读取目前的输出
+type Capture struct {
+	mu     sync.Mutex // 保护 stdout 和 stderr，使 Snapshot 可以与写入并发
+	limit  int
+	stdout *cappedBuffer
+	stderr *cappedBuffer
+	art    *artifacts.Artifact // 保存完整输出的制品，nil 表示不保存
+}
+
+// NewCapture 创建收集一次执行输出的 Capture，每个输出流最多保留 executor.max_output 字节
+// 命令结束后需调用 Finish，命令未能启动时调用 Discard
+func (e *Executor) NewCapture() *Capture {
+	limit := e.maxOutput
+	if limit <= 0 {
+		limit = DefaultMaxOutput
+	}
+	c := &Capture{limit: limit, stdout: newCappedBuffer(limit), stderr: newCappedBuffer(limit)}
+	if e.artifacts != nil {
+		art, err := e.artifacts.Create()
+		if err != nil {
+			logger.Warnf("Executor: 创建制品失败，不保存完整输出: %v", err)
+		} else {
+			c.art = art
+		}
+	}
+	return c
+}
+
+// Stdout 返回写入标准输出的 Writer
+func (c *Capture) Stdout() io.Writer {
+	w := &captureWriter{c: c, buf: c.stdout}
+	if c.art != nil {
+		w.art = c.art.Stdout()
+	}
+	return w
+}
+
+// Stderr 返回写入标准错误的 Writer
+func (c *Capture) Stderr() io.Writer {
+	w := &captureWriter{c: c, buf: c.stderr}
+	if c.art != nil {
+		w.art = c.art.Stderr()
+	}
+	return w
+}
+
+// captureWriter 将一个输出流写入 Capture 的缓冲区和制品
+type captureWriter struct {
+	c   *Capture
+	buf *cappedBuffer
+	art io.Writer // 制品的写入总是成功
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	w.buf.Write(p)
+	w.c.mu.Unlock()
+	if w.art != nil {
+		w.art.Write(p)
+	}
+	return len(p), nil
+}
+
+// Snapshot 返回目前为止的输出，按 norm 规范化，退出码为 -1
+func (c *Capture) Snapshot(norm NormalizeOptions) *Result {
+	r := &Result{ExitCode: -1}
+	c.fill(r, norm)
+	return r
+}
+
+// Finish 在命令结束后将输出写入 res 并关闭制品，输出被截断时保留制品并设置 res.Artifact
+// 保留的输出按 norm 规范化，制品中保存的是原始输出
+func (c *Capture) Finish(res *Result, norm NormalizeOptions) {
+	c.fill(res, norm)
+	if res.Truncated {
+		logger.Infof("Executor: 输出超过上限 %d 字节已截断，标准输出 %d 字节，标准错误 %d 字节", c.limit, res.OutputBytes, res.ErrorBytes)
+	}
+	if c.art != nil {
+		if err := c.art.Close(res.Truncated); err != nil {
+			logger.Warnf("Executor: 保存制品失败: %v", err)
+		} else if res.Truncated {
+			res.Artifact = c.art.ID()
+		}
+	}
+}
+
+// Discard 命令未能启动时删除制品
+func (c *Capture) Discard() {
+	if c.art != nil {
+		c.art.Close(false)
+	}
+}
+
+// fill 将保留的输出写入 r：标准输出写入 Output，标准错误追加到 Error，超过上限时设置截断信息
+func (c *Capture) fill(r *Result, norm NormalizeOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r.Output, r.Encoding = norm.Apply(c.stdout.String())
+	logger.Debugf("Executor: 标准输出长度: %d\n", c.stdout.Size())
+	if r.Encoding != "" {
+		logger.Debugf("Executor: 标准输出不是有效的 UTF-8，以 %s 编码返回\n", r.Encoding)
+	}
+
+	// 合并 stderr 到 error 字段，如果存在
+	if c.stderr.Size() > 0 {
+		if r.Error != "" {
+			r.Error += "\n"
+		}
+		r.Error += norm.Text(c.stderr.String())
+	}
+
+	if c.stdout.Truncated() || c.stderr.Truncated() {
+		r.Truncated = true
+		r.OutputBytes = c.stdout.Size()
+		r.ErrorBytes = c.stderr.Size()
+	}
+}
+
+// cappedBuffer 只保留输出开头和结尾各约一半的缓冲区，中间被丢弃的部分在 String 中以标记代替
+// 不是并发安全的，标准输出和标准错误需使用不同的缓冲区
+type cappedBuffer struct {
+	limit int    // 最多保留的字节数
+	head  []byte // 开头部分，最多 limit/2 字节
+	tail  []byte // 开头之后的部分，超过 limit-limit/2 后只保留末尾
+	total int64  // 写入的总字节数
+}
+
+// newCappedBuffer 创建最多保留 limit 字节的缓冲区，limit <= 0 表示不限制
+func newCappedBuffer(limit int) *cappedBuffer {
+	return &cappedBuffer{limit: limit}
+}
+
+// Write 实现 io.Writer，总是写入成功
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if b.limit <= 0 {
+		b.head = append(b.head, p...)
+		return len(p), nil
+	}
+	data := p
+	if n := b.limit/2 - len(b.head); n > 0 {
+		n = min(n, len(data))
+		b.head = append(b.head, data[:n]...)
+		data = data[n:]
+	}
+	b.tail = append(b.tail, data...)
+	// 超出两倍容量时再丢弃旧数据，避免每次写入都移动
+	if keep := b.limit - b.limit/2; len(b.tail) > 2*keep {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-keep:]...)
+	}
+	return len(p), nil
+}
+
+// Size 返回写入的总字节数
+func (b *cappedBuffer) Size() int64 {
+	return b.total
+}
+
+// Truncated 是否有输出被丢弃
+func (b *cappedBuffer) Truncated() bool {
+	return b.limit > 0 && b.total > int64(b.limit)
+}
+
+// String 返回保留的输出，有输出被丢弃时在开头和结尾之间插入说明丢弃字节数的标记
+// 开头和结尾在 UTF-8 字符边界处截断，不会产生不完整的字符
+func (b *cappedBuffer) String() string {
+	if !b.Truncated() {
+		return string(b.head) + string(b.tail)
+	}
+	head := b.head[:completeLen(b.head)]
+	tail := b.tail[len(b.tail)-(b.limit-b.limit/2):]
+	tail = tail[runeStart(tail):]
+	dropped := b.total - int64(len(head)) - int64(len(tail))
+	return string(head) + truncationMarker(dropped) + string(tail)
+}
+
+// truncationMarker 返回替代被丢弃的 n 字节的标记
+func truncationMarker(n int64) string {
+	return fmt.Sprintf("\n... [%d bytes truncated] ...\n", n)
+}
+
+// Truncate 只保留 s 开头和结尾共约 limit 字节，中间以说明丢弃字节数的标记代替，与执行结果的截断方式相同
+// 返回的字符串可能比 limit 多出标记的长度；s 不超过 limit 或 limit <= 0 时原样返回
+func Truncate(s string, limit int) (string, bool) {
+	if limit <= 0 || len(s) <= limit {
+		return s, false
+	}
+	b := newCappedBuffer(limit)
+	b.Write([]byte(s))
+	return b.String(), true
+}
+
+// completeLen 返回去掉末尾不完整 UTF-8 字符后的长度
+func completeLen(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
+
+// runeStart 返回 p 中第一个 UTF-8 字符起始字节的位置，跳过开头被截断字符的后续字节
+func runeStart(p []byte) int {
+	for i := 0; i < len(p) && i < utf8.UTFMax; i++ {
+		if utf8.RuneStart(p[i]) {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package executor
+
+import "os/exec"
+
+// setProcessGroup Windows 下不设置进程组
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup Windows 下直接终止进程
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
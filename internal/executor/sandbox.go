@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxTmp 沙箱中私有 tmpfs 的挂载点
+const sandboxTmp = "/tmp"
+
+// SandboxConfig 沙箱配置，仅 Linux 支持
+// 沙箱中的命令运行在独立的用户、挂载、PID 命名空间（以及可选的网络命名空间）中：
+// 根文件系统只读，/tmp 为私有 tmpfs，只有 WritablePaths 可写，命令没有任何能力（capabilities）
+type SandboxConfig struct {
+	Enabled       bool     `json:"enabled"`        // 所有命令（包括 Shell 会话）都在沙箱中运行；为 false 时只有匹配 sandbox 策略规则的命令在沙箱中运行
+	Network       bool     `json:"network"`        // 是否允许访问网络，为 false 时使用独立的网络命名空间（没有可用的网络接口）
+	WritablePaths []string `json:"writable_paths"` // 沙箱中可写的路径（绝对路径，需已存在）
+}
+
+// validate 检查可写路径，不允许根目录和沙箱私有 /tmp 下的路径
+func (c SandboxConfig) validate() error {
+	for _, p := range c.WritablePaths {
+		if !filepath.IsAbs(p) || filepath.Clean(p) != p {
+			return fmt.Errorf("sandbox writable path %q is not a clean absolute path", p)
+		}
+		if p == "/" {
+			return fmt.Errorf("sandbox writable path cannot be /")
+		}
+		if p == sandboxTmp || strings.HasPrefix(p, sandboxTmp+"/") {
+			return fmt.Errorf("sandbox writable path %q is under the private %s of the sandbox", p, sandboxTmp)
+		}
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("sandbox writable path %q: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// sandboxSpec 传给辅助进程的沙箱参数
+type sandboxSpec struct {
+	WritablePaths []string `json:"writable_paths,omitempty"`
+	Dir           string   `json:"dir,omitempty"` // 调用方指定的工作目录，为空表示沿用服务器的工作目录，在沙箱中不存在时使用 /
+}
+
+// AlwaysSandboxed 是否所有命令（包括 Shell 会话）都在沙箱中运行
+func (e *Executor) AlwaysSandboxed() bool {
+	return e.sandbox.Enabled
+}
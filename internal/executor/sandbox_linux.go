@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxSupported 当前平台是否支持沙箱
+const sandboxSupported = true
+
+// secureNoRoot 使 uid 0 执行程序时不再获得能力，并锁定该设置
+// 即 SECBIT_NOROOT、SECBIT_NO_SETUID_FIXUP、SECBIT_NO_CAP_AMBIENT_RAISE、SECBIT_KEEP_CAPS_LOCKED 及对应的锁定位（linux/securebits.h）
+const secureNoRoot = 0xef
+
+// applySandbox 使命令在新的用户、挂载、PID 命名空间（不允许访问网络时还有网络命名空间）中启动
+// 辅助进程以命名空间中的 root 运行，映射为运行用户（未配置时为服务器的用户），只在命名空间内有权限，执行命令前放弃
+func applySandbox(cmd *exec.Cmd, cfg SandboxConfig, id *identity) {
+	uid, gid := os.Getuid(), os.Getgid()
+	if id != nil {
+		uid, gid = int(id.UID), int(id.GID)
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID
+	if !cfg.Network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: uid, Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: gid, Size: 1}}
+	// 服务器的用户不一定在映射中，需显式切换为命名空间中的 root，执行辅助进程后才保留命名空间中的能力
+	attr.Credential = &syscall.Credential{Uid: 0, Gid: 0}
+	// 只有特权进程可以在映射用户组后允许 setgroups，此时同时清除服务器的附加用户组
+	if os.Getuid() == 0 {
+		attr.GidMappingsEnableSetgroups = true
+		attr.Credential.Groups = []uint32{}
+	} else {
+		attr.Credential.NoSetGroups = true
+	}
+}
+
+// enterSandbox 在辅助进程中设置沙箱的文件系统
+// 根文件系统（包括其下的所有挂载点）重新设置为只读，/tmp 挂载私有 tmpfs，/proc 重新挂载为新 PID 命名空间的视图，
+// WritablePaths 以可写的方式绑定挂载到原位置；可写路径下的其他挂载点保持只读
+func enterSandbox(spec *sandboxSpec) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	// 挂载变化不传播回服务器所在的挂载命名空间
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %v", err)
+	}
+	if err := unix.MountSetattr(unix.AT_FDCWD, "/", unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+		return fmt.Errorf("make root read-only: %v", err)
+	}
+	if err := unix.Mount("tmpfs", sandboxTmp, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount tmpfs on %s: %v", sandboxTmp, err)
+	}
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %v", err)
+	}
+	for _, p := range spec.WritablePaths {
+		if err := unix.Mount(p, p, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind writable path %s: %v", p, err)
+		}
+		if err := unix.MountSetattr(unix.AT_FDCWD, p, 0, &unix.MountAttr{Attr_clr: unix.MOUNT_ATTR_RDONLY}); err != nil {
+			return fmt.Errorf("make %s writable: %v", p, err)
+		}
+	}
+	// 重新进入工作目录，使其位于新的挂载点上（如可写路径或私有 /tmp）
+	// 沿用的服务器工作目录可能被私有 /tmp 遮盖，此时使用根目录；调用方指定的工作目录必须存在
+	if err := os.Chdir(wd); err != nil {
+		if spec.Dir != "" {
+			return err
+		}
+		return os.Chdir("/")
+	}
+	return nil
+}
+
+// dropCapabilities 放弃当前线程在用户命名空间中的所有能力，执行的命令（命名空间中的 root）不会重新获得能力
+// 只作用于当前线程，调用方需锁定线程并在同一线程上执行命令
+func dropCapabilities() error {
+	if err := unix.Prctl(unix.PR_SET_SECUREBITS, secureNoRoot, 0, 0, 0); err != nil {
+		return fmt.Errorf("set securebits: %v", err)
+	}
+	for c := 0; c <= unix.CAP_LAST_CAP; c++ {
+		// 内核不支持的能力返回 EINVAL
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil && !errors.Is(err, unix.EINVAL) {
+			return fmt.Errorf("drop capability %d: %v", c, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("clear ambient capabilities: %v", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %v", err)
+	}
+	var data [2]unix.CapUserData
+	if err := unix.Capset(&unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}, &data[0]); err != nil {
+		return fmt.Errorf("clear capabilities: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// sandboxSupported 只有 Linux 支持沙箱
+const sandboxSupported = false
+
+// applySandbox 只有 Linux 支持沙箱
+func applySandbox(cmd *exec.Cmd, cfg SandboxConfig, id *identity) {}
+
+// enterSandbox 只有 Linux 支持沙箱
+func enterSandbox(spec *sandboxSpec) error {
+	return fmt.Errorf("sandbox is only supported on linux")
+}
+
+// dropCapabilities 只有 Linux 支持沙箱
+func dropCapabilities() error {
+	return fmt.Errorf("sandbox is only supported on linux")
+}
@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// outputBuffer 保存作业输出的末尾部分，超出上限时丢弃最早的内容
+// 偏移量始终相对于作业的完整输出，丢弃内容不会改变已返回给调用方的偏移量
+type outputBuffer struct {
+	mu    sync.Mutex
+	data  []byte
+	start int64 // data[0] 在完整输出中的偏移
+	limit int   // 保留的最大字节数
+}
+
+// newOutputBuffer 创建一个最多保留 limit 字节的输出缓冲区
+func newOutputBuffer(limit int) *outputBuffer {
+	return &outputBuffer{limit: limit}
+}
+
+// Write 追加输出，实现 io.Writer
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	// 超过上限两倍时才整理，避免每次写入都移动数据
+	if len(b.data) > 2*b.limit {
+		drop := len(b.data) - b.limit
+		b.data = append(b.data[:0], b.data[drop:]...)
+		b.start += int64(drop)
+	}
+	return len(p), nil
+}
+
+// Size 返回完整输出的总字节数
+func (b *outputBuffer) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.start + int64(len(b.data))
+}
+
+// Since 返回从 offset 开始的输出，以及这段输出实际的起始偏移和下次读取使用的偏移
+// offset 之前的内容已被丢弃时从最早保留的位置开始返回
+// partial 为 true 时不返回末尾不完整的 UTF-8 字符，留到下次读取
+func (b *outputBuffer) Since(offset int64, partial bool) (out string, from int64, next int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 只对外提供最后 limit 字节
+	data, start := b.data, b.start
+	if len(data) > b.limit {
+		start += int64(len(data) - b.limit)
+		data = data[len(data)-b.limit:]
+	}
+	end := start + int64(len(data))
+
+	from = min(max(offset, start), end)
+	chunk := data[from-start:]
+	// 从字符中间开始时跳过残缺的部分
+	for i := 0; from > 0 && i < utf8.UTFMax-1 && len(chunk) > 0 && !utf8.RuneStart(chunk[0]); i++ {
+		chunk = chunk[1:]
+		from++
+	}
+	if partial {
+		chunk = chunk[:completeLen(chunk)]
+	}
+	return string(chunk), from, from + int64(len(chunk))
+}
+
+// completeLen 返回去掉末尾不完整 UTF-8 字符后的长度
+func completeLen(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
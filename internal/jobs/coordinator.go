@@ -0,0 +1,480 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+)
+
+// peerRequestTimeout 向 Peer 发送作业请求的超时时间
+const peerRequestTimeout = 10 * time.Second
+
+// waitInterval Wait 轮询作业状态的间隔
+const waitInterval = 200 * time.Millisecond
+
+// StartRequest POST /internal/jobs 的请求体
+type StartRequest struct {
+	ID               string `json:"id"`
+	Cmd              string `json:"cmd"`
+	Timeout          int    `json:"timeout,omitempty"` // 执行超时（秒），0 表示 DefaultTimeout
+	executor.Options        // 工作目录、环境变量和标准输入（cwd、env、stdin）
+}
+
+// Job 集群作业在各节点上的状态
+type Job struct {
+	ID        string    `json:"id" jsonschema:"job ID"`
+	Command   string    `json:"command" jsonschema:"command of the job"`
+	Status    string    `json:"status" jsonschema:"running while any node is running; unreachable when some node could not be queried; otherwise cancelled, failed or success"`
+	StartedAt string    `json:"started_at" jsonschema:"RFC3339 start time"`
+	Nodes     []NodeJob `json:"nodes" jsonschema:"status of each node, this node first"`
+}
+
+// NodeJob 作业在单个节点上的状态
+type NodeJob struct {
+	NodeName   string `json:"node_name"`
+	Status     string `json:"status" jsonschema:"running, success, failed, cancelled, unreachable or expired"`
+	ExitCode   int    `json:"exit_code" jsonschema:"exit code of the command, -1 while running or when not available"`
+	Output     string `json:"output,omitempty" jsonschema:"stdout and stderr merged in order, starting at offset"`
+	Offset     int64  `json:"offset" jsonschema:"byte offset of output in the full output of the node"`
+	NextOffset int64  `json:"next_offset" jsonschema:"pass back in offsets to only read newer output"`
+	Error      string `json:"error,omitempty"`
+	KilledBy   string `json:"killed_by,omitempty" jsonschema:"set when the command was killed by a limit: timeout, cpu, file_size or memory"`
+	Sandboxed  bool   `json:"sandboxed,omitempty" jsonschema:"true when the command runs in the namespace sandbox"`
+	DurationMs int64  `json:"duration_ms,omitempty" jsonschema:"execution time in milliseconds"`
+	// Result 与 execute_command 形式相同的执行结果，只由 Wait 设置，不随 get_job 返回
+	Result *executor.Result `json:"-"`
+}
+
+// record Coordinator 发起的作业及其目标节点
+type record struct {
+	command string
+	started time.Time
+	nodes   []string          // 目标节点地址，空字符串表示本节点
+	failed  map[string]string // 启动失败的节点及原因
+}
+
+// Coordinator 在集群节点上启动作业并汇总各节点的状态
+// 作业在各节点上以相同的 ID 独立运行，状态保存在节点本地，Coordinator 只记录目标节点；
+// 查询本节点未发起（如 Coordinator 重启或客户端切换到其他节点）的作业时会询问所有节点
+type Coordinator struct {
+	local      *Manager
+	nodeName   string
+	peers      func() []string
+	token      string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	records map[string]*record
+}
+
+// NewCoordinator 创建作业协调器
+// local: 本节点的作业管理器
+// nodeName: 本节点名称
+// peers: 返回当前所有 Peer 地址，用于查询未知作业和列出作业
+// token: 集群内部通信 Token
+func NewCoordinator(local *Manager, nodeName string, peers func() []string, token string) *Coordinator {
+	return &Coordinator{
+		local:      local,
+		nodeName:   nodeName,
+		peers:      peers,
+		token:      token,
+		httpClient: &http.Client{},
+		records:    make(map[string]*record),
+	}
+}
+
+// newID 生成随机的作业 ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start 在本节点（skipLocal 为 false 时）和 peers 上启动作业，peers 为 nil 表示所有 Peer
+// 部分节点启动失败时作业仍然创建，失败节点的状态为 failed
+func (c *Coordinator) Start(ctx context.Context, cmd string, timeout time.Duration, opts executor.Options, peers []string, skipLocal bool) (*Job, error) {
+	if peers == nil {
+		peers = c.peers()
+	}
+	if skipLocal && len(peers) == 0 {
+		return nil, errors.New("no target nodes")
+	}
+	timeout = ClampTimeout(timeout)
+	rec := &record{
+		command: cmd,
+		started: time.Now(),
+		failed:  make(map[string]string),
+	}
+	if !skipLocal {
+		rec.nodes = append(rec.nodes, "")
+	}
+	rec.nodes = append(rec.nodes, peers...)
+	id := newID()
+	logger.Infof("Jobs: 启动作业 %s, 命令: %s, 节点数量: %d, 超时: %s", id, cmd, len(rec.nodes), timeout)
+
+	results := eachNode(rec.nodes, func(node string) (Info, error) {
+		if node == "" {
+			return c.local.Start(id, cmd, timeout, opts)
+		}
+		// 超时按秒向上取整传给 Peer
+		req := StartRequest{ID: id, Cmd: cmd, Timeout: int((timeout + time.Second - 1) / time.Second), Options: opts}
+		var info Info
+		err := c.do(ctx, http.MethodPost, node+"/internal/jobs", req, &info)
+		return info, err
+	})
+	for i, node := range rec.nodes {
+		if results[i].err != nil {
+			logger.Warnf("Jobs: 作业 %s 在节点 %s 上启动失败: %v", id, c.displayName(node), results[i].err)
+			rec.failed[node] = results[i].err.Error()
+		}
+	}
+
+	c.mu.Lock()
+	c.pruneLocked(time.Now())
+	c.records[id] = rec
+	c.mu.Unlock()
+
+	return c.build(id, rec, results), nil
+}
+
+// Get 返回作业在各节点上的状态，offsets 为各节点（按 node_name）已读取的输出偏移
+func (c *Coordinator) Get(ctx context.Context, id string, offsets map[string]int64) (*Job, error) {
+	return c.query(ctx, id, func(node string) (Info, error) {
+		offset := offsets[c.displayName(node)]
+		if node == "" {
+			return c.local.Get(id, offset)
+		}
+		var info Info
+		err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/internal/jobs/%s?offset=%d", node, url.PathEscape(id), offset), nil, &info)
+		return info, err
+	})
+}
+
+// Wait 等待作业在所有节点上结束，最长等待 timeout，返回最新状态
+// 各节点的 Result 为与 execute_command 形式相同的执行结果（仍在执行的节点为目前为止的输出），Output 为空
+// 超时后作业继续在节点上执行；ctx 取消时返回 ctx.Err()，同样不影响作业
+func (c *Coordinator) Wait(ctx context.Context, id string, timeout time.Duration) (*Job, error) {
+	deadline := time.Now().Add(timeout)
+	// 轮询时只读取新的输出，结束后再读取一次执行结果
+	offsets := make(map[string]int64)
+	for {
+		job, err := c.Get(ctx, id, offsets)
+		if err != nil {
+			return nil, err
+		}
+		wait := time.Until(deadline)
+		if job.Status != StatusRunning || wait <= 0 {
+			break
+		}
+		for _, n := range job.Nodes {
+			offsets[n.NodeName] = n.NextOffset
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(wait, waitInterval)):
+		}
+	}
+	return c.query(ctx, id, func(node string) (Info, error) {
+		if node == "" {
+			return c.local.Result(id)
+		}
+		var info Info
+		err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/internal/jobs/%s/result", node, url.PathEscape(id)), nil, &info)
+		return info, err
+	})
+}
+
+// Cancel 在所有节点上终止作业，已结束的节点保持原状态
+func (c *Coordinator) Cancel(ctx context.Context, id string) (*Job, error) {
+	logger.Infof("Jobs: 取消作业 %s", id)
+	return c.query(ctx, id, func(node string) (Info, error) {
+		if node == "" {
+			return c.local.Cancel(id)
+		}
+		var info Info
+		err := c.do(ctx, http.MethodPost, fmt.Sprintf("%s/internal/jobs/%s/cancel", node, url.PathEscape(id)), nil, &info)
+		return info, err
+	})
+}
+
+// List 汇总本节点和所有 Peer 上的作业（不含输出），按启动时间从新到旧排序
+// 无法访问的 Peer 会被跳过
+func (c *Coordinator) List(ctx context.Context) []Job {
+	nodes := append([]string{""}, c.peers()...)
+	results := eachNode(nodes, func(node string) ([]Info, error) {
+		if node == "" {
+			return c.local.List(), nil
+		}
+		var infos []Info
+		err := c.do(ctx, http.MethodGet, node+"/internal/jobs", nil, &infos)
+		return infos, err
+	})
+
+	byID := make(map[string]*Job)
+	var jobs []*Job
+	for i, node := range nodes {
+		if results[i].err != nil {
+			logger.Warnf("Jobs: 获取节点 %s 的作业列表失败: %v", node, results[i].err)
+			continue
+		}
+		for _, info := range results[i].value {
+			job, ok := byID[info.ID]
+			if !ok {
+				job = &Job{ID: info.ID, Command: info.Command, StartedAt: info.StartedAt.UTC().Format(time.RFC3339)}
+				byID[info.ID] = job
+				jobs = append(jobs, job)
+			}
+			job.Nodes = append(job.Nodes, c.nodeJob(node, info))
+		}
+	}
+
+	out := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		job.Status = summarize(job.Nodes)
+		out = append(out, *job)
+	}
+	sort.SliceStable(out, func(a, b int) bool {
+		return out[a].StartedAt > out[b].StartedAt
+	})
+	return out
+}
+
+// query 对作业的所有节点执行 op 并汇总结果
+// 本节点没有作业记录时询问所有节点，只保留拥有该作业的节点
+func (c *Coordinator) query(ctx context.Context, id string, op func(node string) (Info, error)) (*Job, error) {
+	c.mu.Lock()
+	c.pruneLocked(time.Now())
+	rec, known := c.records[id]
+	c.mu.Unlock()
+
+	if !known {
+		rec = &record{nodes: append([]string{""}, c.peers()...)}
+	}
+	nodes := make([]string, 0, len(rec.nodes))
+	for _, node := range rec.nodes {
+		if _, failed := rec.failed[node]; !failed {
+			nodes = append(nodes, node)
+		}
+	}
+	results := eachNode(nodes, op)
+
+	// 已知作业只要有节点仍保留记录（或启动失败）即存在；未知作业只看成功返回的节点
+	found := len(rec.failed) > 0
+	byNode := make(map[string]result[Info], len(nodes))
+	for i, node := range nodes {
+		if results[i].err == nil || known && !errors.Is(results[i].err, ErrNotFound) {
+			found = true
+		}
+		byNode[node] = results[i]
+	}
+	if !found {
+		c.mu.Lock()
+		delete(c.records, id)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	if !known {
+		// 只保留拥有该作业的节点
+		kept := rec.nodes[:0]
+		for _, node := range rec.nodes {
+			if res := byNode[node]; res.err == nil {
+				kept = append(kept, node)
+				if rec.command == "" {
+					rec.command = res.value.Command
+					rec.started = res.value.StartedAt
+				}
+			}
+		}
+		rec.nodes = kept
+		// 记录下来，之后的查询只询问这些节点，节点暂时无法访问时也能如实报告
+		c.mu.Lock()
+		c.records[id] = rec
+		c.mu.Unlock()
+	}
+
+	ordered := make([]result[Info], len(rec.nodes))
+	for i, node := range rec.nodes {
+		if res, ok := byNode[node]; ok {
+			ordered[i] = res
+		} else {
+			ordered[i] = result[Info]{err: errors.New(rec.failed[node])}
+		}
+	}
+	return c.build(id, rec, ordered), nil
+}
+
+// build 根据各节点的结果生成作业状态
+func (c *Coordinator) build(id string, rec *record, results []result[Info]) *Job {
+	job := &Job{
+		ID:        id,
+		Command:   rec.command,
+		StartedAt: rec.started.UTC().Format(time.RFC3339),
+	}
+	for i, node := range rec.nodes {
+		res := results[i]
+		switch {
+		case res.err == nil:
+			job.Nodes = append(job.Nodes, c.nodeJob(node, res.value))
+		case rec.failed[node] != "":
+			job.Nodes = append(job.Nodes, NodeJob{NodeName: c.displayName(node), Status: StatusFailed, ExitCode: NoExitCode, Error: rec.failed[node]})
+		case errors.Is(res.err, ErrNotFound):
+			job.Nodes = append(job.Nodes, NodeJob{NodeName: c.displayName(node), Status: StatusExpired, ExitCode: NoExitCode, Error: res.err.Error()})
+		default:
+			job.Nodes = append(job.Nodes, NodeJob{NodeName: c.displayName(node), Status: StatusUnreachable, ExitCode: NoExitCode, Error: res.err.Error()})
+		}
+	}
+	job.Status = summarize(job.Nodes)
+	return job
+}
+
+// nodeJob 将节点返回的作业状态转换为 NodeJob
+func (c *Coordinator) nodeJob(node string, info Info) NodeJob {
+	nj := NodeJob{
+		NodeName:   c.displayName(node),
+		Status:     info.Status,
+		ExitCode:   info.ExitCode,
+		Output:     info.Output,
+		Offset:     info.Offset,
+		NextOffset: info.NextOffset,
+		Error:      info.Error,
+		KilledBy:   info.KilledBy,
+		Sandboxed:  info.Sandboxed,
+		Result:     info.Result,
+	}
+	end := time.Now()
+	if info.FinishedAt != nil {
+		end = *info.FinishedAt
+	}
+	nj.DurationMs = end.Sub(info.StartedAt).Milliseconds()
+	return nj
+}
+
+// displayName 返回节点在结果中的名称：本节点使用节点名称，Peer 使用地址
+func (c *Coordinator) displayName(node string) string {
+	if node == "" {
+		return c.nodeName
+	}
+	return node
+}
+
+// summarize 根据各节点状态计算作业的整体状态
+func summarize(nodes []NodeJob) string {
+	counts := make(map[string]int)
+	for _, n := range nodes {
+		counts[n.Status]++
+	}
+	switch {
+	case counts[StatusRunning] > 0:
+		return StatusRunning
+	case counts[StatusUnreachable] > 0:
+		return StatusUnreachable
+	case counts[StatusCancelled] > 0:
+		return StatusCancelled
+	case counts[StatusSuccess] == len(nodes):
+		return StatusSuccess
+	default:
+		return StatusFailed
+	}
+}
+
+// pruneLocked 清理不可能再有节点保留结果的作业记录
+func (c *Coordinator) pruneLocked(now time.Time) {
+	ttl := MaxTimeout + c.local.cfg.retention()
+	for id, rec := range c.records {
+		if now.Sub(rec.started) > ttl {
+			delete(c.records, id)
+		}
+	}
+}
+
+// result 单个节点上操作的结果
+type result[T any] struct {
+	value T
+	err   error
+}
+
+// eachNode 并发地对每个节点执行 op，结果顺序与 nodes 一致
+func eachNode[T any](nodes []string, op func(node string) (T, error)) []result[T] {
+	results := make([]result[T], len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].value, results[i].err = op(node)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// do 向 Peer 发送作业请求，404 返回 ErrNotFound
+func (c *Coordinator) do(ctx context.Context, method, url string, in any, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, peerRequestTimeout)
+	defer cancel()
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("marshal request failed: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("create request failed: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+	if c.token != "" {
+		req.Header.Set("X-Cluster-Token", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response failed: %v", err)
+	}
+	return nil
+}
+
+// ParseOffset 解析查询参数中的输出偏移，空字符串表示 0
+func ParseOffset(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+	return offset, nil
+}
@@ -0,0 +1,205 @@
+//go:build !windows
+
+package jobs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// TestMain 先显式初始化 logger，避免懒加载初始化时的死锁
+func TestMain(m *testing.M) {
+	_ = logger.InitLogger(&logger.LogConfig{Level: "error", LogDir: os.TempDir()}, "jobs_test.log")
+	os.Exit(m.Run())
+}
+
+// waitStatus 轮询作业直到状态不再是 running
+func waitStatus(t *testing.T, m *Manager, id string) Info {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := m.Get(id, 0)
+		if err != nil {
+			t.Fatalf("查询作业失败: %v", err)
+		}
+		if info.Status != StatusRunning {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待作业 %s 结束超时", id)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestManagerLifecycle 验证作业的增量输出、取消和保留数量限制
+func TestManagerLifecycle(t *testing.T) {
+	m := NewManager(executor.NewExecutor(), Config{MaxJobs: 2})
+
+	if _, err := m.Start("a", "echo one; echo two >&2; exit 3", 0, executor.Options{}); err != nil {
+		t.Fatalf("启动作业失败: %v", err)
+	}
+	info := waitStatus(t, m, "a")
+	if info.Status != StatusFailed || info.ExitCode != 3 || info.Output != "one\ntwo\n" {
+		t.Fatalf("作业状态 = %+v, 预期 failed、退出码 3、合并标准错误的输出", info)
+	}
+
+	// 从上次的偏移读取只返回新的输出
+	info, _ = m.Get("a", 4)
+	if info.Output != "two\n" || info.Offset != 4 || info.NextOffset != 8 {
+		t.Fatalf("offset=4 的结果 = %+v, 预期只返回 two", info)
+	}
+
+	if _, err := m.Start("a", "true", 0, executor.Options{}); err != ErrExists {
+		t.Fatalf("重复 ID 的错误 = %v, 预期 ErrExists", err)
+	}
+
+	if _, err := m.Start("b", "echo started; sleep 10", 0, executor.Options{}); err != nil {
+		t.Fatalf("启动作业失败: %v", err)
+	}
+	// 达到 max_jobs 时清理最早结束的作业 a
+	if _, err := m.Start("c", "sleep 10", 0, executor.Options{}); err != nil {
+		t.Fatalf("达到上限时应清理已结束的作业: %v", err)
+	}
+	if _, err := m.Get("a", 0); err != ErrNotFound {
+		t.Fatalf("作业 a 应已被清理, err = %v", err)
+	}
+	// 执行中的作业不会被清理
+	if _, err := m.Start("d", "true", 0, executor.Options{}); err != ErrTooManyJobs {
+		t.Fatalf("全部作业都在执行时的错误 = %v, 预期 ErrTooManyJobs", err)
+	}
+
+	for _, id := range []string{"b", "c"} {
+		info, err := m.Cancel(id)
+		if err != nil || info.Status != StatusCancelled {
+			t.Fatalf("取消作业 %s 的结果 = %+v, %v, 预期 cancelled", id, info, err)
+		}
+	}
+	if list := m.List(); len(list) != 2 || list[0].ID != "b" {
+		t.Fatalf("作业列表 = %+v, 预期按启动时间排序的 b、c", list)
+	}
+}
+
+// TestOutputBufferKeepsTail 验证超出上限时只保留末尾输出且偏移量不变
+func TestOutputBufferKeepsTail(t *testing.T) {
+	b := newOutputBuffer(4)
+	b.Write([]byte("0123"))
+	b.Write([]byte("4567"))
+	b.Write([]byte("89"))
+
+	out, from, next := b.Since(0, false)
+	if out != "6789" || from != 6 || next != 10 {
+		t.Fatalf("Since(0) = %q, %d, %d, 预期 \"6789\", 6, 10", out, from, next)
+	}
+
+	// 执行中的作业不返回末尾不完整的 UTF-8 字符
+	b = newOutputBuffer(16)
+	b.Write([]byte("ok\xe4\xb8"))
+	if out, _, next := b.Since(0, true); out != "ok" || next != 2 {
+		t.Fatalf("Since(0, partial) = %q, %d, 预期 \"ok\", 2", out, next)
+	}
+}
+
+// TestCoordinatorQueriesPeers 验证作业在 Peer 上启动，未知作业会向所有节点查询
+func TestCoordinatorQueriesPeers(t *testing.T) {
+	remote := NewManager(executor.NewExecutor(), Config{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /internal/jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req StartRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		info, err := remote.Start(req.ID, req.Cmd, time.Duration(req.Timeout)*time.Second, req.Options)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(info)
+	})
+	mux.HandleFunc("GET /internal/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := ParseOffset(r.URL.Query().Get("offset"))
+		info, err := remote.Get(r.PathValue("id"), offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(info)
+	})
+	peer := httptest.NewServer(mux)
+	defer peer.Close()
+	peers := func() []string { return []string{peer.URL} }
+
+	c := NewCoordinator(NewManager(executor.NewExecutor(), Config{}), "local", peers, "")
+	job, err := c.Start(t.Context(), "echo hi", time.Minute, executor.Options{}, []string{peer.URL}, false)
+	if err != nil {
+		t.Fatalf("启动作业失败: %v", err)
+	}
+	if len(job.Nodes) != 2 || job.Nodes[0].NodeName != "local" || job.Nodes[1].NodeName != peer.URL {
+		t.Fatalf("作业节点 = %+v, 预期 local 和 Peer", job.Nodes)
+	}
+
+	// 另一个 Coordinator（如客户端切换到其他节点）没有作业记录，只能从 Peer 上找到作业
+	other := NewCoordinator(NewManager(executor.NewExecutor(), Config{}), "other", peers, "")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err = other.Get(t.Context(), job.ID, nil)
+		if err != nil {
+			t.Fatalf("查询作业失败: %v", err)
+		}
+		if job.Status != StatusRunning || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if job.Status != StatusSuccess || len(job.Nodes) != 1 || strings.TrimSpace(job.Nodes[0].Output) != "hi" {
+		t.Fatalf("作业 = %+v, 预期只包含 Peer 节点且执行成功", job)
+	}
+
+	if _, err := other.Get(t.Context(), "missing", nil); err == nil {
+		t.Fatalf("查询不存在的作业应返回错误")
+	}
+}
+
+// TestCoordinatorWait 验证 Wait 在作业结束时立即返回与 Execute 形式相同的结果，超时后作业继续执行
+func TestCoordinatorWait(t *testing.T) {
+	e := executor.NewExecutor()
+	if err := e.Configure(executor.Config{MaxOutput: 16}); err != nil {
+		t.Fatalf("配置执行器失败: %v", err)
+	}
+	c := NewCoordinator(NewManager(e, Config{}), "local", func() []string { return nil }, "")
+
+	job, _ := c.Start(t.Context(), "echo a; sleep 0.1; echo b >&2", time.Minute, executor.Options{}, nil, false)
+	start := time.Now()
+	job, err := c.Wait(t.Context(), job.ID, 5*time.Second)
+	if err != nil || job.Status != StatusSuccess {
+		t.Fatalf("Wait 结果 = %+v, %v, 预期成功", job, err)
+	}
+	if res := job.Nodes[0].Result; res == nil || res.Output != "a\n" || res.Error != "b\n" || res.ExitCode != 0 {
+		t.Fatalf("执行结果 = %+v, 预期标准输出和标准错误分开", res)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("作业结束后 Wait 应立即返回，耗时 %s", time.Since(start))
+	}
+
+	// 超过输出上限时与 Execute 一样保留开头和结尾
+	job, _ = c.Start(t.Context(), "seq 1 100", time.Minute, executor.Options{}, nil, false)
+	job, _ = c.Wait(t.Context(), job.ID, 5*time.Second)
+	if res := job.Nodes[0].Result; res == nil || !res.Truncated || res.OutputBytes != 292 || !strings.HasPrefix(res.Output, "1\n2\n") || !strings.HasSuffix(res.Output, "99\n100\n") {
+		t.Fatalf("执行结果 = %+v, 预期截断并保留开头和结尾", res)
+	}
+
+	job, _ = c.Start(t.Context(), "echo partial; sleep 10", time.Minute, executor.Options{}, nil, false)
+	job, err = c.Wait(t.Context(), job.ID, 300*time.Millisecond)
+	if err != nil || job.Status != StatusRunning || job.Nodes[0].Result == nil || job.Nodes[0].Result.Output != "partial\n" {
+		t.Fatalf("Wait 结果 = %+v, %v, 预期仍在执行并返回部分输出", job, err)
+	}
+	if job, _ = c.Cancel(t.Context(), job.ID); job.Status != StatusCancelled {
+		t.Fatalf("取消后的状态 = %s, 预期 cancelled", job.Status)
+	}
+}
@@ -0,0 +1,342 @@
+package jobs
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// 作业状态
+const (
+	StatusRunning     = "running"     // 正在执行
+	StatusSuccess     = "success"     // 退出码为 0
+	StatusFailed      = "failed"      // 退出码非 0、超时、无法启动或因服务器关闭被终止
+	StatusCancelled   = "cancelled"   // 被 cancel_job 终止
+	StatusUnreachable = "unreachable" // Coordinator 无法访问该节点，作业可能仍在执行
+	StatusExpired     = "expired"     // 节点上的作业已超过保留时间被清理
+)
+
+// 作业执行超时
+const (
+	DefaultTimeout = time.Hour      // 未指定超时时作业的执行超时
+	MaxTimeout     = 24 * time.Hour // 允许指定的最大执行超时
+)
+
+// 默认配置
+const (
+	DefaultRetention = time.Hour // 作业结束后保留结果的时间
+	DefaultMaxJobs   = 100       // 每个节点保留的最大作业数
+	DefaultMaxOutput = 1 << 20   // 每个作业保留的输出字节数
+)
+
+var (
+	// ErrNotFound 作业不存在或已被清理
+	ErrNotFound = errors.New("job not found")
+	// ErrExists 作业 ID 已存在
+	ErrExists = errors.New("job already exists")
+	// ErrTooManyJobs 节点上正在执行的作业已达到 max_jobs
+	ErrTooManyJobs = errors.New("too many running jobs")
+)
+
+// Config 作业配置
+type Config struct {
+	Retention int `json:"retention"`  // 作业结束后保留结果的秒数，默认 3600
+	MaxJobs   int `json:"max_jobs"`   // 每个节点保留的最大作业数（含执行中的作业），默认 100
+	MaxOutput int `json:"max_output"` // 每个作业保留的输出字节数，超出时只保留末尾部分，默认 1 MiB
+	// SoftTimeout execute_command 未指定 soft_timeout 时使用的软超时秒数，0 表示不启用
+	SoftTimeout int `json:"soft_timeout"`
+}
+
+// GetSoftTimeout 返回 execute_command 默认的软超时，0 表示不启用
+func (c Config) GetSoftTimeout() time.Duration {
+	if c.SoftTimeout <= 0 {
+		return 0
+	}
+	return time.Duration(c.SoftTimeout) * time.Second
+}
+
+// retention 返回作业结束后保留结果的时间
+func (c Config) retention() time.Duration {
+	if c.Retention <= 0 {
+		return DefaultRetention
+	}
+	return time.Duration(c.Retention) * time.Second
+}
+
+// maxJobs 返回每个节点保留的最大作业数
+func (c Config) maxJobs() int {
+	if c.MaxJobs <= 0 {
+		return DefaultMaxJobs
+	}
+	return c.MaxJobs
+}
+
+// maxOutput 返回每个作业保留的输出字节数
+func (c Config) maxOutput() int {
+	if c.MaxOutput <= 0 {
+		return DefaultMaxOutput
+	}
+	return c.MaxOutput
+}
+
+// ClampTimeout 将作业执行超时限制在 (0, MaxTimeout] 范围内，0 或负数返回 DefaultTimeout
+func ClampTimeout(timeout time.Duration) time.Duration {
+	switch {
+	case timeout <= 0:
+		return DefaultTimeout
+	case timeout > MaxTimeout:
+		return MaxTimeout
+	default:
+		return timeout
+	}
+}
+
+// NoExitCode 作业执行中或没有退出码（无法启动、被信号终止）时的 ExitCode
+const NoExitCode = -1
+
+// Info 单个节点上作业的状态
+// 标准输出和标准错误按写入顺序合并到 Output 中；Result 只在 Manager.Result 中返回
+type Info struct {
+	ID         string     `json:"id"`
+	Command    string     `json:"command"`
+	Status     string     `json:"status"`
+	ExitCode   int        `json:"exit_code"` // 执行中或没有退出码时为 -1
+	Error      string     `json:"error,omitempty"`
+	KilledBy   string     `json:"killed_by,omitempty"` // 因超时或资源限制被终止时的原因
+	Sandboxed  bool       `json:"sandboxed,omitempty"` // 是否在沙箱中运行
+	Output     string     `json:"output,omitempty"`
+	Offset     int64      `json:"offset"`      // Output 在完整输出中的起始偏移
+	NextOffset int64      `json:"next_offset"` // 下次读取新输出时使用的偏移
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Result 与 execute_command 形式相同的执行结果：标准输出和标准错误分开，超过输出上限时截断并保存制品
+	Result *executor.Result `json:"result,omitempty"`
+}
+
+// job 本节点上的一个作业
+type job struct {
+	id        string
+	command   string
+	proc      *executor.Process
+	out       *outputBuffer             // 合并的输出，供 get_job 按偏移读取
+	capture   *executor.Capture         // 分开的标准输出和标准错误，供 Manager.Result 使用
+	norm      executor.NormalizeOptions // capture 中输出的规范化选项
+	final     *executor.Result          // 结束后与 Execute 形式相同的结果
+	started   time.Time
+	finished  time.Time // 零值表示仍在执行
+	result    *executor.Result
+	cancelled bool
+	done      chan struct{} // 记录结果后关闭
+}
+
+// Manager 管理本节点上的后台作业
+// 作业结束后保留 Retention 秒供查询，之后在下一次访问时清理
+type Manager struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	exec *executor.Executor
+	cfg  Config
+}
+
+// NewManager 创建作业管理器，作业通过 exec 执行，受其优雅关闭流程管理
+func NewManager(exec *executor.Executor, cfg Config) *Manager {
+	return &Manager{
+		jobs: make(map[string]*job),
+		exec: exec,
+		cfg:  cfg,
+	}
+}
+
+// Start 以指定 ID 在本节点启动作业，timeout 会经过 ClampTimeout 处理
+func (m *Manager) Start(id, cmd string, timeout time.Duration, opts executor.Options) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(time.Now())
+
+	if _, ok := m.jobs[id]; ok {
+		return Info{}, ErrExists
+	}
+	if len(m.jobs) >= m.cfg.maxJobs() && !m.evictLocked() {
+		return Info{}, ErrTooManyJobs
+	}
+
+	out := newOutputBuffer(m.cfg.maxOutput())
+	capture := m.exec.NewCapture()
+	proc, err := m.exec.Start(cmd, ClampTimeout(timeout), opts, io.MultiWriter(out, capture.Stdout()), io.MultiWriter(out, capture.Stderr()))
+	if err != nil {
+		capture.Discard()
+		return Info{}, err
+	}
+	j := &job{
+		id:      id,
+		command: cmd,
+		proc:    proc,
+		out:     out,
+		capture: capture,
+		norm:    m.exec.Normalization(opts.Normalize),
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	m.jobs[id] = j
+	logger.Infof("作业已启动: id=%s, 命令: %s", id, cmd)
+
+	go func() {
+		res := proc.Wait()
+		final := *res
+		capture.Finish(&final, j.norm)
+		m.mu.Lock()
+		j.result = res
+		j.final = &final
+		j.finished = time.Now()
+		m.mu.Unlock()
+		close(j.done)
+		logger.Infof("作业已结束: id=%s, 退出码: %d, 耗时: %s", id, res.ExitCode, j.finished.Sub(j.started))
+	}()
+	return m.infoLocked(j, 0, false), nil
+}
+
+// Get 返回作业状态和从 offset 开始的输出
+func (m *Manager) Get(id string, offset int64) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(time.Now())
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return m.infoLocked(j, offset, true), nil
+}
+
+// Result 返回作业状态和与 execute_command 形式相同的执行结果（Info.Result），不包含合并的输出
+// 作业仍在执行时 Info.Result 为目前为止的输出
+func (m *Manager) Result(id string) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(time.Now())
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	info := m.infoLocked(j, j.out.Size(), false)
+	if j.final != nil {
+		final := *j.final
+		info.Result = &final
+	} else {
+		info.Result = j.capture.Snapshot(j.norm)
+	}
+	return info, nil
+}
+
+// cancelWait 取消作业后等待进程退出的最长时间
+const cancelWait = 2 * time.Second
+
+// Cancel 终止作业的整个进程组，已结束的作业保持原状态
+func (m *Manager) Cancel(id string) (Info, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	if ok && j.finished.IsZero() {
+		j.cancelled = true
+		logger.Infof("取消作业: id=%s", id)
+		j.proc.Kill()
+	}
+	m.mu.Unlock()
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+
+	// 等待进程退出，使返回的状态反映取消结果
+	select {
+	case <-j.done:
+	case <-time.After(cancelWait):
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.infoLocked(j, j.out.Size(), false), nil
+}
+
+// List 返回本节点上所有作业的状态（不含输出），按启动时间排序
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(time.Now())
+
+	infos := make([]Info, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		infos = append(infos, m.infoLocked(j, j.out.Size(), false))
+	}
+	sort.Slice(infos, func(a, b int) bool {
+		return infos[a].StartedAt.Before(infos[b].StartedAt)
+	})
+	return infos
+}
+
+// infoLocked 生成作业状态，withOutput 为 false 时不返回输出内容
+func (m *Manager) infoLocked(j *job, offset int64, withOutput bool) Info {
+	info := Info{
+		ID:        j.id,
+		Command:   j.command,
+		Status:    StatusRunning,
+		ExitCode:  NoExitCode,
+		StartedAt: j.started,
+		Sandboxed: j.proc.Sandboxed(),
+	}
+	running := j.finished.IsZero()
+	if !running {
+		finished := j.finished
+		info.FinishedAt = &finished
+		info.ExitCode = j.result.ExitCode
+		info.Error = j.result.Error
+		info.KilledBy = j.result.KilledBy
+		info.Sandboxed = j.result.Sandboxed
+		switch {
+		case j.cancelled && j.result.ExitCode != 0:
+			info.Status = StatusCancelled
+		case j.result.ExitCode == 0 && j.result.Error == "":
+			info.Status = StatusSuccess
+		default:
+			info.Status = StatusFailed
+		}
+	}
+
+	if withOutput {
+		info.Output, info.Offset, info.NextOffset = j.out.Since(offset, running)
+	} else {
+		info.Offset = j.out.Size()
+		info.NextOffset = info.Offset
+	}
+	return info
+}
+
+// pruneLocked 清理超过保留时间的已结束作业
+func (m *Manager) pruneLocked(now time.Time) {
+	retention := m.cfg.retention()
+	for id, j := range m.jobs {
+		if !j.finished.IsZero() && now.Sub(j.finished) > retention {
+			logger.Debugf("清理过期作业: id=%s", id)
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// evictLocked 删除最早结束的作业，为新作业腾出位置；没有已结束的作业时返回 false
+func (m *Manager) evictLocked() bool {
+	var oldest *job
+	for _, j := range m.jobs {
+		if !j.finished.IsZero() && (oldest == nil || j.finished.Before(oldest.finished)) {
+			oldest = j
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	logger.Debugf("作业数量达到上限，清理最早结束的作业: id=%s", oldest.id)
+	delete(m.jobs, oldest.id)
+	return true
+}
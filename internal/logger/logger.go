@@ -13,13 +13,21 @@ import (
 
 // LogConfig 日志配置
 type LogConfig struct {
-	Level      string // 日志级别: debug, info, warn, error
-	LogDir     string // 日志文件目录，默认为当前目录
-	MaxSize    int    // 单个日志文件最大大小（MB），默认 100MB
-	MaxBackups int    // 保留的旧日志文件最大数量，默认 3 个
-	MaxAge     int    // 保留旧日志文件的最大天数，默认 28 天
-	Compress   bool   // 是否压缩旧日志文件，默认 false
-}
+	Level      string `json:"level"`       // 日志级别: debug, info, warn, error
+	LogDir     string `json:"log_dir"`     // 日志文件目录，默认为当前目录
+	MaxSize    int    `json:"max_size"`    // 单个日志文件最大大小（MB），默认 100MB
+	MaxBackups int    `json:"max_backups"` // 保留的旧日志文件最大数量，默认 3 个
+	MaxAge     int    `json:"max_age"`     // 保留旧日志文件的最大天数，默认 28 天
+	Compress   bool   `json:"compress"`    // 是否压缩旧日志文件，默认 false
+	Console    string `json:"console"`     // 控制台输出: stdout（默认）、stderr、none
+}
+
+// 控制台输出目标
+const (
+	ConsoleStdout = "stdout" // 输出到标准输出
+	ConsoleStderr = "stderr" // 输出到标准错误，标准输出留给程序的执行结果
+	ConsoleNone   = "none"   // 只写日志文件
+)
 
 // DefaultLogConfig 返回默认的日志配置
 func DefaultLogConfig() *LogConfig {
@@ -93,9 +101,11 @@ func InitLogger(cfg *LogConfig, filename string) error {
 		}
 
 		// 创建核心 - 使用Tee同时输出到文件和Console
-		fileCore := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(fileWriter), level)
-		consoleCore := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(os.Stdout), level)
-		core := zapcore.NewTee(fileCore, consoleCore)
+		core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(fileWriter), level)
+		if console := consoleWriter(cfg.Console); console != nil {
+			consoleCore := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(console), level)
+			core = zapcore.NewTee(core, consoleCore)
+		}
 
 		// 创建全局日志记录器
 		globalLogger = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1), zap.AddStacktrace(zapcore.ErrorLevel))
@@ -105,6 +115,18 @@ func InitLogger(cfg *LogConfig, filename string) error {
 	return initErr
 }
 
+// consoleWriter 返回控制台输出目标，ConsoleNone 时返回 nil
+func consoleWriter(console string) *os.File {
+	switch console {
+	case ConsoleNone:
+		return nil
+	case ConsoleStderr:
+		return os.Stderr
+	default:
+		return os.Stdout
+	}
+}
+
 // parseLogLevel 解析日志级别字符串
 func parseLogLevel(levelStr string) zapcore.Level {
 	switch levelStr {
@@ -124,20 +146,18 @@ func parseLogLevel(levelStr string) zapcore.Level {
 // L 返回全局的 zap.Logger
 // 使用 sync.Once 保证并发安全，如果未初始化则使用默认配置自动初始化
 func L() *zap.Logger {
-	loggerOnce.Do(func() {
-		// 如果未初始化，使用默认配置初始化
-		_ = InitLogger(nil, "default.log")
-	})
+	// 如果未初始化，使用默认配置初始化
+	// InitLogger 内部已经使用 loggerOnce，不能再嵌套在 loggerOnce.Do 中调用，否则会死锁
+	_ = InitLogger(nil, "default.log")
 	return globalLogger
 }
 
 // S 返回全局的 zap.SugaredLogger
 // 使用 sync.Once 保证并发安全，如果未初始化则使用默认配置自动初始化
 func S() *zap.SugaredLogger {
-	loggerOnce.Do(func() {
-		// 如果未初始化，使用默认配置初始化
-		_ = InitLogger(nil, "default.log")
-	})
+	// 如果未初始化，使用默认配置初始化
+	// InitLogger 内部已经使用 loggerOnce，不能再嵌套在 loggerOnce.Do 中调用，否则会死锁
+	_ = InitLogger(nil, "default.log")
 	return sugarLogger
 }
 
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefBuckets 默认的直方图分桶（秒），覆盖从毫秒级命令到分钟级命令
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Default 默认注册表，包内定义的服务器指标以及 Go 运行时、进程指标均注册在此
+// 使用独立的注册表而不是 prometheus.DefaultRegisterer，避免依赖库注册的指标混入
+var Default = prometheus.NewRegistry()
+
+func init() {
+	Default.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler 返回输出 Default 注册表指标的 HTTP Handler，按请求的 Accept 头协商输出格式
+func Handler() http.Handler {
+	return HandlerFor(Default)
+}
+
+// HandlerFor 返回输出指定注册表指标的 HTTP Handler
+func HandlerFor(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
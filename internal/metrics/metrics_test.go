@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// scrape 请求 Handler 并用 Prometheus 文本格式解析器解析输出
+func scrape(t *testing.T, reg *prometheus.Registry) map[string]*dto.MetricFamily {
+	t.Helper()
+	srv := httptest.NewServer(HandlerFor(reg))
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("请求 metrics 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		t.Fatalf("解析 metrics 输出失败: %v", err)
+	}
+	return families
+}
+
+// labelsOf 返回时间序列的标签
+func labelsOf(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+// TestServerMetrics 验证内置指标的类型、标签和取值，标签值中的特殊字符被正确转义
+func TestServerMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(Executions, DispatchDuration, ActiveExecutions)
+
+	Executions.WithLabelValues("success", "0").Inc()
+	Executions.WithLabelValues("success", "0").Inc()
+	Executions.WithLabelValues("failed", "2").Inc()
+	ActiveExecutions.Inc()
+	peer := "http://a:1\"x\n"
+	DispatchDuration.WithLabelValues(peer).Observe(0.003)
+	DispatchDuration.WithLabelValues(peer).Observe(0.5)
+	DispatchDuration.WithLabelValues(peer).Observe(90)
+
+	families := scrape(t, reg)
+
+	execs := families["shell_executor_executions_total"]
+	if execs.GetType() != dto.MetricType_COUNTER || len(execs.GetMetric()) != 2 {
+		t.Fatalf("shell_executor_executions_total = %v", execs)
+	}
+	for _, m := range execs.GetMetric() {
+		want := map[string]float64{"success/0": 2, "failed/2": 1}
+		l := labelsOf(m)
+		if got := m.GetCounter().GetValue(); got != want[l["status"]+"/"+l["exit_code"]] {
+			t.Errorf("执行次数 %v = %v", l, got)
+		}
+	}
+
+	if active := families["shell_executor_active_executions"]; active.GetType() != dto.MetricType_GAUGE || active.GetMetric()[0].GetGauge().GetValue() != 1 {
+		t.Errorf("shell_executor_active_executions = %v", active)
+	}
+
+	dispatch := families["shell_executor_dispatch_duration_seconds"]
+	if dispatch.GetType() != dto.MetricType_HISTOGRAM || len(dispatch.GetMetric()) != 1 {
+		t.Fatalf("shell_executor_dispatch_duration_seconds = %v", dispatch)
+	}
+	m := dispatch.GetMetric()[0]
+	if got := labelsOf(m)["peer"]; got != peer {
+		t.Errorf("peer 标签 = %q, 预期 %q", got, peer)
+	}
+	h := m.GetHistogram()
+	// 文本格式中 le="+Inf" 的分桶也会被解析出来
+	if h.GetSampleCount() != 3 || math.Abs(h.GetSampleSum()-90.503) > 1e-9 || len(h.GetBucket()) != len(DefBuckets)+1 {
+		t.Fatalf("直方图 = %v", h)
+	}
+	for _, b := range h.GetBucket() {
+		want := uint64(1)
+		switch {
+		case math.IsInf(b.GetUpperBound(), 1):
+			want = 3
+		case b.GetUpperBound() >= 0.5:
+			want = 2
+		}
+		if b.GetCumulativeCount() != want {
+			t.Errorf("le=%v 的累计计数 = %d, 预期 %d", b.GetUpperBound(), b.GetCumulativeCount(), want)
+		}
+	}
+}
+
+// TestDefaultRegistry 验证默认注册表包含内置指标和 Go 运行时指标
+func TestDefaultRegistry(t *testing.T) {
+	GuardRejections.WithLabelValues("blacklist", "rm").Inc()
+	families := scrape(t, Default)
+	for _, name := range []string{"shell_executor_guard_rejections_total", "go_goroutines", "process_cpu_seconds_total"} {
+		if _, ok := families[name]; !ok {
+			t.Errorf("默认注册表缺少指标 %s", name)
+		}
+	}
+}
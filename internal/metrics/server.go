@@ -0,0 +1,48 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// 服务器内置指标，由各模块在关键路径上直接更新
+var (
+	// Executions 本地命令执行次数，按结果状态和退出码区分
+	Executions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "shell_executor_executions_total",
+		Help: "Total number of local command executions by status and exit code.",
+	}, []string{"status", "exit_code"})
+
+	// ExecutionDuration 本地命令执行耗时
+	ExecutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "shell_executor_execution_duration_seconds",
+		Help:    "Duration of local command executions in seconds.",
+		Buckets: DefBuckets,
+	}, []string{"status"})
+
+	// ActiveExecutions 正在执行的本地命令数量
+	ActiveExecutions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "shell_executor_active_executions",
+		Help: "Number of local command executions currently running.",
+	})
+
+	// DispatchDuration 向 Peer 分发命令的耗时（含网络和 Peer 执行时间）
+	DispatchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "shell_executor_dispatch_duration_seconds",
+		Help:    "Latency of dispatching a command to a peer in seconds.",
+		Buckets: DefBuckets,
+	}, []string{"peer"})
+
+	// DispatchErrors 向 Peer 分发命令失败的次数，reason 为 request、status、decode 或 too_large（响应超过大小上限）
+	DispatchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "shell_executor_dispatch_errors_total",
+		Help: "Total number of failed dispatches to a peer by reason.",
+	}, []string{"peer", "reason"})
+
+	// GuardRejections 安全卫士拦截的命令次数，reason 为 empty、blacklist、dangerous_args、cwd 或 env，rule 为命中的规则（cwd、env 时为空）
+	GuardRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "shell_executor_guard_rejections_total",
+		Help: "Total number of commands rejected by the security guard by rule.",
+	}, []string{"reason", "rule"})
+)
+
+func init() {
+	Default.MustRegister(Executions, ExecutionDuration, ActiveExecutions, DispatchDuration, DispatchErrors, GuardRejections)
+}
@@ -1,18 +1,46 @@
 package security
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
 	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/metrics"
 )
 
 // Guard 负责命令的安全审计
 type Guard struct {
 	blacklistedCommands []string
 	dangerousArgsRegex  []*regexp.Regexp
+	allowedCwdPrefixes  []string // 允许的工作目录前缀，为空表示不限制
+	allowedEnv          []string // 允许传入的环境变量名（path.Match 通配符），为空表示不限制
+	rules               []rule   // 按命令匹配的策略规则，按配置顺序匹配
+	policyVersion       string   // 策略指纹，用于比较集群内各节点的安全策略是否一致
+}
+
+// ActionSandbox 策略规则的动作：匹配的命令强制在沙箱中运行
+const ActionSandbox = "sandbox"
+
+// Rule 按命令匹配的策略规则，命令匹配第一条规则时使用该规则的执行参数
+type Rule struct {
+	Name   string          `json:"name"`             // 规则名称，用于日志和追踪
+	Match  string          `json:"match"`            // 正则表达式，匹配规范化（压缩空格）后的命令
+	Action string          `json:"action,omitempty"` // 动作，为空表示按默认方式执行，ActionSandbox 表示在沙箱中运行
+	Limits executor.Limits `json:"limits"`           // 覆盖执行器默认资源限制的字段，0 表示沿用默认值
+}
+
+// rule 预编译的策略规则
+type rule struct {
+	Rule
+	re *regexp.Regexp
 }
 
 // NewGuard 创建一个新的安全卫士实例
@@ -30,9 +58,101 @@ func NewGuard(blacklistedCommands []string, dangerousArgsRegex []string) (*Guard
 		g.dangerousArgsRegex = append(g.dangerousArgsRegex, re)
 	}
 
+	g.policyVersion = g.fingerprint()
 	return g, nil
 }
 
+// SetExecPolicy 设置执行参数的策略
+// cwdPrefixes: 允许的工作目录前缀（绝对路径），为空表示允许任意绝对路径
+// envNames: 允许传入的环境变量名，支持 path.Match 通配符（如 APP_*），为空表示允许任意变量名
+func (g *Guard) SetExecPolicy(cwdPrefixes []string, envNames []string) error {
+	for _, prefix := range cwdPrefixes {
+		if !filepath.IsAbs(prefix) {
+			return fmt.Errorf("cwd prefix '%s' is not an absolute path", prefix)
+		}
+	}
+	for _, pattern := range envNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid env name pattern '%s': %v", pattern, err)
+		}
+	}
+	g.allowedCwdPrefixes = cwdPrefixes
+	g.allowedEnv = envNames
+	g.policyVersion = g.fingerprint()
+	return nil
+}
+
+// SetRules 设置按命令匹配的策略规则，规则名称不能为空或重复
+func (g *Guard) SetRules(rules []Rule) error {
+	compiled := make([]rule, 0, len(rules))
+	names := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("rule matching '%s' has no name", r.Match)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate rule name '%s'", r.Name)
+		}
+		names[r.Name] = true
+		if r.Action != "" && r.Action != ActionSandbox {
+			return fmt.Errorf("unknown action '%s' in rule '%s'", r.Action, r.Name)
+		}
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return fmt.Errorf("invalid match pattern '%s' in rule '%s': %v", r.Match, r.Name, err)
+		}
+		compiled = append(compiled, rule{Rule: r, re: re})
+	}
+	g.rules = compiled
+	g.policyVersion = g.fingerprint()
+	return nil
+}
+
+// MatchRule 返回命令匹配的第一条策略规则，没有匹配的规则时返回 nil
+func (g *Guard) MatchRule(cmd string) *Rule {
+	cmd = strings.Join(strings.Fields(cmd), " ")
+	for i := range g.rules {
+		if g.rules[i].re.MatchString(cmd) {
+			logger.Debugf("[DEBUG] Guard: 命令匹配策略规则: %s", g.rules[i].Name)
+			return &g.rules[i].Rule
+		}
+	}
+	return nil
+}
+
+// PolicyVersion 返回当前安全策略的版本指纹
+// 相同的黑名单和危险参数正则（顺序一致）会得到相同的指纹
+func (g *Guard) PolicyVersion() string {
+	return g.policyVersion
+}
+
+// fingerprint 计算安全策略的 SHA256 指纹（取前 12 位）
+// 未设置执行参数策略和策略规则时与只有黑名单和危险参数正则的旧版本指纹相同
+func (g *Guard) fingerprint() string {
+	h := sha256.New()
+	for _, c := range g.blacklistedCommands {
+		h.Write([]byte("cmd:" + c + "\n"))
+	}
+	for _, re := range g.dangerousArgsRegex {
+		h.Write([]byte("re:" + re.String() + "\n"))
+	}
+	for _, prefix := range g.allowedCwdPrefixes {
+		h.Write([]byte("cwd:" + prefix + "\n"))
+	}
+	for _, name := range g.allowedEnv {
+		h.Write([]byte("env:" + name + "\n"))
+	}
+	for _, r := range g.rules {
+		limits, _ := json.Marshal(r.Limits)
+		line := "rule:" + r.Name + " " + r.Match + " " + string(limits)
+		if r.Action != "" {
+			line += " action:" + r.Action
+		}
+		h.Write([]byte(line + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
 // CheckCommand 检查命令是否安全
 // 返回 error 表示命令被拦截，nil 表示命令安全
 func (g *Guard) CheckCommand(cmd string) error {
@@ -40,6 +160,7 @@ func (g *Guard) CheckCommand(cmd string) error {
 
 	if cmd == "" {
 		logger.Debugf("[DEBUG] Guard: 命令为空")
+		metrics.GuardRejections.WithLabelValues("empty", "").Inc()
 		return errors.New("command is empty")
 	}
 
@@ -53,6 +174,7 @@ func (g *Guard) CheckCommand(cmd string) error {
 	parts := strings.Fields(cmd)
 	if len(parts) == 0 {
 		logger.Debugf("[DEBUG] Guard: 标准化后命令为空")
+		metrics.GuardRejections.WithLabelValues("empty", "").Inc()
 		return errors.New("command is empty after normalization")
 	}
 
@@ -65,6 +187,7 @@ func (g *Guard) CheckCommand(cmd string) error {
 	for _, blacklisted := range g.blacklistedCommands {
 		if commandVerb == blacklisted {
 			logger.Debugf("[DEBUG] Guard: 命令 '%s' 在黑名单中，拦截", commandVerb)
+			metrics.GuardRejections.WithLabelValues("blacklist", blacklisted).Inc()
 			return fmt.Errorf("command '%s' is blacklisted", commandVerb)
 		}
 	}
@@ -78,6 +201,7 @@ func (g *Guard) CheckCommand(cmd string) error {
 	for _, re := range g.dangerousArgsRegex {
 		if re.MatchString(cmd) {
 			logger.Debugf("[DEBUG] Guard: 命令匹配危险正则: %s，拦截", re.String())
+			metrics.GuardRejections.WithLabelValues("dangerous_args", re.String()).Inc()
 			return fmt.Errorf("command matches dangerous pattern: %s", re.String())
 		}
 	}
@@ -86,3 +210,69 @@ func (g *Guard) CheckCommand(cmd string) error {
 
 	return nil
 }
+
+// envNameRegex 合法的环境变量名
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CheckExecOptions 检查单次执行的工作目录和环境变量是否符合策略
+// 工作目录必须是绝对路径；如果目录存在，解析符号链接后的路径同样需要在允许的前缀下
+func (g *Guard) CheckExecOptions(cwd string, env map[string]string) error {
+	if cwd != "" {
+		if !filepath.IsAbs(cwd) {
+			metrics.GuardRejections.WithLabelValues("cwd", "").Inc()
+			return fmt.Errorf("cwd '%s' is not an absolute path", cwd)
+		}
+		paths := []string{filepath.Clean(cwd)}
+		if resolved, err := filepath.EvalSymlinks(cwd); err == nil && resolved != paths[0] {
+			paths = append(paths, resolved)
+		}
+		for _, p := range paths {
+			if !g.cwdAllowed(p) {
+				logger.Debugf("[DEBUG] Guard: 工作目录 %s 不在允许的前缀中: %v", p, g.allowedCwdPrefixes)
+				metrics.GuardRejections.WithLabelValues("cwd", "").Inc()
+				return fmt.Errorf("cwd '%s' is not allowed", cwd)
+			}
+		}
+	}
+
+	for name := range env {
+		if !envNameRegex.MatchString(name) {
+			metrics.GuardRejections.WithLabelValues("env", "").Inc()
+			return fmt.Errorf("invalid env name '%s'", name)
+		}
+		if !g.envAllowed(name) {
+			logger.Debugf("[DEBUG] Guard: 环境变量 %s 不在允许的列表中: %v", name, g.allowedEnv)
+			// 变量名由调用方指定，只记录在日志和错误中，不作为标签值，避免产生无限多的时间序列
+			metrics.GuardRejections.WithLabelValues("env", "").Inc()
+			return fmt.Errorf("env '%s' is not allowed", name)
+		}
+	}
+	return nil
+}
+
+// cwdAllowed 判断已清理的绝对路径是否在允许的前缀下（按路径分段匹配，/srv/app 不匹配 /srv/application）
+func (g *Guard) cwdAllowed(dir string) bool {
+	if len(g.allowedCwdPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range g.allowedCwdPrefixes {
+		prefix = filepath.Clean(prefix)
+		if dir == prefix || strings.HasPrefix(dir, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// envAllowed 判断环境变量名是否匹配允许的模式
+func (g *Guard) envAllowed(name string) bool {
+	if len(g.allowedEnv) == 0 {
+		return true
+	}
+	for _, pattern := range g.allowedEnv {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,375 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/tracing"
+)
+
+// peerRequestTimeout 向 Peer 发送打开、关闭会话请求的超时时间
+const peerRequestTimeout = 10 * time.Second
+
+// peerExecGrace 在 Peer 上执行命令时 HTTP 请求超时比执行超时多出的时间
+const peerExecGrace = 10 * time.Second
+
+// OpenRequest POST /internal/sessions 的请求体
+type OpenRequest struct {
+	ID        string `json:"id"`
+	Principal string `json:"principal"`
+}
+
+// ExecRequest POST /internal/sessions/{id}/exec 的请求体
+type ExecRequest struct {
+	Principal string `json:"principal"`
+	Cmd       string `json:"cmd"`
+	Timeout   int    `json:"timeout,omitempty"` // 执行超时（秒）
+}
+
+// CloseRequest POST /internal/sessions/{id}/close 的请求体
+type CloseRequest struct {
+	Principal string `json:"principal"`
+}
+
+// Session 集群会话在各节点上的状态
+type Session struct {
+	ID          string        `json:"id" jsonschema:"session ID"`
+	IdleTimeout int           `json:"idle_timeout" jsonschema:"seconds of inactivity after which the session is closed on each node"`
+	Nodes       []NodeSession `json:"nodes" jsonschema:"status of the session on each node, this node first"`
+}
+
+// NodeSession 会话在单个节点上的状态
+type NodeSession struct {
+	NodeName string `json:"node_name"`
+	Status   string `json:"status" jsonschema:"open, closed, failed or unreachable"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NodeExec 会话命令在单个节点上的执行结果，Err 为请求该节点失败的原因
+type NodeExec struct {
+	NodeName string
+	Result   ExecResult
+	Err      error
+}
+
+// record Coordinator 打开的会话及其所在节点
+type record struct {
+	principal string
+	nodes     []string // 会话所在的节点地址，空字符串表示本节点
+	lastUsed  time.Time
+}
+
+// Coordinator 在集群节点上打开会话，并将命令发送到会话所在的所有节点
+// 会话在各节点上以相同的 ID 独立存在，Coordinator 只记录会话所在的节点；
+// 使用本节点未打开（如客户端切换到其他节点）的会话时会询问所有节点
+type Coordinator struct {
+	local      *Manager
+	nodeName   string
+	peers      func() []string
+	token      string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	records map[string]*record
+}
+
+// NewCoordinator 创建会话协调器
+// local: 本节点的会话管理器
+// nodeName: 本节点名称
+// peers: 返回当前所有 Peer 地址，用于查找未知会话
+// token: 集群内部通信 Token
+func NewCoordinator(local *Manager, nodeName string, peers func() []string, token string) *Coordinator {
+	return &Coordinator{
+		local:      local,
+		nodeName:   nodeName,
+		peers:      peers,
+		token:      token,
+		httpClient: &http.Client{},
+		records:    make(map[string]*record),
+	}
+}
+
+// newID 生成随机的会话 ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Open 为 principal 在本节点（skipLocal 为 false 时）和 peers 上打开会话
+// 部分节点打开失败时会话仍然创建，失败节点的状态为 failed；所有节点都失败时返回错误
+func (c *Coordinator) Open(ctx context.Context, principal string, peers []string, skipLocal bool) (*Session, error) {
+	var nodes []string
+	if !skipLocal {
+		nodes = append(nodes, "")
+	}
+	nodes = append(nodes, peers...)
+	if len(nodes) == 0 {
+		return nil, errors.New("no target nodes")
+	}
+	id := newID()
+	logger.Infof("Sessions: 打开会话 %s, principal: %s, 节点数量: %d", id, principal, len(nodes))
+
+	results := eachNode(nodes, func(node string) (Info, error) {
+		if node == "" {
+			return c.local.Open(id, principal)
+		}
+		var info Info
+		err := c.do(ctx, peerRequestTimeout, node+"/internal/sessions", OpenRequest{ID: id, Principal: principal}, &info)
+		return info, err
+	})
+
+	sess := &Session{ID: id, IdleTimeout: int(c.local.cfg.GetIdleTimeout() / time.Second)}
+	rec := &record{principal: principal, lastUsed: time.Now()}
+	var lastErr error
+	for i, node := range nodes {
+		if err := results[i].err; err != nil {
+			logger.Warnf("Sessions: 会话 %s 在节点 %s 上打开失败: %v", id, c.displayName(node), err)
+			sess.Nodes = append(sess.Nodes, NodeSession{NodeName: c.displayName(node), Status: StatusFailed, Error: err.Error()})
+			lastErr = err
+			continue
+		}
+		rec.nodes = append(rec.nodes, node)
+		sess.Nodes = append(sess.Nodes, NodeSession{NodeName: c.displayName(node), Status: StatusOpen})
+	}
+	if len(rec.nodes) == 0 {
+		return nil, fmt.Errorf("open session failed on all nodes: %w", lastErr)
+	}
+
+	c.mu.Lock()
+	c.pruneLocked(time.Now())
+	c.records[id] = rec
+	c.mu.Unlock()
+	return sess, nil
+}
+
+// Exec 在会话所在的所有节点上执行命令，结果顺序与会话的节点顺序一致
+// 超时或 Shell 退出的节点上会话随之结束，之后不再向该节点发送命令
+func (c *Coordinator) Exec(ctx context.Context, id, principal, cmd string, timeout time.Duration) ([]NodeExec, error) {
+	// 超时按秒向上取整传给 Peer
+	req := ExecRequest{Principal: principal, Cmd: cmd, Timeout: int((timeout + time.Second - 1) / time.Second)}
+	nodes, results, err := query(c, id, principal, func(node string) (ExecResult, error) {
+		if node == "" {
+			return c.local.Exec(id, principal, cmd, timeout)
+		}
+		var res ExecResult
+		err := c.do(ctx, timeout+peerExecGrace, fmt.Sprintf("%s/internal/sessions/%s/exec", node, url.PathEscape(id)), req, &res)
+		return res, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]NodeExec, len(nodes))
+	var closed []string
+	for i, node := range nodes {
+		out[i] = NodeExec{NodeName: c.displayName(node), Result: results[i].value, Err: results[i].err}
+		if results[i].err == nil && results[i].value.Closed || errors.Is(results[i].err, ErrNotFound) {
+			closed = append(closed, node)
+		}
+	}
+	c.forget(id, closed)
+	return out, nil
+}
+
+// Close 在所有节点上关闭会话
+func (c *Coordinator) Close(ctx context.Context, id, principal string) (*Session, error) {
+	logger.Infof("Sessions: 关闭会话 %s", id)
+	nodes, results, err := query(c, id, principal, func(node string) (Info, error) {
+		if node == "" {
+			return c.local.Close(id, principal)
+		}
+		var info Info
+		err := c.do(ctx, peerRequestTimeout, fmt.Sprintf("%s/internal/sessions/%s/close", node, url.PathEscape(id)), CloseRequest{Principal: principal}, &info)
+		return info, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	delete(c.records, id)
+	c.mu.Unlock()
+
+	sess := &Session{ID: id, IdleTimeout: int(c.local.cfg.GetIdleTimeout() / time.Second)}
+	for i, node := range nodes {
+		ns := NodeSession{NodeName: c.displayName(node), Status: StatusClosed}
+		if err := results[i].err; err != nil && !errors.Is(err, ErrNotFound) {
+			ns.Status = StatusUnreachable
+			ns.Error = err.Error()
+		}
+		sess.Nodes = append(sess.Nodes, ns)
+	}
+	return sess, nil
+}
+
+// query 对会话所在的所有节点执行 op，返回节点和对应的结果
+// 本节点没有会话记录时询问所有节点，只保留拥有该会话的节点；所有节点都没有该会话时返回 ErrNotFound
+func query[T any](c *Coordinator, id, principal string, op func(node string) (T, error)) ([]string, []result[T], error) {
+	notFound := fmt.Errorf("%w: %s", ErrNotFound, id)
+
+	c.mu.Lock()
+	c.pruneLocked(time.Now())
+	rec, known := c.records[id]
+	var nodes []string
+	if known {
+		if rec.principal != principal {
+			c.mu.Unlock()
+			return nil, nil, notFound
+		}
+		nodes = append(nodes, rec.nodes...)
+		rec.lastUsed = time.Now()
+	}
+	c.mu.Unlock()
+
+	if !known {
+		nodes = append([]string{""}, c.peers()...)
+	}
+	results := eachNode(nodes, op)
+
+	if known {
+		for _, res := range results {
+			if !errors.Is(res.err, ErrNotFound) {
+				return nodes, results, nil
+			}
+		}
+		c.mu.Lock()
+		delete(c.records, id)
+		c.mu.Unlock()
+		return nil, nil, notFound
+	}
+
+	// 只保留拥有该会话的节点，并记录下来，之后只向这些节点发送请求
+	var kept []string
+	var keptResults []result[T]
+	for i, node := range nodes {
+		if results[i].err == nil || errors.Is(results[i].err, ErrBusy) {
+			kept = append(kept, node)
+			keptResults = append(keptResults, results[i])
+		}
+	}
+	if len(kept) == 0 {
+		return nil, nil, notFound
+	}
+	c.mu.Lock()
+	c.records[id] = &record{principal: principal, nodes: kept, lastUsed: time.Now()}
+	c.mu.Unlock()
+	return kept, keptResults, nil
+}
+
+// displayName 返回节点在结果中的名称：本节点使用节点名称，Peer 使用地址
+func (c *Coordinator) displayName(node string) string {
+	if node == "" {
+		return c.nodeName
+	}
+	return node
+}
+
+// forget 从会话记录中删除会话已结束的节点，没有剩余节点时删除记录
+func (c *Coordinator) forget(id string, nodes []string) {
+	if len(nodes) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rec, ok := c.records[id]
+	if !ok {
+		return
+	}
+	kept := rec.nodes[:0]
+	for _, node := range rec.nodes {
+		closed := false
+		for _, n := range nodes {
+			closed = closed || n == node
+		}
+		if !closed {
+			kept = append(kept, node)
+		}
+	}
+	rec.nodes = kept
+	if len(kept) == 0 {
+		delete(c.records, id)
+	}
+}
+
+// pruneLocked 清理已超过空闲超时、各节点上必然已关闭的会话记录
+func (c *Coordinator) pruneLocked(now time.Time) {
+	idle := c.local.cfg.GetIdleTimeout()
+	for id, rec := range c.records {
+		if now.Sub(rec.lastUsed) > idle {
+			delete(c.records, id)
+		}
+	}
+}
+
+// result 单个节点上操作的结果
+type result[T any] struct {
+	value T
+	err   error
+}
+
+// eachNode 并发地对每个节点执行 op，结果顺序与 nodes 一致
+func eachNode[T any](nodes []string, op func(node string) (T, error)) []result[T] {
+	results := make([]result[T], len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].value, results[i].err = op(node)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// do 向 Peer 发送会话请求，404 返回 ErrNotFound，409 返回 ErrBusy
+func (c *Coordinator) do(ctx context.Context, timeout time.Duration, url string, in any, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("marshal request failed: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request failed: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+	if c.token != "" {
+		req.Header.Set("X-Cluster-Token", c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrBusy
+	default:
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package sessions
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// 会话状态
+const (
+	StatusOpen        = "open"        // 会话可用
+	StatusClosed      = "closed"      // 会话已关闭或已结束
+	StatusFailed      = "failed"      // 会话无法在该节点上打开
+	StatusUnreachable = "unreachable" // Coordinator 无法访问该节点，会话可能仍然存在
+)
+
+// 命令执行状态，与 execute_command 的节点状态一致
+const (
+	ExecSuccess = "success" // 退出码为 0
+	ExecFailed  = "failed"  // 退出码非 0 或 Shell 已退出
+	ExecTimeout = "timeout" // 超时，会话已被关闭
+)
+
+// 默认配置
+const (
+	DefaultIdleTimeout     = 10 * time.Minute // 会话空闲多久后自动关闭
+	DefaultMaxPerPrincipal = 5                // 每个调用方在每个节点上的最大会话数
+	DefaultMaxOutput       = 1 << 20          // 每条命令返回的最大输出字节数
+)
+
+// NoExitCode 没有退出码（超时、Shell 被终止）时的 ExitCode
+const NoExitCode = -1
+
+var (
+	// ErrNotFound 会话不存在、已关闭或属于其他调用方
+	ErrNotFound = errors.New("session not found")
+	// ErrExists 会话 ID 已存在
+	ErrExists = errors.New("session already exists")
+	// ErrTooManySessions 调用方的会话数已达到 max_per_principal
+	ErrTooManySessions = errors.New("too many sessions")
+	// ErrBusy 会话中仍有命令在执行
+	ErrBusy = errors.New("session is busy")
+)
+
+// Config 会话配置
+type Config struct {
+	IdleTimeout     int `json:"idle_timeout"`      // 会话空闲多少秒后自动关闭，默认 600
+	MaxPerPrincipal int `json:"max_per_principal"` // 每个调用方在每个节点上的最大会话数，默认 5
+	MaxOutput       int `json:"max_output"`        // 每条命令返回的最大输出字节数，超出部分被丢弃，默认 1 MiB
+}
+
+// GetIdleTimeout 返回会话的空闲超时
+func (c Config) GetIdleTimeout() time.Duration {
+	if c.IdleTimeout <= 0 {
+		return DefaultIdleTimeout
+	}
+	return time.Duration(c.IdleTimeout) * time.Second
+}
+
+// maxPerPrincipal 返回每个调用方的最大会话数
+func (c Config) maxPerPrincipal() int {
+	if c.MaxPerPrincipal <= 0 {
+		return DefaultMaxPerPrincipal
+	}
+	return c.MaxPerPrincipal
+}
+
+// maxOutput 返回每条命令返回的最大输出字节数
+func (c Config) maxOutput() int {
+	if c.MaxOutput <= 0 {
+		return DefaultMaxOutput
+	}
+	return c.MaxOutput
+}
+
+// Info 单个节点上会话的状态
+type Info struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	OpenedAt   time.Time `json:"opened_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// ExecResult 会话中一条命令在单个节点上的执行结果
+// 标准输出和标准错误按写入顺序合并到 Output 中
+type ExecResult struct {
+	Status     string `json:"status"`
+	ExitCode   int    `json:"exit_code"`
+	Output     string `json:"output"`
+	Error      string `json:"error,omitempty"`
+	Encoding   string `json:"encoding,omitempty"`  // Output 的编码，空表示 UTF-8 文本，见 executor.EncodingBase64
+	Truncated  bool   `json:"truncated,omitempty"` // 输出超过 max_output，超出部分被丢弃
+	Closed     bool   `json:"closed,omitempty"`    // 命令结束后会话已关闭（Shell 退出或超时）
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// session 本节点上的一个会话
+type session struct {
+	id        string
+	principal string
+	sh        *shell
+	opened    time.Time
+	lastUsed  time.Time
+	busy      bool
+	idle      *time.Timer
+}
+
+// Manager 管理本节点上的 Shell 会话
+// 会话空闲超过 IdleTimeout 后自动关闭
+type Manager struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	exec     *executor.Executor
+	cfg      Config
+}
+
+// NewManager 创建会话管理器，Shell 通过 exec 启动，受其优雅关闭流程管理
+func NewManager(exec *executor.Executor, cfg Config) *Manager {
+	return &Manager{
+		sessions: make(map[string]*session),
+		exec:     exec,
+		cfg:      cfg,
+	}
+}
+
+// Open 为 principal 打开指定 ID 的会话
+func (m *Manager) Open(id, principal string) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[id]; ok {
+		return Info{}, ErrExists
+	}
+	count := 0
+	for _, s := range m.sessions {
+		if s.principal == principal {
+			count++
+		}
+	}
+	if count >= m.cfg.maxPerPrincipal() {
+		return Info{}, ErrTooManySessions
+	}
+
+	sh, err := startShell(m.exec)
+	if err != nil {
+		return Info{}, err
+	}
+	now := time.Now()
+	s := &session{
+		id:        id,
+		principal: principal,
+		sh:        sh,
+		opened:    now,
+		lastUsed:  now,
+	}
+	s.idle = time.AfterFunc(m.cfg.GetIdleTimeout(), func() { m.expire(s) })
+	m.sessions[id] = s
+	logger.Infof("会话已打开: id=%s, principal=%s", id, principal)
+	return s.info(StatusOpen), nil
+}
+
+// Exec 在会话中执行命令，timeout 为 0 表示不限制
+// 超时后会话被关闭；Shell 退出（如执行了 exit）时会话同样结束
+func (m *Manager) Exec(id, principal, cmd string, timeout time.Duration) (ExecResult, error) {
+	m.mu.Lock()
+	s, err := m.getLocked(id, principal)
+	if err == nil && s.busy {
+		err = ErrBusy
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return ExecResult{}, err
+	}
+	s.busy = true
+	s.idle.Stop()
+	m.mu.Unlock()
+
+	logger.Infof("会话 %s 执行命令: %s", id, cmd)
+	start := time.Now()
+	out, truncated, exitCode, runErr := s.sh.run(cmd, timeout, m.cfg.maxOutput())
+	res := ExecResult{
+		Status:     ExecSuccess,
+		ExitCode:   exitCode,
+		Truncated:  truncated,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	// 按本节点的默认选项规范化输出
+	res.Output, res.Encoding = m.exec.Normalization(nil).Apply(string(out))
+	switch {
+	case errors.Is(runErr, errTimeout):
+		res.Status = ExecTimeout
+		res.Error = "execution timeout, session closed"
+		res.Closed = true
+	case runErr != nil:
+		res.Status = ExecFailed
+		res.Error = "shell exited, session closed"
+		res.Closed = true
+	case exitCode != 0:
+		res.Status = ExecFailed
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.busy = false
+	s.lastUsed = time.Now()
+	if res.Closed {
+		m.removeLocked(s, res.Error)
+	} else if m.sessions[id] == s {
+		s.idle.Reset(m.cfg.GetIdleTimeout())
+	}
+	logger.Infof("会话 %s 命令已结束, 退出码: %d, 耗时: %dms", id, res.ExitCode, res.DurationMs)
+	return res, nil
+}
+
+// Close 关闭会话并终止其中的所有进程
+func (m *Manager) Close(id, principal string) (Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, err := m.getLocked(id, principal)
+	if err != nil {
+		return Info{}, err
+	}
+	m.removeLocked(s, "closed by client")
+	return s.info(StatusClosed), nil
+}
+
+// CloseAll 关闭所有会话，返回关闭的数量，用于服务器关闭
+func (m *Manager) CloseAll() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.sessions)
+	for _, s := range m.sessions {
+		m.removeLocked(s, "server shutting down")
+	}
+	return n
+}
+
+// getLocked 返回属于 principal 的会话，不属于该调用方的会话视为不存在
+func (m *Manager) getLocked(id, principal string) (*session, error) {
+	s, ok := m.sessions[id]
+	if !ok || s.principal != principal {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+// expire 关闭空闲超时的会话
+func (m *Manager) expire(s *session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions[s.id] == s && !s.busy {
+		m.removeLocked(s, "idle timeout")
+	}
+}
+
+// removeLocked 删除会话并终止 Shell
+func (m *Manager) removeLocked(s *session, reason string) {
+	if m.sessions[s.id] != s {
+		return
+	}
+	delete(m.sessions, s.id)
+	s.idle.Stop()
+	s.sh.close()
+	logger.Infof("会话已关闭: id=%s, 原因: %s", s.id, reason)
+}
+
+// info 生成会话状态
+func (s *session) info(status string) Info {
+	return Info{
+		ID:         s.id,
+		Status:     status,
+		OpenedAt:   s.opened,
+		LastUsedAt: s.lastUsed,
+	}
+}
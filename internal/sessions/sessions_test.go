@@ -0,0 +1,108 @@
+//go:build !windows
+
+package sessions
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// TestMain 先显式初始化 logger，避免懒加载初始化时的死锁
+func TestMain(m *testing.M) {
+	_ = logger.InitLogger(&logger.LogConfig{Level: "error", LogDir: os.TempDir()}, "sessions_test.log")
+	os.Exit(m.Run())
+}
+
+// TestSessionKeepsState 验证工作目录和环境变量在多次执行之间保留，并能得到每条命令的退出码
+func TestSessionKeepsState(t *testing.T) {
+	m := NewManager(executor.NewExecutor(), Config{})
+	defer m.CloseAll()
+
+	if _, err := m.Open("s", "alice"); err != nil {
+		t.Fatalf("打开会话失败: %v", err)
+	}
+	if res, err := m.Exec("s", "alice", "cd /tmp && export GREETING=hi", time.Second); err != nil || res.Status != ExecSuccess {
+		t.Fatalf("执行结果 = %+v, %v, 预期成功", res, err)
+	}
+	res, err := m.Exec("s", "alice", `echo "$PWD $GREETING"; echo oops >&2; exit_code() { return 3; }; exit_code`, time.Second)
+	if err != nil || res.Output != "/tmp hi\noops\n" || res.ExitCode != 3 || res.Status != ExecFailed {
+		t.Fatalf("执行结果 = %+v, %v, 预期保留工作目录和变量、合并标准错误、退出码 3", res, err)
+	}
+	// 不以换行结尾的输出保持原样，命令不会读取到会话的输入
+	if res, _ := m.Exec("s", "alice", "printf abc; cat", time.Second); res.Output != "abc" || res.ExitCode != 0 {
+		t.Fatalf("执行结果 = %+v, 预期输出 abc", res)
+	}
+
+	// 其他调用方无法使用该会话
+	if _, err := m.Exec("s", "bob", "true", time.Second); err != ErrNotFound {
+		t.Fatalf("其他调用方执行的错误 = %v, 预期 ErrNotFound", err)
+	}
+
+	// 超时后会话被关闭
+	res, err = m.Exec("s", "alice", "sleep 10", 200*time.Millisecond)
+	if err != nil || res.Status != ExecTimeout || !res.Closed {
+		t.Fatalf("超时的执行结果 = %+v, %v, 预期 timeout 且会话已关闭", res, err)
+	}
+	if _, err := m.Exec("s", "alice", "true", time.Second); err != ErrNotFound {
+		t.Fatalf("超时后会话应已关闭, err = %v", err)
+	}
+}
+
+// TestSessionDropsIdleOutput 验证没有命令执行时后台进程的输出被丢弃，不会在会话中累积
+func TestSessionDropsIdleOutput(t *testing.T) {
+	m := NewManager(executor.NewExecutor(), Config{})
+	defer m.CloseAll()
+
+	if _, err := m.Open("s", "alice"); err != nil {
+		t.Fatalf("打开会话失败: %v", err)
+	}
+	if res, err := m.Exec("s", "alice", "(sleep 0.2; head -c 1000000 /dev/zero) &", time.Second); err != nil || res.Status != ExecSuccess {
+		t.Fatalf("执行结果 = %+v, %v, 预期成功", res, err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	m.mu.Lock()
+	sh := m.sessions["s"].sh
+	m.mu.Unlock()
+	sh.mu.Lock()
+	buffered := len(sh.buf)
+	sh.mu.Unlock()
+	if buffered != 0 {
+		t.Errorf("空闲期间缓存了 %d 字节的输出, 预期丢弃", buffered)
+	}
+	if res, err := m.Exec("s", "alice", "echo next", time.Second); err != nil || res.Output != "next\n" {
+		t.Errorf("之后的执行结果 = %+v, %v, 预期只包含本条命令的输出", res, err)
+	}
+}
+
+// TestSessionLimits 验证每个调用方的会话数限制、exit 结束会话和空闲超时
+func TestSessionLimits(t *testing.T) {
+	m := NewManager(executor.NewExecutor(), Config{MaxPerPrincipal: 1, IdleTimeout: 1})
+	defer m.CloseAll()
+
+	if _, err := m.Open("a", "alice"); err != nil {
+		t.Fatalf("打开会话失败: %v", err)
+	}
+	if _, err := m.Open("b", "alice"); err != ErrTooManySessions {
+		t.Fatalf("超过上限的错误 = %v, 预期 ErrTooManySessions", err)
+	}
+	if _, err := m.Open("c", "bob"); err != nil {
+		t.Fatalf("其他调用方不受影响: %v", err)
+	}
+
+	res, err := m.Exec("a", "alice", "echo bye; exit 4", time.Second)
+	if err != nil || res.Output != "bye\n" || res.ExitCode != 4 || !res.Closed {
+		t.Fatalf("exit 的执行结果 = %+v, %v, 预期退出码 4 且会话已关闭", res, err)
+	}
+	if _, err := m.Open("b", "alice"); err != nil {
+		t.Fatalf("会话结束后应可以打开新会话: %v", err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := m.Exec("c", "bob", "true", time.Second); err != ErrNotFound {
+		t.Fatalf("空闲超时后会话应已关闭, err = %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package sessions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/executor"
+)
+
+// shell 一个持久化的 /bin/sh 进程
+// 每条命令通过 eval 在同一个 Shell 中执行，之后打印带退出码的哨兵行，
+// 读取输出直到哨兵行即得到该命令的输出和退出码
+type shell struct {
+	proc   *executor.Process
+	stdin  *os.File
+	prefix string // 哨兵行的两部分，命令中只出现拆开的形式，避免回显（如 set -x）被误认为哨兵
+	suffix string
+
+	mu      sync.Mutex
+	buf     []byte        // 尚未被读取的输出
+	running bool          // 是否有命令在执行，没有时收到的输出（如会话中的后台进程）直接丢弃
+	notify  chan struct{} // 有新输出时通知
+}
+
+// startShell 启动 Shell 进程
+func startShell(exec *executor.Executor) (*shell, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create stdin pipe failed: %v", err)
+	}
+	token := make([]byte, 8)
+	rand.Read(token)
+	s := &shell{
+		stdin:  w,
+		prefix: "__SESSION_",
+		suffix: hex.EncodeToString(token) + "__",
+		notify: make(chan struct{}, 1),
+	}
+	proc, err := exec.StartShell(r, s)
+	// 子进程已持有读端
+	r.Close()
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	s.proc = proc
+	select {
+	case <-proc.Done():
+		w.Close()
+		return nil, fmt.Errorf("start shell failed: %s", proc.Wait().Error)
+	default:
+	}
+	return s, nil
+}
+
+// Write 接收 Shell 的标准输出和标准错误
+// 没有命令在执行时丢弃输出，避免会话中的后台进程（如 yes &）在空闲期间占满内存
+func (s *shell) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return len(p), nil
+	}
+	s.buf = append(s.buf, p...)
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// setRunning 标记命令开始或结束执行，同时丢弃之前未读取的输出
+func (s *shell) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = running
+	s.buf = nil
+}
+
+// take 取出尚未读取的输出
+func (s *shell) take() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := s.buf
+	s.buf = nil
+	return data
+}
+
+// errTimeout 命令在超时时间内没有结束
+var errTimeout = errors.New("execution timeout")
+
+// errExited Shell 在命令结束前退出
+var errExited = errors.New("shell exited")
+
+// run 在 Shell 中执行命令，返回输出（最多 limit 字节）、是否被截断和退出码
+// 命令的标准输入为 /dev/null；超时返回 errTimeout，Shell 退出返回 errExited，此时 Shell 不可再用
+func (s *shell) run(cmd string, timeout time.Duration, limit int) (out []byte, truncated bool, exitCode int, err error) {
+	// 只接收本条命令执行期间的输出，上一条命令之后后台进程产生的输出已被丢弃
+	s.setRunning(true)
+	defer s.setRunning(false)
+
+	script := fmt.Sprintf("command eval %s </dev/null\nprintf '\\n%%s%%s %%d\\n' '%s' '%s' \"$?\"\n", quote(cmd), s.prefix, s.suffix)
+	if _, err := s.stdin.WriteString(script); err != nil {
+		return nil, false, NoExitCode, errExited
+	}
+
+	marker := []byte("\n" + s.prefix + s.suffix + " ")
+	// 保留足够的末尾数据，哨兵行被拆到两次写入时也能识别
+	keep := len(marker) + 16
+	var pending []byte
+	appendOut := func(data []byte) {
+		if truncated {
+			return
+		}
+		if n := limit - len(out); n < len(data) {
+			truncated = true
+			// 截断位置不落在 UTF-8 字符中间，避免文本输出被当作二进制输出
+			out = append(out, data[:max(n, 0)]...)
+			out = out[:completeLen(out)]
+			return
+		}
+		out = append(out, data...)
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	exited := false
+	for {
+		pending = append(pending, s.take()...)
+		if i := bytes.Index(pending, marker); i >= 0 {
+			rest := pending[i+len(marker):]
+			if end := bytes.IndexByte(rest, '\n'); end >= 0 {
+				appendOut(pending[:i])
+				code, _ := strconv.Atoi(string(rest[:end]))
+				return out, truncated, code, nil
+			}
+		} else if len(pending) > keep {
+			appendOut(pending[:len(pending)-keep])
+			pending = pending[len(pending)-keep:]
+		}
+		if exited {
+			appendOut(pending)
+			return out, truncated, s.proc.Wait().ExitCode, errExited
+		}
+
+		select {
+		case <-s.notify:
+		case <-s.proc.Done():
+			// 读取退出前的剩余输出
+			exited = true
+		case <-deadline:
+			appendOut(pending)
+			return out, truncated, NoExitCode, errTimeout
+		}
+	}
+}
+
+// close 终止 Shell 的整个进程组
+func (s *shell) close() {
+	s.stdin.Close()
+	s.proc.Kill()
+}
+
+// quote 将字符串转义为 Shell 单引号字符串
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// completeLen 返回去掉末尾不完整 UTF-8 字符后的长度
+func completeLen(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(p)
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileExporter 将 Span 以 JSON Lines 格式追加写入本地文件，用于离线排查
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// fileSpan FileExporter 输出的单行 JSON 结构
+type fileSpan struct {
+	TraceID       string            `json:"trace_id"`
+	SpanID        string            `json:"span_id"`
+	ParentSpanID  string            `json:"parent_span_id,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind"`
+	Start         time.Time         `json:"start"`
+	End           time.Time         `json:"end"`
+	DurationMs    float64           `json:"duration_ms"`
+	Status        string            `json:"status"`
+	StatusMessage string            `json:"status_message,omitempty"`
+	Attributes    map[string]any    `json:"attributes,omitempty"`
+	Resource      map[string]string `json:"resource,omitempty"`
+}
+
+// NewFileExporter 创建文件导出器，文件不存在时自动创建
+func NewFileExporter(path string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: open trace file failed: %w", err)
+	}
+	return &FileExporter{file: f}, nil
+}
+
+// Export 每个 Span 写入一行 JSON
+func (e *FileExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	enc := json.NewEncoder(e.file)
+	for _, s := range spans {
+		line := fileSpan{
+			TraceID:       s.TraceID.String(),
+			SpanID:        s.SpanID.String(),
+			Name:          s.Name,
+			Kind:          s.Kind.String(),
+			Start:         s.Start,
+			End:           s.End,
+			DurationMs:    float64(s.End.Sub(s.Start).Microseconds()) / 1000,
+			Status:        s.Status,
+			StatusMessage: s.StatusMessage,
+			Attributes:    s.Attributes,
+			Resource:      s.Resource,
+		}
+		if s.ParentSpanID.IsValid() {
+			line.ParentSpanID = s.ParentSpanID.String()
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown 关闭文件
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
@@ -0,0 +1,198 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// 导出器类型
+const (
+	ExporterNone = "none" // 不导出，仅传播 traceparent
+	ExporterOTLP = "otlp" // OTLP/HTTP（JSON 编码）
+	ExporterFile = "file" // 本地 JSON 文件，每行一个 Span
+)
+
+// Config 链路追踪配置
+type Config struct {
+	Exporter string            `json:"exporter"` // 导出器: none, otlp, file，默认 none
+	Endpoint string            `json:"endpoint"` // OTLP/HTTP 地址，如 http://localhost:4318/v1/traces
+	Headers  map[string]string `json:"headers"`  // OTLP 请求附加的请求头，如鉴权信息
+	File     string            `json:"file"`     // file 导出器的输出路径，默认 traces.jsonl
+}
+
+// SpanData 已结束 Span 的只读数据，交给 Exporter 导出
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	TraceID       TraceID
+	SpanID        SpanID
+	ParentSpanID  SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]any
+	Status        string
+	StatusMessage string
+	Resource      map[string]string // 产生 Span 的服务信息，如 service.name、node.name
+}
+
+// Exporter Span 导出器接口，实现该接口即可接入新的后端
+type Exporter interface {
+	// Export 导出一批 Span
+	Export(ctx context.Context, spans []SpanData) error
+	// Shutdown 释放导出器资源
+	Shutdown(ctx context.Context) error
+}
+
+// 批量导出参数
+const (
+	queueSize     = 2048
+	batchSize     = 256
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+// provider 负责将结束的 Span 批量交给 Exporter
+type provider struct {
+	resource map[string]string
+	exporter Exporter
+	queue    chan SpanData
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+var global atomic.Pointer[provider]
+
+func getProvider() *provider {
+	return global.Load()
+}
+
+// NewExporter 根据配置创建导出器，Exporter 为空或 none 时返回 nil
+func NewExporter(cfg Config) (Exporter, error) {
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterOTLP:
+		if cfg.Endpoint == "" {
+			return nil, fmt.Errorf("tracing: otlp exporter requires endpoint")
+		}
+		return NewOTLPExporter(cfg.Endpoint, cfg.Headers), nil
+	case ExporterFile:
+		path := cfg.File
+		if path == "" {
+			path = "traces.jsonl"
+		}
+		return NewFileExporter(path)
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", cfg.Exporter)
+	}
+}
+
+// Init 初始化全局链路追踪
+// exporter 为 nil 时不导出 Span，但仍会传播调用方的 traceparent
+// resource 描述本服务，如 {"service.name": "shell-executor-mcp", "node.name": "node-01"}
+func Init(exporter Exporter, resource map[string]string) {
+	if exporter == nil {
+		return
+	}
+	p := &provider{
+		resource: resource,
+		exporter: exporter,
+		queue:    make(chan SpanData, queueSize),
+		done:     make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go p.run()
+
+	if old := global.Swap(p); old != nil {
+		old.shutdown(context.Background())
+	}
+}
+
+// Shutdown 导出队列中剩余的 Span 并关闭导出器
+func Shutdown(ctx context.Context) error {
+	p := global.Swap(nil)
+	if p == nil {
+		return nil
+	}
+	return p.shutdown(ctx)
+}
+
+// enqueue 将 Span 放入导出队列，队列满时丢弃
+func (p *provider) enqueue(span SpanData) {
+	select {
+	case p.queue <- span:
+	case <-p.done:
+	default:
+		logger.Debugf("Tracing: 导出队列已满，丢弃 Span: %s", span.Name)
+	}
+}
+
+// run 按批次或时间间隔导出 Span
+func (p *provider) run() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		if err := p.exporter.Export(ctx, batch); err != nil {
+			logger.Warnf("Tracing: 导出 %d 个 Span 失败: %v", len(batch), err)
+		}
+		cancel()
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-p.done:
+			// 取出队列中剩余的 Span 后退出
+			for {
+				select {
+				case span := <-p.queue:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// shutdown 停止导出循环并关闭导出器
+func (p *provider) shutdown(ctx context.Context) error {
+	var err error
+	p.once.Do(func() {
+		close(p.done)
+		finished := make(chan struct{})
+		go func() {
+			p.wg.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+		err = p.exporter.Shutdown(ctx)
+	})
+	return err
+}
@@ -0,0 +1,272 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID 16 字节的 Trace 标识
+type TraceID [16]byte
+
+// SpanID 8 字节的 Span 标识
+type SpanID [8]byte
+
+// String 返回小写十六进制表示
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid 全零为非法 TraceID
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// String 返回小写十六进制表示
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid 全零为非法 SpanID
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanKind Span 类型，取值与 OTLP 一致
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1 // 进程内部操作
+	KindServer   SpanKind = 2 // 处理远程请求
+	KindClient   SpanKind = 3 // 发起远程请求
+)
+
+// String 返回 Span 类型名称
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// Span 状态
+const (
+	StatusUnset = "unset"
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// SpanContext 跨进程传播的 Span 标识
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid TraceID 和 SpanID 均合法时返回 true
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// TraceparentHeader W3C Trace Context 的请求头名称
+const TraceparentHeader = "traceparent"
+
+// Traceparent 按 W3C Trace Context 格式编码，如 00-<trace-id>-<span-id>-01
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent 解析 W3C traceparent 请求头
+func ParseTraceparent(h string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", h)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("unsupported traceparent version %q", parts[0])
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace id: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid span id: %w", err)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, fmt.Errorf("invalid trace flags: %w", err)
+	}
+	if !sc.IsValid() {
+		return sc, errors.New("traceparent contains all-zero id")
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	return sc, nil
+}
+
+// Span 表示一次操作的耗时和属性
+type Span struct {
+	mu         sync.Mutex
+	name       string
+	kind       SpanKind
+	sc         SpanContext
+	parent     SpanID
+	start      time.Time
+	end        time.Time
+	attributes map[string]any
+	status     string
+	statusMsg  string
+	ended      bool
+}
+
+// SpanContext 返回 Span 的传播标识
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetAttribute 设置 Span 属性，value 支持 string、bool、整数和浮点数
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.attributes[key] = value
+}
+
+// SetStatus 设置 Span 状态（StatusOK 或 StatusError）
+func (s *Span) SetStatus(status, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.status = status
+	s.statusMsg = msg
+}
+
+// RecordError 记录错误并将状态设置为 StatusError，err 为 nil 时忽略
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetAttribute("error.message", err.Error())
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End 结束 Span 并交给导出器，重复调用无效
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	data := s.snapshotLocked()
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		if p := getProvider(); p != nil {
+			p.enqueue(data)
+		}
+	}
+}
+
+// snapshotLocked 生成导出用的只读数据
+func (s *Span) snapshotLocked() SpanData {
+	attrs := make(map[string]any, len(s.attributes))
+	for k, v := range s.attributes {
+		attrs[k] = v
+	}
+	data := SpanData{
+		Name:          s.name,
+		Kind:          s.kind,
+		TraceID:       s.sc.TraceID,
+		SpanID:        s.sc.SpanID,
+		ParentSpanID:  s.parent,
+		Start:         s.start,
+		End:           s.end,
+		Attributes:    attrs,
+		Status:        s.status,
+		StatusMessage: s.statusMsg,
+	}
+	if p := getProvider(); p != nil {
+		data.Resource = p.resource
+	}
+	return data
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start 创建 Span 并返回携带该 Span 的 context
+// 父 Span 优先取 ctx 中的本地 Span，其次取 Extract 得到的远程 SpanContext，否则创建新的 Trace
+// 调用方必须调用 span.End()
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]any),
+		status:     StatusUnset,
+	}
+
+	var parent SpanContext
+	if p, ok := ctx.Value(spanKey{}).(*Span); ok {
+		parent = p.sc
+	} else if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		parent = sc
+	}
+
+	if parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.sc.Sampled = parent.Sampled
+		span.parent = parent.SpanID
+	} else {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = getProvider() != nil
+	}
+	rand.Read(span.sc.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// FromContext 返回 ctx 中的当前 Span，不存在时返回 nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Inject 将 ctx 中当前 Span 的 traceparent 写入请求头
+func Inject(ctx context.Context, header http.Header) {
+	if span := FromContext(ctx); span != nil {
+		header.Set(TraceparentHeader, span.sc.Traceparent())
+	}
+}
+
+// Extract 从请求头解析 traceparent，返回携带远程父 Span 的 context
+// 请求头不存在或格式非法时返回原 ctx
+func Extract(ctx context.Context, header http.Header) context.Context {
+	h := header.Get(TraceparentHeader)
+	if h == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(h)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Middleware 从请求头提取 traceparent 并放入请求的 context，使处理函数中的 Span 加入调用方的 Trace
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(Extract(r.Context(), r.Header)))
+	})
+}
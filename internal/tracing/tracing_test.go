@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AceDarkknight/shell-executor-mcp/internal/logger"
+)
+
+// TestMain 先显式初始化 logger，避免懒加载初始化时的死锁
+func TestMain(m *testing.M) {
+	_ = logger.InitLogger(&logger.LogConfig{Level: "error", LogDir: os.TempDir()}, "tracing_test.log")
+	os.Exit(m.Run())
+}
+
+// TestTraceparent 验证 traceparent 的解析和编码
+func TestTraceparent(t *testing.T) {
+	const h = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(h)
+	if err != nil {
+		t.Fatalf("ParseTraceparent 失败: %v", err)
+	}
+	if !sc.Sampled || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("解析结果错误: %+v", sc)
+	}
+	if got := sc.Traceparent(); got != h {
+		t.Errorf("Traceparent() = %s, 预期 %s", got, h)
+	}
+
+	for _, bad := range []string{"", "00-xyz-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("ParseTraceparent(%q) 应返回错误", bad)
+		}
+	}
+}
+
+// TestPropagationAndFileExporter 验证跨 HTTP 传播后 Peer 端 Span 加入同一 Trace，并由文件导出器写出
+func TestPropagationAndFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exp, err := NewExporter(Config{Exporter: ExporterFile, File: path})
+	if err != nil {
+		t.Fatalf("创建文件导出器失败: %v", err)
+	}
+	Init(exp, map[string]string{"service.name": "test"})
+
+	// 模拟 Peer 端
+	peer := httptest.NewServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "internal.exec", KindServer)
+		span.End()
+	})))
+	defer peer.Close()
+
+	// 模拟 Coordinator 端
+	ctx, root := Start(context.Background(), "mcp.tool execute_command", KindServer)
+	ctx, client := Start(ctx, "dispatch.peer", KindClient)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, peer.URL, nil)
+	Inject(ctx, req.Header)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求 peer 失败: %v", err)
+	}
+	resp.Body.Close()
+	client.End()
+	root.End()
+
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown 失败: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("打开 trace 文件失败: %v", err)
+	}
+	defer f.Close()
+
+	spans := map[string]fileSpan{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s fileSpan
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatalf("解析 trace 行失败: %v", err)
+		}
+		spans[s.Name] = s
+	}
+	if len(spans) != 3 {
+		t.Fatalf("导出 Span 数量 = %d, 预期 3: %v", len(spans), spans)
+	}
+
+	traceID := root.SpanContext().TraceID.String()
+	for name, s := range spans {
+		if s.TraceID != traceID {
+			t.Errorf("Span %s 的 trace_id = %s, 预期 %s", name, s.TraceID, traceID)
+		}
+	}
+	if spans["internal.exec"].ParentSpanID != spans["dispatch.peer"].SpanID {
+		t.Errorf("peer Span 的父 Span 错误: %+v", spans["internal.exec"])
+	}
+	if spans["mcp.tool execute_command"].ParentSpanID != "" || spans["mcp.tool execute_command"].Resource["service.name"] != "test" {
+		t.Errorf("根 Span 错误: %+v", spans["mcp.tool execute_command"])
+	}
+}
+
+// TestOTLPExporter 验证 OTLP/HTTP 导出的 JSON 结构
+func TestOTLPExporter(t *testing.T) {
+	var got otlpRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer x" {
+			t.Errorf("请求错误: path=%s auth=%s", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("解析 OTLP 请求失败: %v", err)
+		}
+	}))
+	defer collector.Close()
+
+	Init(NewOTLPExporter(collector.URL+"/v1/traces", map[string]string{"Authorization": "Bearer x"}), map[string]string{"node.name": "n1"})
+	_, span := Start(context.Background(), "security.check", KindInternal)
+	span.SetAttribute("security.allowed", false)
+	span.SetAttribute("exec.exit_code", 2)
+	span.SetStatus(StatusError, "blocked")
+	span.End()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown 失败: %v", err)
+	}
+
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("OTLP 请求结构错误: %+v", got)
+	}
+	s := got.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if s.Name != "security.check" || s.Kind != int(KindInternal) || s.Status.Code != 2 || len(s.TraceID) != 32 {
+		t.Errorf("OTLP Span 错误: %+v", s)
+	}
+	if len(s.Attributes) != 2 || s.Attributes[0].Key != "exec.exit_code" || s.Attributes[0].Value["intValue"] != "2" {
+		t.Errorf("OTLP 属性错误: %+v", s.Attributes)
+	}
+}
@@ -0,0 +1,5 @@
+package version
+
+// Version 当前程序版本号
+// 可在构建时通过 -ldflags "-X github.com/AceDarkknight/shell-executor-mcp/internal/version.Version=x.y.z" 覆盖
+var Version = "1.0.0"
@@ -8,11 +8,17 @@ import (
 )
 
 // ClientConfig 定义客户端的配置结构
+// 配置了 contexts 时，当前上下文的连接配置会覆盖顶层的 servers、token 等字段，见 UseContext
 type ClientConfig struct {
-	Servers            []ServerConfig `json:"servers"`              // 服务器列表
-	Token              string         `json:"token"`                // 连接 Token
-	InsecureSkipVerify bool           `json:"insecure_skip_verify"` // 跳过 TLS 证书验证（用于自签证书）
-	Log                LogConfig      `json:"log"`                  // 日志配置
+	Servers            []ServerConfig  `json:"servers,omitempty"`         // 服务器列表
+	Token              string          `json:"token,omitempty"`           // 连接 Token（明文，建议改用 token_env、token_file 或 token_command）
+	TokenEnv           string          `json:"token_env,omitempty"`       // 从该环境变量读取 Token
+	TokenFile          string          `json:"token_file,omitempty"`      // 从该文件读取 Token
+	TokenCommand       string          `json:"token_command,omitempty"`   // 凭证助手命令，以其标准输出作为 Token
+	InsecureSkipVerify bool            `json:"insecure_skip_verify"`      // 跳过 TLS 证书验证（用于自签证书）
+	Log                LogConfig       `json:"log"`                       // 日志配置
+	CurrentContext     string          `json:"current_context,omitempty"` // 默认使用的上下文名称
+	Contexts           []ContextConfig `json:"contexts,omitempty"`        // 命名的上下文（集群）列表
 }
 
 // ServerConfig 定义服务器的配置结构
@@ -43,17 +49,56 @@ func (lc *LogConfig) ToLoggerConfig() logger.LogConfig {
 	}
 }
 
+// DefaultClientConfig 返回客户端配置的默认值
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		Log: LogConfig{
+			Level:      "info",
+			LogDir:     "logs",
+			MaxSize:    100,
+			MaxBackups: 3,
+			MaxAge:     28,
+			Compress:   true,
+		},
+	}
+}
+
 // LoadClientConfig 从指定路径加载客户端配置
 func LoadClientConfig(path string) (*ClientConfig, error) {
+	var cfg ClientConfig
+	if err := ReadClientConfig(path, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ReadClientConfig 读取配置文件并覆盖到 cfg 上，文件中未出现的字段保持原值
+func ReadClientConfig(path string, cfg *ClientConfig) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return json.Unmarshal(data, cfg)
+}
 
-	var cfg ClientConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+// SetCurrentContext 修改配置文件中的 current_context，文件中的其他配置项保持原值
+// （不经过 ClientConfig 序列化，避免把文件中未出现的字段写成零值）
+func SetCurrentContext(path, name string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc["current_context"], err = json.Marshal(name); err != nil {
+		return err
 	}
 
-	return &cfg, nil
+	data, err = json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
 }
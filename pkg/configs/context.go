@@ -0,0 +1,51 @@
+package configs
+
+import (
+	"fmt"
+)
+
+// ContextConfig 定义一个命名的上下文，对应一个集群的连接配置
+type ContextConfig struct {
+	Name               string         `json:"name"`                    // 上下文名称，如 prod、staging
+	Servers            []ServerConfig `json:"servers"`                 // 服务器列表
+	Token              string         `json:"token,omitempty"`         // 连接 Token（明文）
+	TokenEnv           string         `json:"token_env,omitempty"`     // 从该环境变量读取 Token
+	TokenFile          string         `json:"token_file,omitempty"`    // 从该文件读取 Token
+	TokenCommand       string         `json:"token_command,omitempty"` // 凭证助手命令，以其标准输出作为 Token
+	InsecureSkipVerify bool           `json:"insecure_skip_verify,omitempty"`
+}
+
+// Context 返回指定名称的上下文
+func (c *ClientConfig) Context(name string) (*ContextConfig, bool) {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i], true
+		}
+	}
+	return nil, false
+}
+
+// UseContext 使用指定上下文的连接配置替换顶层的 servers、token 和 TLS 配置
+// name 为空时使用 current_context；两者都为空时保持顶层配置不变
+// 上下文之间不继承顶层的 Token，避免把一个集群的 Token 发送到另一个集群
+func (c *ClientConfig) UseContext(name string) error {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return nil
+	}
+
+	ctx, ok := c.Context(name)
+	if !ok {
+		return fmt.Errorf("未找到上下文: %s", name)
+	}
+	c.Servers = ctx.Servers
+	c.Token = ctx.Token
+	c.TokenEnv = ctx.TokenEnv
+	c.TokenFile = ctx.TokenFile
+	c.TokenCommand = ctx.TokenCommand
+	c.InsecureSkipVerify = ctx.InsecureSkipVerify
+	c.CurrentContext = name
+	return nil
+}
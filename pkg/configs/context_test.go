@@ -0,0 +1,94 @@
+package configs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUseContext 验证上下文的连接配置替换顶层配置，且不继承顶层 Token
+func TestUseContext(t *testing.T) {
+	cfg := &ClientConfig{
+		Servers:        []ServerConfig{{Name: "local", URL: "http://127.0.0.1:8090/mcp"}},
+		Token:          "top-level",
+		CurrentContext: "staging",
+		Contexts: []ContextConfig{
+			{Name: "prod", Servers: []ServerConfig{{Name: "prod-01", URL: "https://prod/mcp"}}, TokenEnv: "PROD_TOKEN"},
+			{Name: "staging", Servers: []ServerConfig{{Name: "stg-01", URL: "https://stg/mcp"}}, InsecureSkipVerify: true},
+		},
+	}
+
+	if err := cfg.UseContext(""); err != nil {
+		t.Fatalf("使用 current_context 失败: %v", err)
+	}
+	if cfg.Servers[0].Name != "stg-01" || !cfg.InsecureSkipVerify || cfg.Token != "" {
+		t.Errorf("staging 上下文未生效: %+v", cfg)
+	}
+
+	if err := cfg.UseContext("prod"); err != nil {
+		t.Fatalf("切换到 prod 失败: %v", err)
+	}
+	if cfg.Servers[0].Name != "prod-01" || cfg.TokenEnv != "PROD_TOKEN" || cfg.InsecureSkipVerify || cfg.CurrentContext != "prod" {
+		t.Errorf("prod 上下文未生效: %+v", cfg)
+	}
+
+	if err := cfg.UseContext("lab"); err == nil {
+		t.Error("不存在的上下文应返回错误")
+	}
+}
+
+// TestResolveToken 验证从明文、环境变量、文件和凭证助手命令读取 Token
+func TestResolveToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_MCP_TOKEN", "from-env")
+
+	tests := []struct {
+		name    string
+		cfg     ClientConfig
+		want    string
+		wantErr bool
+	}{
+		{"未配置", ClientConfig{}, "", false},
+		{"明文优先", ClientConfig{Token: "inline", TokenEnv: "TEST_MCP_TOKEN"}, "inline", false},
+		{"环境变量", ClientConfig{TokenEnv: "TEST_MCP_TOKEN"}, "from-env", false},
+		{"环境变量未设置", ClientConfig{TokenEnv: "TEST_MCP_TOKEN_MISSING"}, "", true},
+		{"文件", ClientConfig{TokenFile: path}, "from-file", false},
+		{"凭证助手", ClientConfig{TokenCommand: "echo ' from-helper '"}, "from-helper", false},
+		{"凭证助手失败", ClientConfig{TokenCommand: "echo denied >&2; exit 1"}, "", true},
+		{"凭证助手无输出", ClientConfig{TokenCommand: "true"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.cfg.ResolveToken(context.Background())
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: ResolveToken() = %q, %v, 预期 %q, 错误 %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestSetCurrentContext 验证只修改 current_context，不写入文件中未出现的字段
+func TestSetCurrentContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client_config.json")
+	if err := os.WriteFile(path, []byte(`{"contexts":[{"name":"prod","servers":[]}],"log":{"level":"debug"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetCurrentContext(path, "prod"); err != nil {
+		t.Fatalf("SetCurrentContext 失败: %v", err)
+	}
+
+	cfg := DefaultClientConfig()
+	if err := ReadClientConfig(path, cfg); err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	if cfg.CurrentContext != "prod" || cfg.Log.Level != "debug" || cfg.Log.MaxSize != 100 {
+		t.Errorf("配置 = %+v", cfg)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "max_size") {
+		t.Errorf("文件中不应出现 max_size: %s", data)
+	}
+}
@@ -0,0 +1,61 @@
+package configs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// tokenCommandTimeout 凭证助手命令的最长执行时间
+const tokenCommandTimeout = 30 * time.Second
+
+// ResolveToken 返回连接 Token
+// 按 token、token_env、token_file、token_command 的顺序，使用第一个配置了的来源；都未配置时返回空字符串
+func (c *ClientConfig) ResolveToken(ctx context.Context) (string, error) {
+	switch {
+	case c.Token != "":
+		return c.Token, nil
+	case c.TokenEnv != "":
+		token, ok := os.LookupEnv(c.TokenEnv)
+		if !ok || token == "" {
+			return "", fmt.Errorf("环境变量 %s 未设置", c.TokenEnv)
+		}
+		return token, nil
+	case c.TokenFile != "":
+		data, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("读取 Token 文件失败: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case c.TokenCommand != "":
+		return runTokenCommand(ctx, c.TokenCommand)
+	}
+	return "", nil
+}
+
+// runTokenCommand 通过 sh -c 执行凭证助手命令，返回去掉首尾空白的标准输出
+func runTokenCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tokenCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("凭证助手命令执行失败: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("凭证助手命令执行失败: %w", err)
+	}
+
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("凭证助手命令没有输出 Token")
+	}
+	return token, nil
+}
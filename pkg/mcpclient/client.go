@@ -2,6 +2,7 @@ package mcpclient
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -25,9 +26,21 @@ type Client struct {
 	timeout    time.Duration
 	headers    map[string]string
 	serverURL  string
+	// 故障转移相关字段
+	servers       []*serverEntry    // 按优先级排列的服务器列表
+	active        *serverEntry      // 当前连接的服务器
+	cooldown      time.Duration     // 服务器连接失败后的冷却时间
+	onStateChange func(StateChange) // 连接状态变化回调
+	retryPolicy   RetryPolicy       // 命令执行失败时的重试策略
+	// 服务端通知相关字段
+	onProgress func(context.Context, *mcp.ProgressNotificationParams) // 进度通知回调
+	onLog      func(context.Context, *mcp.LoggingMessageParams)       // 日志通知回调
+	logLevel   mcp.LoggingLevel                                       // SetLoggingLevel 设置的日志级别，重连后重新设置
 	// 心跳机制相关字段
-	cancelHeartbeat context.CancelFunc // 用于停止心跳协程
-	heartbeatCtx    context.Context    // 心跳协程的上下文
+	heartbeatInterval  time.Duration      // 心跳间隔，0 表示不发送心跳
+	heartbeatThreshold int                // 连续失败多少次心跳后在后台重连
+	cancelHeartbeat    context.CancelFunc // 用于停止心跳协程
+	heartbeatCtx       context.Context    // 心跳协程的上下文
 	// 重连机制相关字段
 	mu           sync.Mutex    // 保护 session 状态
 	isConnecting bool          // 标记是否正在进行连接/重连，防止重连风暴
@@ -78,11 +91,16 @@ func NewClient(cfg *configs.ClientConfig, opts ...Option) (*Client, error) {
 
 	// 创建客户端实例
 	client := &Client{
-		config:     cfg,
-		logger:     GetDefaultLogger(),
-		timeout:    30 * time.Second,
-		httpClient: &http.Client{},
-		headers:    make(map[string]string),
+		config:      cfg,
+		logger:      GetDefaultLogger(),
+		timeout:     30 * time.Second,
+		httpClient:  &http.Client{},
+		headers:     make(map[string]string),
+		cooldown:    DefaultServerCooldown,
+		retryPolicy: DefaultRetryPolicy(),
+
+		heartbeatInterval:  DefaultHeartbeatInterval,
+		heartbeatThreshold: DefaultHeartbeatThreshold,
 	}
 
 	// 应用可选参数
@@ -95,17 +113,22 @@ func NewClient(cfg *configs.ClientConfig, opts ...Option) (*Client, error) {
 		WithInsecureSkipVerify()(client)
 	}
 
-	// 如果没有设置超时，使用默认值
-	if client.httpClient.Timeout == 0 {
-		client.httpClient.Timeout = client.timeout
-	}
+	// 构建故障转移服务器列表
+	client.servers = buildServerList(cfg, client.serverURL)
 
 	return client, nil
 }
 
 // Connect 连接到服务器
-// 如果没有指定 serverURL，则尝试连接配置中的第一个可用服务器
+// 按配置顺序依次尝试服务器列表，连接成功即停止；连接失败的服务器进入冷却期，
+// 冷却期内的服务器排在最后尝试。使用 WithServerURL 时只尝试该地址
 func (c *Client) Connect(ctx context.Context) error {
+	return c.connect(ctx, nil)
+}
+
+// connect 依次尝试候选服务器，only 不为 nil 时只尝试该服务器
+// 只尝试单个服务器（SwitchServer）失败时保留原有连接，不发出 disconnected 事件
+func (c *Client) connect(ctx context.Context, only *serverEntry) error {
 	// 尝试获取连接令牌
 	c.mu.Lock()
 	if c.isConnecting {
@@ -113,6 +136,10 @@ func (c *Client) Connect(ctx context.Context) error {
 		return errors.New("正在尝试连接，请稍后重试")
 	}
 	c.isConnecting = true
+	candidates := []*serverEntry{only}
+	if only == nil {
+		candidates = c.candidatesLocked(time.Now())
+	}
 	c.mu.Unlock()
 
 	// 使用 defer 确保状态被重置
@@ -122,29 +149,74 @@ func (c *Client) Connect(ctx context.Context) error {
 		c.mu.Unlock()
 	}()
 
-	// 确定要连接的服务器 URL
-	c.mu.Lock()
-	serverURL := c.serverURL
-	if serverURL == "" {
-		if len(c.config.Servers) == 0 {
+	if len(candidates) == 0 {
+		return errors.New("没有可用的服务器")
+	}
+
+	var errs []error
+	for _, server := range candidates {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		c.notifyState(StateChange{State: StateConnecting, Server: server.config})
+		newClient, session, err := c.connectTo(ctx, server.config.URL)
+		if err != nil {
+			c.logger.Warnf("连接服务器 %s (%s) 失败: %v", server.config.Name, server.config.URL, err)
+			c.mu.Lock()
+			c.markFailedLocked(server, err)
 			c.mu.Unlock()
-			return errors.New("没有可用的服务器")
+			errs = append(errs, fmt.Errorf("%s: %w", server.config.Name, err))
+			continue
+		}
+
+		// 获取锁，更新 session 和 client，并启动心跳机制
+		c.mu.Lock()
+		oldSession := c.session
+		c.client = newClient
+		c.session = session
+		c.markHealthyLocked(server)
+		c.startHeartbeatLocked()
+		c.mu.Unlock()
+
+		// 关闭旧的 session（重连场景）
+		if oldSession != nil {
+			_ = oldSession.Close()
 		}
-		serverURL = c.config.Servers[0].URL
+
+		c.logger.Infof("成功连接到服务器: %s (%s)", server.config.Name, server.config.URL)
+		c.restoreLoggingLevel(session)
+		c.notifyState(StateChange{State: StateConnected, Server: server.config})
+		return nil
 	}
-	c.mu.Unlock()
 
+	err := fmt.Errorf("连接服务器失败: %w", errors.Join(errs...))
+	if only == nil {
+		c.notifyState(StateChange{State: StateDisconnected, Err: err})
+	}
+	return err
+}
+
+// connectTo 与指定的服务器建立 MCP 会话
+func (c *Client) connectTo(ctx context.Context, serverURL string) (*mcp.Client, *mcp.ClientSession, error) {
 	if err := validateEndpointURL(serverURL); err != nil {
-		return fmt.Errorf("服务器地址无效: %w", err)
+		return nil, nil, fmt.Errorf("服务器地址无效: %w", err)
 	}
 
 	c.logger.Debugf("连接到服务器: %s", serverURL)
 
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
 	// 创建 MCP Client
 	newClient := mcp.NewClient(&mcp.Implementation{
 		Name:    "shell-executor-client",
 		Version: "1.0.0",
-	}, nil)
+	}, c.clientOptions())
 
 	// 创建 StreamableClientTransport 用于 Streamable HTTP 连接
 	transport := &mcp.StreamableClientTransport{
@@ -154,23 +226,9 @@ func (c *Client) Connect(ctx context.Context) error {
 
 	session, err := newClient.Connect(ctx, transport, nil)
 	if err != nil {
-		return fmt.Errorf("连接服务器失败: %w", err)
+		return nil, nil, err
 	}
-
-	// 获取锁，更新 session 和 client
-	c.mu.Lock()
-	c.client = newClient
-	c.session = session
-	c.mu.Unlock()
-
-	c.logger.Infof("成功连接到服务器: %s", serverURL)
-
-	// 启动心跳机制（在锁保护内调用）
-	c.mu.Lock()
-	c.startHeartbeatLocked()
-	c.mu.Unlock()
-
-	return nil
+	return newClient, session, nil
 }
 
 // Close 关闭客户端连接
@@ -185,11 +243,17 @@ func (c *Client) Close() error {
 
 	// 关闭 session
 	session := c.session
+	var server configs.ServerConfig
+	if c.active != nil {
+		server = c.active.config
+	}
 	c.session = nil
 	c.client = nil
+	c.active = nil
 	c.mu.Unlock()
 
 	if session != nil {
+		c.notifyState(StateChange{State: StateDisconnected, Server: server})
 		return session.Close()
 	}
 	return nil
@@ -197,112 +261,221 @@ func (c *Client) Close() error {
 
 // ExecuteCommand 执行命令
 // command: 要执行的命令
+// opts: 单次执行参数，如 WithExecTimeout、WithTargets、WithStrategy
 // 返回执行结果
-func (c *Client) ExecuteCommand(ctx context.Context, command string) (*Result, error) {
-	c.mu.Lock()
-	session := c.session
-	c.mu.Unlock()
+// 连接错误会按重试策略（见 WithRetryPolicy）重连或切换服务器后重试；
+// 请求可能已送达服务端时，只有 SafeToRetry（或 WithSafeToRetry）认为可安全重试的命令才会重试
+func (c *Client) ExecuteCommand(ctx context.Context, command string, opts ...ExecOption) (*Result, error) {
+	c.logger.Debugf("执行命令: %s", command)
 
-	if session == nil {
-		return nil, errors.New("客户端未连接，请先调用 Connect()")
+	o := newExecOptions(opts)
+	safe := c.retryPolicy.safeToRetry(command)
+	if o.safe != nil {
+		safe = *o.safe
 	}
 
-	c.logger.Debugf("执行命令: %s", command)
+	result, err := c.callTool(ctx, "execute_command", o.arguments(command), safe)
+	if err != nil {
+		return nil, err
+	}
+	return ParseResult(result)
+}
+
+// ToolError 表示 MCP Tool 返回的错误结果（CallToolResult.IsError 为 true）
+type ToolError struct {
+	Tool    string // Tool 名称
+	Message string // Tool 返回的错误信息
+}
 
-	// 最大重试次数
-	const maxRetries = 3
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("tool %s 返回错误: %s", e.Tool, e.Message)
+}
 
-	for i := 0; i < maxRetries; i++ {
-		// 调用 MCP Tool: execute_command
-		result, err := c.executeTool(ctx, session, command)
+// CallTool 调用任意 MCP Tool，并将结构化输出（StructuredContent）解码到 out
+// args: Tool 参数，可以是 map 或可 JSON 序列化的结构体
+// out: 结构化输出的解码目标（指针），为 nil 时不解码
+// Tool 返回错误结果时返回 *ToolError 和原始结果。连接错误按重试策略重试，
+// 但请求可能已送达服务端时不会重试
+func (c *Client) CallTool(ctx context.Context, name string, args any, out any) (*Result, error) {
+	c.logger.Debugf("调用 MCP Tool: %s", name)
 
-		// 如果成功，直接返回结果
-		if err == nil {
-			return result, nil
-		}
+	raw, err := c.callTool(ctx, name, args, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := parseContent(raw)
+	if raw.IsError {
+		return result, &ToolError{Tool: name, Message: strings.Join(result.GetTextContents(), "\n")}
+	}
+	if out == nil {
+		return result, nil
+	}
+	if raw.StructuredContent == nil {
+		return result, fmt.Errorf("tool %s 没有返回结构化输出", name)
+	}
+
+	data, err := json.Marshal(raw.StructuredContent)
+	if err != nil {
+		return result, fmt.Errorf("序列化 tool %s 的结构化输出失败: %w", name, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return result, fmt.Errorf("解码 tool %s 的结构化输出失败: %w", name, err)
+	}
+	return result, nil
+}
+
+// ListTools 获取服务器提供的 Tool 列表（一页），params 为 nil 时获取第一页
+// 查询是幂等的，连接错误时按重试策略重试
+func (c *Client) ListTools(ctx context.Context, params *mcp.ListToolsParams) (*mcp.ListToolsResult, error) {
+	var result *mcp.ListToolsResult
+	err := c.withSession(ctx, "获取 Tool 列表", true, callTimeoutMargin, func(ctx context.Context, session *mcp.ClientSession) error {
+		var err error
+		result, err = session.ListTools(ctx, params)
+		return err
+	})
+	return result, err
+}
+
+// CallToolRaw 使用完整的请求参数调用 MCP Tool，返回未解析的结果，用于转发其他 MCP 客户端的请求
+// params.Meta 中的 progressToken 原样发送，服务端的进度通知可以通过 WithProgressHandler 转发回去。
+// 与 CallTool 相同，请求可能已送达服务端时不会重试
+func (c *Client) CallToolRaw(ctx context.Context, params *mcp.CallToolParams) (*mcp.CallToolResult, error) {
+	c.logger.Debugf("转发 MCP Tool 调用: %s", params.Name)
+	return c.callToolParams(ctx, params, false)
+}
+
+// callTool 调用 MCP Tool，连接错误时按重试策略重连或切换服务器后重试
+// safe 表示请求可能已送达服务端时是否可以安全重试
+func (c *Client) callTool(ctx context.Context, name string, args any, safe bool) (*mcp.CallToolResult, error) {
+	return c.callToolParams(ctx, &mcp.CallToolParams{Name: name, Arguments: args}, safe)
+}
 
-		// 检查错误是否为连接相关错误
-		if !c.isConnectionError(err) {
-			// 非连接错误，直接返回
-			return nil, fmt.Errorf("调用 MCP Tool 失败: %w", err)
+// callToolParams 使用完整的请求参数（包括 _meta）调用 MCP Tool
+// 每次尝试等待结果的时间由参数中的执行超时决定，见 callTimeout
+func (c *Client) callToolParams(ctx context.Context, params *mcp.CallToolParams, safe bool) (*mcp.CallToolResult, error) {
+	var result *mcp.CallToolResult
+	err := c.withSession(ctx, "调用 MCP Tool", safe, callTimeout(params.Arguments), func(ctx context.Context, session *mcp.ClientSession) error {
+		var err error
+		result, err = session.CallTool(ctx, params)
+		return err
+	})
+	return result, err
+}
+
+// withSession 使用当前 session 执行 call，连接错误时按重试策略重连或切换服务器后重试
+// op 用于错误信息，safe 表示请求可能已送达服务端时是否可以安全重试，
+// timeout 为每次尝试等待结果的时间上限，0 表示只受 ctx 限制
+func (c *Client) withSession(ctx context.Context, op string, safe bool, timeout time.Duration, call func(context.Context, *mcp.ClientSession) error) error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+
+	if session == nil {
+		return fmt.Errorf("%w，请先调用 Connect()", ErrNotConnected)
+	}
+
+	policy := c.retryPolicy
+	maxAttempts := max(policy.MaxAttempts, 1)
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			c.logger.Warnf("调用失败，准备重试 (第 %d/%d 次): %v", attempt, maxAttempts, lastErr)
+			if err := policy.wait(ctx, attempt-1); err != nil {
+				return fmt.Errorf("%s失败: %w", op, lastErr)
+			}
+
+			// 重连或切换到下一个服务器，重连失败时请求未发出，可以继续重试
+			var err error
+			if session, err = c.sessionForRetry(ctx, session); err != nil {
+				c.logger.Errorf("重连失败: %v", err)
+				lastErr = fmt.Errorf("%w: %w", ErrNotConnected, err)
+				if ctx.Err() != nil {
+					return fmt.Errorf("%s失败: %w", op, lastErr)
+				}
+				continue
+			}
 		}
 
-		// 是连接错误，尝试重连
-		c.logger.Warnf("检测到连接错误，尝试重连 (第 %d/%d 次)", i+1, maxRetries)
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		callCtx, tracker := withDeliveryTracker(attemptCtx)
+		err := call(callCtx, session)
+		timedOut := attemptCtx.Err() != nil
+		cancel()
+		if err == nil {
+			return nil
+		}
+		err = tracker.wrap(err)
+		lastErr = err
 
-		// 重新建立连接
-		reconnectErr := c.Connect(ctx)
-		if reconnectErr != nil {
-			c.logger.Errorf("重连失败: %v", reconnectErr)
-			// 重连失败，等待后继续重试
-			time.Sleep(time.Second * time.Duration(i+1))
-			continue
+		// 调用方取消时不再重试
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s失败: %w", op, err)
+		}
+		// 等待结果超时说明服务端仍在处理，不是连接问题，不切换服务器也不重试
+		if timedOut {
+			return fmt.Errorf("%s超时（%s）: %w", op, timeout, err)
 		}
 
-		// 重连成功，获取新的 session
-		c.mu.Lock()
-		session = c.session
-		c.mu.Unlock()
+		// 连接错误：将当前服务器标记为失败，下次重试时切换服务器
+		retry := policy.retryable(err, safe) && attempt < maxAttempts
+		if IsConnectionError(err) {
+			if failed := c.markSessionFailed(session, err); failed != nil {
+				state := StateDisconnected
+				if retry {
+					state = StateReconnecting
+				}
+				c.notifyState(StateChange{State: state, Server: failed.config, Err: err})
+			}
+		}
 
-		if session == nil {
-			return nil, errors.New("重连后 session 为空")
+		if !policy.retryable(err, safe) {
+			return fmt.Errorf("%s失败: %w", op, err)
 		}
 	}
 
-	// 达到最大重试次数
-	return nil, fmt.Errorf("执行命令失败，已达到最大重试次数 %d 次", maxRetries)
+	// 达到最大尝试次数
+	return fmt.Errorf("%s失败，已达到最大尝试次数 %d 次: %w", op, maxAttempts, lastErr)
 }
 
-// executeTool 执行 MCP Tool 调用
-func (c *Client) executeTool(ctx context.Context, session *mcp.ClientSession, command string) (*Result, error) {
-	result, err := session.CallTool(ctx, &mcp.CallToolParams{
-		Name: "execute_command",
-		Arguments: map[string]any{
-			"command": command,
-		},
-	})
-
-	if err != nil {
-		return nil, err
+// markSessionFailed 将 session 对应的服务器标记为失败，返回被标记的服务器
+// 如果其他协程已经完成重连（session 已被替换），则不做处理并返回 nil
+func (c *Client) markSessionFailed(session *mcp.ClientSession, err error) *serverEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	failed := c.active
+	if c.session != session || failed == nil {
+		return nil
 	}
-
-	// 解析结果
-	return ParseResult(result), nil
+	c.markFailedLocked(failed, err)
+	c.logger.Warnf("检测到连接错误，服务器 %s 进入冷却期: %v", failed.config.Name, err)
+	return failed
 }
 
-// isConnectionError 检查错误是否为连接相关错误
-func (c *Client) isConnectionError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	errStr := err.Error()
-
-	// 检查常见连接错误
-	connectionErrors := []string{
-		"EOF",
-		"broken pipe",
-		"connection reset",
-		"connection refused",
-		"connection closed",
-		"stream closed",
-		"i/o timeout",
-		"timeout",
-		"context deadline exceeded",
-		"context canceled",
-		"session is closed",
-		"no session",
-		"not connected",
-	}
-
-	errLower := strings.ToLower(errStr)
-	for _, pattern := range connectionErrors {
-		if strings.Contains(errLower, strings.ToLower(pattern)) {
-			return true
-		}
+// sessionForRetry 返回重试时使用的 session
+// 其他协程已经完成重连时直接使用新的 session，否则重新建立连接
+func (c *Client) sessionForRetry(ctx context.Context, stale *mcp.ClientSession) (*mcp.ClientSession, error) {
+	c.mu.Lock()
+	current := c.session
+	c.mu.Unlock()
+	if current != nil && current != stale {
+		return current, nil
 	}
 
-	return false
+	if err := c.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		return nil, errors.New("重连后 session 为空")
+	}
+	return c.session, nil
 }
 
 // GetSession 获取底层的 MCP ClientSession
@@ -332,6 +505,10 @@ func (c *Client) startHeartbeatLocked() {
 	}
 	c.heartbeatCtx = nil
 
+	if c.heartbeatInterval <= 0 {
+		return
+	}
+
 	// 创建可取消的上下文
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancelHeartbeat = cancel
@@ -349,12 +526,15 @@ func (c *Client) startHeartbeat() {
 }
 
 // runHeartbeat 运行心跳循环
-// ctx 参数用于控制协程生命周期
+// ctx 参数用于控制协程生命周期；连续 heartbeatThreshold 次心跳失败后在后台重连，
+// 重连成功后由 Connect 启动新的心跳协程，当前协程退出
 func (c *Client) runHeartbeat(ctx context.Context) {
 	// 使用 time.Ticker 定期发送心跳请求
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(c.heartbeatInterval)
 	defer ticker.Stop()
 
+	threshold := max(c.heartbeatThreshold, 1)
+	failures := 0
 	for {
 		select {
 		case <-ctx.Done():
@@ -363,29 +543,90 @@ func (c *Client) runHeartbeat(ctx context.Context) {
 			return
 		case <-ticker.C:
 			// 发送心跳请求
-			c.sendHeartbeat()
+			session, err := c.sendHeartbeat(ctx)
+			if err == nil {
+				failures = 0
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			failures++
+			c.logger.Warnf("心跳请求失败 (%d/%d): %v", failures, threshold, err)
+			if failures >= threshold {
+				c.reconnectInBackground(ctx, session, err)
+				return
+			}
 		}
 	}
 }
 
-// sendHeartbeat 发送心跳请求
-func (c *Client) sendHeartbeat() {
+// sendHeartbeat 发送心跳请求，返回发送心跳使用的 session
+func (c *Client) sendHeartbeat(ctx context.Context) (*mcp.ClientSession, error) {
 	c.mu.Lock()
 	session := c.session
 	c.mu.Unlock()
 
 	if session == nil {
-		return
+		return nil, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, min(c.heartbeatInterval, 10*time.Second))
 	defer cancel()
 
-	_, err := session.ListTools(ctx, nil)
-	if err != nil {
-		c.logger.Warnf("心跳请求失败: %v", err)
-	} else {
-		c.logger.Debugf("心跳请求成功")
+	if _, err := session.ListTools(ctx, nil); err != nil {
+		return session, err
+	}
+	c.logger.Debugf("心跳请求成功")
+	return session, nil
+}
+
+// reconnectInBackground 在心跳连续失败后重连（或切换到下一个服务器）
+// 重连失败时按重试策略的退避时间（不超过心跳间隔）继续重连，直到成功、
+// 其他协程完成重连或 ctx 被取消（Close）
+func (c *Client) reconnectInBackground(ctx context.Context, session *mcp.ClientSession, cause error) {
+	c.mu.Lock()
+	if c.session != session {
+		// 其他协程已经完成重连
+		c.mu.Unlock()
+		return
+	}
+	var server configs.ServerConfig
+	if c.active != nil {
+		server = c.active.config
+		c.markFailedLocked(c.active, cause)
+	}
+	c.mu.Unlock()
+
+	for retry := 1; ; retry++ {
+		c.logger.Warnf("心跳连续失败，开始后台重连 (第 %d 次): %v", retry, cause)
+		c.notifyState(StateChange{State: StateReconnecting, Server: server, Err: cause})
+
+		err := c.Connect(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		cause = err
+
+		wait := min(c.retryPolicy.Backoff(retry), c.heartbeatInterval)
+		if wait <= 0 {
+			wait = c.heartbeatInterval
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		c.mu.Lock()
+		reconnected := c.session != nil && c.session != session
+		c.mu.Unlock()
+		if reconnected {
+			return
+		}
 	}
 }
 
@@ -395,10 +636,9 @@ func (c *Client) transportHTTPClient() *http.Client {
 		baseClient = &http.Client{}
 	}
 
+	// 不设置 Timeout：它同时限制读取响应的时间，会中断执行时间较长的 Tool 调用和 SSE 流，
+	// 连接和调用的超时由 context 控制（见 connectTo 和 callTimeout）
 	clonedClient := *baseClient
-	if clonedClient.Timeout == 0 {
-		clonedClient.Timeout = c.timeout
-	}
 
 	// 构建 Transport 链：headerRoundTripper(可选) -> baseTransport
 	baseTransport := clonedClient.Transport
@@ -418,7 +658,8 @@ func (c *Client) transportHTTPClient() *http.Client {
 		}
 	}
 
-	clonedClient.Transport = baseTransport
+	// 记录请求是否已送达服务端，用于判断命令能否安全重试
+	clonedClient.Transport = &trackingRoundTripper{base: baseTransport}
 
 	return &clonedClient
 }
@@ -2,69 +2,208 @@ package mcpclient
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/AceDarkknight/shell-executor-mcp/pkg/configs"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-// TestIsConnectionError 测试错误识别逻辑
+// TestIsConnectionError 测试基于错误类型的错误分类逻辑
 func TestIsConnectionError(t *testing.T) {
-	client := &Client{}
-
 	tests := []struct {
 		name     string
 		err      error
+		class    ErrorClass
 		expected bool
 	}{
 		{
 			name:     "nil 错误",
 			err:      nil,
+			class:    ErrorClassPermanent,
 			expected: false,
 		},
 		{
 			name:     "EOF 错误",
-			err:      errors.New("EOF"),
+			err:      fmt.Errorf("read response: %w", io.EOF),
+			class:    ErrorClassConnection,
 			expected: true,
 		},
 		{
 			name:     "broken pipe 错误",
-			err:      errors.New("broken pipe"),
+			err:      &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE},
+			class:    ErrorClassConnection,
 			expected: true,
 		},
 		{
-			name:     "connection reset 错误",
-			err:      errors.New("connection reset by peer"),
+			name:     "connection refused 错误",
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+			class:    ErrorClassNotSent,
 			expected: true,
 		},
 		{
-			name:     "context canceled 错误",
-			err:      errors.New("context canceled"),
+			name:     "SDK 连接已关闭",
+			err:      fmt.Errorf("%w: calling \"tools/call\": client is closing", mcp.ErrConnectionClosed),
+			class:    ErrorClassConnection,
+			expected: true,
+		},
+		{
+			name:     "传输层拒绝",
+			err:      &jsonrpc.Error{Code: codeRejectedByTransport, Message: "rejected by transport"},
+			class:    ErrorClassNotSent,
 			expected: true,
 		},
 		{
-			name:     "普通错误",
-			err:      errors.New("some other error"),
+			name:     "传输层拒绝但请求已送达",
+			err:      &callError{err: &jsonrpc.Error{Code: codeRejectedByTransport}, delivered: true},
+			class:    ErrorClassConnection,
+			expected: true,
+		},
+		{
+			name:     "服务端返回的 JSON-RPC 错误",
+			err:      &jsonrpc.Error{Code: -32602, Message: "invalid params"},
+			class:    ErrorClassPermanent,
+			expected: false,
+		},
+		{
+			name:     "context canceled 错误",
+			err:      fmt.Errorf("calling tool: %w", context.Canceled),
+			class:    ErrorClassPermanent,
 			expected: false,
 		},
 		{
-			name:     "session is closed 错误",
-			err:      errors.New("session is closed"),
+			name:     "仅包含连接错误文本的普通错误",
+			err:      errors.New("connection reset by peer"),
+			class:    ErrorClassPermanent,
+			expected: false,
+		},
+		{
+			name:     "未连接",
+			err:      fmt.Errorf("%w，请先调用 Connect()", ErrNotConnected),
+			class:    ErrorClassNotSent,
 			expected: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := client.isConnectionError(tt.err)
-			if result != tt.expected {
-				t.Errorf("isConnectionError(%v) = %v, 预期 %v", tt.err, result, tt.expected)
+			if class := ClassifyError(tt.err); class != tt.class {
+				t.Errorf("ClassifyError(%v) = %s, 预期 %s", tt.err, class, tt.class)
+			}
+			if result := IsConnectionError(tt.err); result != tt.expected {
+				t.Errorf("IsConnectionError(%v) = %v, 预期 %v", tt.err, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRetryPolicyBackoff 验证指数退避、上限和随机浮动范围
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := policy.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %v, 预期 %v", i+1, got, w)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("Backoff(1) = %v 超出浮动范围", got)
+		}
+	}
+
+	// ctx 取消时等待立即返回
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := (RetryPolicy{InitialBackoff: time.Hour}).wait(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() = %v, 预期 context.Canceled", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("ctx 取消后 wait 没有立即返回")
+	}
+}
+
+// TestExecuteCommandRetry 验证命令只在请求未送达或被标记为可安全重试时才会重试
+func TestExecuteCommandRetry(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	live := newTestMCPServer(t, "live")
+	// 第一次 tools/call 请求送达后直接断开连接，模拟命令执行过程中连接中断
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		if strings.Contains(string(body), `"tools/call"`) {
+			mu.Lock()
+			calls[r.Header.Get("X-Test-Case")]++
+			first := calls[r.Header.Get("X-Test-Case")] == 1
+			mu.Unlock()
+			if first {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+		}
+		live.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(flaky.Close)
+
+	cfg := &configs.ClientConfig{Servers: []configs.ServerConfig{{Name: "flaky", URL: flaky.URL + "/mcp"}}}
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		SafeToRetry:    func(command string) bool { return command == "uptime" },
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr bool
+	}{
+		{name: "不可安全重试的命令", command: "rm -f /tmp/x", wantErr: true},
+		{name: "可安全重试的命令", command: "uptime", wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(cfg, WithLogger(&mockLogger{}), WithRetryPolicy(policy), WithHeader("X-Test-Case", tt.name))
+			if err != nil {
+				t.Fatalf("创建客户端失败: %v", err)
+			}
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatalf("Connect 失败: %v", err)
+			}
+			defer client.Close()
+
+			_, err = client.ExecuteCommand(context.Background(), tt.command)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExecuteCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && ClassifyError(err) != ErrorClassConnection {
+				t.Errorf("错误分类 = %s, 预期 %s", ClassifyError(err), ErrorClassConnection)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			want := 1
+			if !tt.wantErr {
+				want = 2
+			}
+			if calls[tt.name] != want {
+				t.Errorf("tools/call 请求次数 = %d, 预期 %d", calls[tt.name], want)
 			}
 		})
 	}
@@ -313,3 +452,448 @@ func TestClientConfigValidation(t *testing.T) {
 		})
 	}
 }
+
+// echoOutput echo_args 测试 tool 的结构化输出
+type echoOutput struct {
+	Server string         `json:"server"`
+	Args   map[string]any `json:"args"`
+}
+
+// newTestMCPServer 启动一个提供 execute_command tool 的 MCP 测试服务器
+func newTestMCPServer(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	server := mcp.NewServer(&mcp.Implementation{Name: name, Version: "test"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "execute_command"}, func(ctx context.Context, req *mcp.CallToolRequest, in struct {
+		Command string `json:"command"`
+	}) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: name + ": " + in.Command}}}, nil, nil
+	})
+	mcp.AddTool(server, &mcp.Tool{Name: "echo_args"}, func(ctx context.Context, req *mcp.CallToolRequest, in map[string]any) (*mcp.CallToolResult, echoOutput, error) {
+		if in["fail"] == true {
+			return nil, echoOutput{}, errors.New("requested failure")
+		}
+		if ms, ok := in["sleep_ms"].(float64); ok {
+			time.Sleep(time.Duration(ms) * time.Millisecond)
+		}
+		return nil, echoOutput{Server: name, Args: in}, nil
+	})
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, &mcp.StreamableHTTPOptions{
+		Stateless:    true,
+		JSONResponse: true,
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", handler)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestFailover 验证连接时按顺序尝试服务器，失败的服务器进入冷却期，并通过回调通知状态变化
+func TestFailover(t *testing.T) {
+	live := newTestMCPServer(t, "live")
+	cfg := &configs.ClientConfig{
+		Servers: []configs.ServerConfig{
+			{Name: "dead", URL: "http://127.0.0.1:1/mcp"},
+			{Name: "live", URL: live.URL + "/mcp"},
+		},
+	}
+
+	var mu sync.Mutex
+	var changes []string
+	client, err := NewClient(cfg, WithLogger(&mockLogger{}), WithOnStateChange(func(sc StateChange) {
+		mu.Lock()
+		changes = append(changes, string(sc.State)+":"+sc.Server.Name)
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+
+	if _, ok := client.CurrentServer(); ok {
+		t.Fatal("未连接时 CurrentServer 应返回 false")
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect 失败: %v", err)
+	}
+	if server, ok := client.CurrentServer(); !ok || server.Name != "live" {
+		t.Fatalf("CurrentServer() = %+v, %v, 预期 live", server, ok)
+	}
+
+	result, err := client.ExecuteCommand(context.Background(), "hostname")
+	if err != nil {
+		t.Fatalf("ExecuteCommand 失败: %v", err)
+	}
+	if texts := result.GetTextContents(); len(texts) != 1 || texts[0] != "live: hostname" {
+		t.Errorf("执行结果错误: %v", texts)
+	}
+
+	// dead 处于冷却期，重新连接时应直接连接 live
+	client.Close()
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("重新 Connect 失败: %v", err)
+	}
+	client.Close()
+
+	want := []string{
+		"connecting:dead", "connecting:live", "connected:live", "disconnected:live",
+		"connecting:live", "connected:live", "disconnected:live",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(changes, ",") != strings.Join(want, ",") {
+		t.Errorf("状态变化 = %v, 预期 %v", changes, want)
+	}
+}
+
+// TestSwitchServer 验证切换到指定服务器，切换失败时保留原有连接
+func TestSwitchServer(t *testing.T) {
+	first := newTestMCPServer(t, "first")
+	second := newTestMCPServer(t, "second")
+	cfg := &configs.ClientConfig{
+		Servers: []configs.ServerConfig{
+			{Name: "first", URL: first.URL + "/mcp"},
+			{Name: "second", URL: second.URL + "/mcp"},
+			{Name: "dead", URL: "http://127.0.0.1:1/mcp"},
+		},
+	}
+	client, err := NewClient(cfg, WithLogger(&mockLogger{}))
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	defer client.Close()
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect 失败: %v", err)
+	}
+
+	if err := client.SwitchServer(context.Background(), "second"); err != nil {
+		t.Fatalf("SwitchServer 失败: %v", err)
+	}
+	if err := client.SwitchServer(context.Background(), "dead"); err == nil {
+		t.Error("切换到不可用的服务器应返回错误")
+	}
+	if err := client.SwitchServer(context.Background(), "unknown"); err == nil {
+		t.Error("切换到未配置的服务器应返回错误")
+	}
+
+	result, err := client.ExecuteCommand(context.Background(), "hostname")
+	if err != nil {
+		t.Fatalf("切换失败后 ExecuteCommand 失败: %v", err)
+	}
+	if texts := result.GetTextContents(); len(texts) != 1 || texts[0] != "second: hostname" {
+		t.Errorf("执行结果 = %v, 预期仍使用 second", texts)
+	}
+	if server, ok := client.CurrentServer(); !ok || server.Name != "second" {
+		t.Errorf("CurrentServer() = %+v, %v, 预期 second", server, ok)
+	}
+}
+
+// TestHeartbeatReconnect 验证心跳连续失败后客户端在后台切换到下一个服务器
+func TestHeartbeatReconnect(t *testing.T) {
+	first := newTestMCPServer(t, "first")
+	second := newTestMCPServer(t, "second")
+	cfg := &configs.ClientConfig{
+		Servers: []configs.ServerConfig{
+			{Name: "first", URL: first.URL + "/mcp"},
+			{Name: "second", URL: second.URL + "/mcp"},
+		},
+	}
+
+	connected := make(chan string, 4)
+	var mu sync.Mutex
+	var changes []string
+	client, err := NewClient(cfg,
+		WithLogger(&mockLogger{}),
+		WithHeartbeat(20*time.Millisecond, 2),
+		WithOnStateChange(func(sc StateChange) {
+			mu.Lock()
+			changes = append(changes, string(sc.State)+":"+sc.Server.Name)
+			mu.Unlock()
+			if sc.State == StateConnected {
+				connected <- sc.Server.Name
+			}
+		}))
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect 失败: %v", err)
+	}
+	if name := <-connected; name != "first" {
+		t.Fatalf("首次连接到 %s, 预期 first", name)
+	}
+
+	first.Close()
+	select {
+	case name := <-connected:
+		if name != "second" {
+			t.Fatalf("后台重连到 %s, 预期 second", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("心跳失败后未在后台重连")
+	}
+
+	if server, ok := client.CurrentServer(); !ok || server.Name != "second" {
+		t.Errorf("CurrentServer() = %+v, %v, 预期 second", server, ok)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := "connecting:first,connected:first,reconnecting:first,connecting:second,connected:second"
+	if got := strings.Join(changes, ","); got != want {
+		t.Errorf("状态变化 = %s, 预期 %s", got, want)
+	}
+}
+
+// TestExecOptionsArguments 验证执行参数序列化为 tool 参数，零值参数不发送
+func TestExecOptionsArguments(t *testing.T) {
+	args := newExecOptions(nil).arguments("ls")
+	if len(args) != 1 || args["command"] != "ls" {
+		t.Errorf("无参数时 arguments = %v", args)
+	}
+
+	args = newExecOptions([]ExecOption{
+		WithExecTimeout(1500 * time.Millisecond),
+		WithTargets("web-*"),
+		WithTargets("db-01"),
+		WithWorkDir("/srv/app"),
+		WithEnv(map[string]string{"A": "1"}),
+		WithEnv(map[string]string{"B": "2"}),
+		WithStdin(""),
+		WithStrategy(StrategySerial),
+		WithNormalize(Normalize{StripANSI: true}),
+	}).arguments("cat")
+
+	if args["timeout"] != 2 {
+		t.Errorf("timeout = %v, 预期向上取整为 2", args["timeout"])
+	}
+	if targets, _ := args["targets"].([]string); strings.Join(targets, ",") != "web-*,db-01" {
+		t.Errorf("targets = %v", args["targets"])
+	}
+	if args["cwd"] != "/srv/app" || args["strategy"] != "serial" {
+		t.Errorf("cwd/strategy 参数错误: %v", args)
+	}
+	if env, _ := args["env"].(map[string]string); len(env) != 2 || env["B"] != "2" {
+		t.Errorf("env = %v, 预期合并多次设置", args["env"])
+	}
+	if stdin, ok := args["stdin"]; !ok || stdin != "" {
+		t.Errorf("显式设置的空 stdin 也应发送: %v", args)
+	}
+	if normalize, _ := args["normalize"].(map[string]bool); !normalize["strip_ansi"] || normalize["collapse_cr"] {
+		t.Errorf("normalize = %v", args["normalize"])
+	}
+
+	// 负数的软超时表示不使用服务端默认值
+	if args := newExecOptions([]ExecOption{WithSoftTimeout(-1)}).arguments("ls"); args["soft_timeout"] != -1 {
+		t.Errorf("soft_timeout = %v, 预期 -1", args["soft_timeout"])
+	}
+}
+
+// TestCallTool 验证 CallTool 将结构化输出解码到指定结构体，并将 tool 错误转换为 ToolError
+func TestCallTool(t *testing.T) {
+	live := newTestMCPServer(t, "live")
+	cfg := &configs.ClientConfig{Servers: []configs.ServerConfig{{Name: "live", URL: live.URL + "/mcp"}}}
+	client, err := NewClient(cfg, WithLogger(&mockLogger{}))
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect 失败: %v", err)
+	}
+	defer client.Close()
+
+	var out echoOutput
+	if _, err := client.CallTool(context.Background(), "echo_args", map[string]any{"n": 1}, &out); err != nil {
+		t.Fatalf("CallTool 失败: %v", err)
+	}
+	if out.Server != "live" || out.Args["n"] != float64(1) {
+		t.Errorf("结构化输出解码错误: %+v", out)
+	}
+
+	_, err = client.CallTool(context.Background(), "echo_args", map[string]any{"fail": true}, &out)
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) || toolErr.Tool != "echo_args" || !strings.Contains(toolErr.Message, "requested failure") {
+		t.Errorf("预期返回 ToolError, 实际: %v", err)
+	}
+}
+
+// TestCallToolOutlastsConnectTimeout 验证 Tool 调用等待结果的时间不受 WithTimeout 限制
+func TestCallToolOutlastsConnectTimeout(t *testing.T) {
+	live := newTestMCPServer(t, "live")
+	cfg := &configs.ClientConfig{Servers: []configs.ServerConfig{{Name: "live", URL: live.URL + "/mcp"}}}
+	client, err := NewClient(cfg, WithLogger(&mockLogger{}), WithTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect 失败: %v", err)
+	}
+	defer client.Close()
+
+	var out echoOutput
+	if _, err := client.CallTool(context.Background(), "echo_args", map[string]any{"sleep_ms": 300}, &out); err != nil {
+		t.Fatalf("执行时间超过连接超时的 CallTool 失败: %v", err)
+	}
+	if out.Server != "live" {
+		t.Errorf("结构化输出解码错误: %+v", out)
+	}
+}
+
+// TestCallTimeout 验证 Tool 调用等待结果的时间按参数中的执行超时计算
+func TestCallTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		args any
+		want time.Duration
+	}{
+		{"无参数", nil, serverDefaultTimeout + callTimeoutMargin},
+		{"未指定超时", map[string]any{"command": "ls"}, serverDefaultTimeout + callTimeoutMargin},
+		{"指定超时", newExecOptions([]ExecOption{WithExecTimeout(90 * time.Second)}).arguments("ls"), 90*time.Second + callTimeoutMargin},
+		{"转发的参数", json.RawMessage(`{"command":"ls","timeout":600}`), 600*time.Second + callTimeoutMargin},
+		{"串行策略", map[string]any{"timeout": 10, "strategy": "serial"}, 0},
+	}
+	for _, tt := range tests {
+		if got := callTimeout(tt.args); got != tt.want {
+			t.Errorf("%s: callTimeout() = %s, 预期 %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// newNotifyingMCPServer 启动一个有状态的 MCP 测试服务器，notify tool 在执行时发送进度和日志通知
+func newNotifyingMCPServer(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	server := mcp.NewServer(&mcp.Implementation{Name: name, Version: "test"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "notify"}, func(ctx context.Context, req *mcp.CallToolRequest, in map[string]any) (*mcp.CallToolResult, any, error) {
+		if token := req.Params.GetProgressToken(); token != nil {
+			_ = req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{ProgressToken: token, Progress: 1, Total: 1})
+		}
+		_ = req.Session.Log(ctx, &mcp.LoggingMessageParams{Level: "info", Data: name})
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: name}}}, nil, nil
+	})
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil)
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", handler)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestNotificationRelay 验证 ListTools、CallToolRaw 和通知回调，切换服务器后日志级别重新生效
+func TestNotificationRelay(t *testing.T) {
+	first := newNotifyingMCPServer(t, "first")
+	second := newNotifyingMCPServer(t, "second")
+	cfg := &configs.ClientConfig{
+		Servers: []configs.ServerConfig{
+			{Name: "first", URL: first.URL + "/mcp"},
+			{Name: "second", URL: second.URL + "/mcp"},
+		},
+	}
+
+	progress := make(chan any, 4)
+	logs := make(chan any, 4)
+	client, err := NewClient(cfg, WithLogger(&mockLogger{}), WithHeartbeat(0, 0),
+		WithProgressHandler(func(ctx context.Context, p *mcp.ProgressNotificationParams) { progress <- p.ProgressToken }),
+		WithLoggingHandler(func(ctx context.Context, p *mcp.LoggingMessageParams) { logs <- p.Data }),
+	)
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	defer client.Close()
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect 失败: %v", err)
+	}
+
+	tools, err := client.ListTools(context.Background(), nil)
+	if err != nil || len(tools.Tools) != 1 || tools.Tools[0].Name != "notify" {
+		t.Fatalf("ListTools() = %+v, %v", tools, err)
+	}
+	if err := client.SetLoggingLevel(context.Background(), "info"); err != nil {
+		t.Fatalf("SetLoggingLevel 失败: %v", err)
+	}
+
+	receive := func(ch chan any, want any) {
+		t.Helper()
+		select {
+		case got := <-ch:
+			if got != want {
+				t.Errorf("通知内容 = %v, 预期 %v", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("等待通知 %v 超时", want)
+		}
+	}
+
+	params := &mcp.CallToolParams{Name: "notify", Meta: mcp.Meta{"progressToken": "tok-1"}}
+	if _, err := client.CallToolRaw(context.Background(), params); err != nil {
+		t.Fatalf("CallToolRaw 失败: %v", err)
+	}
+	receive(progress, "tok-1")
+	receive(logs, "first")
+
+	// 切换服务器后日志级别在新会话上重新设置，日志通知继续送达
+	if err := client.SwitchServer(context.Background(), "second"); err != nil {
+		t.Fatalf("SwitchServer 失败: %v", err)
+	}
+	if _, err := client.CallToolRaw(context.Background(), &mcp.CallToolParams{Name: "notify"}); err != nil {
+		t.Fatalf("切换后 CallToolRaw 失败: %v", err)
+	}
+	receive(logs, "second")
+}
+
+// TestNotificationRelayAfterHeartbeatReconnect 验证心跳失败触发后台重连后，
+// 日志级别在新会话上重新生效，日志通知继续送达
+func TestNotificationRelayAfterHeartbeatReconnect(t *testing.T) {
+	first := newNotifyingMCPServer(t, "first")
+	second := newNotifyingMCPServer(t, "second")
+	cfg := &configs.ClientConfig{
+		Servers: []configs.ServerConfig{
+			{Name: "first", URL: first.URL + "/mcp"},
+			{Name: "second", URL: second.URL + "/mcp"},
+		},
+	}
+
+	connected := make(chan string, 4)
+	logs := make(chan any, 4)
+	client, err := NewClient(cfg, WithLogger(&mockLogger{}), WithHeartbeat(20*time.Millisecond, 2),
+		WithLoggingHandler(func(ctx context.Context, p *mcp.LoggingMessageParams) { logs <- p.Data }),
+		WithOnStateChange(func(sc StateChange) {
+			if sc.State == StateConnected {
+				connected <- sc.Server.Name
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("创建客户端失败: %v", err)
+	}
+	defer client.Close()
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect 失败: %v", err)
+	}
+	<-connected
+	if err := client.SetLoggingLevel(context.Background(), "info"); err != nil {
+		t.Fatalf("SetLoggingLevel 失败: %v", err)
+	}
+
+	first.CloseClientConnections()
+	first.Close()
+	select {
+	case name := <-connected:
+		if name != "second" {
+			t.Fatalf("后台重连到 %s, 预期 second", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("心跳失败后未在后台重连")
+	}
+
+	if _, err := client.CallToolRaw(context.Background(), &mcp.CallToolParams{Name: "notify"}); err != nil {
+		t.Fatalf("重连后 CallToolRaw 失败: %v", err)
+	}
+	select {
+	case got := <-logs:
+		if got != "second" {
+			t.Errorf("日志通知 = %v, 预期 second", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("后台重连后没有收到日志通知")
+	}
+}
@@ -0,0 +1,187 @@
+package mcpclient
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Strategy 命令在集群上的分发策略
+type Strategy string
+
+const (
+	StrategyParallel Strategy = "parallel" // 所有目标节点并发执行（服务端默认）
+	StrategySerial   Strategy = "serial"   // 按顺序逐个节点执行，某个节点失败后跳过剩余节点
+)
+
+const (
+	// serverDefaultTimeout 未指定执行超时时服务端使用的执行超时
+	serverDefaultTimeout = 5 * time.Second
+	// callTimeoutMargin Tool 调用在执行超时之外等待结果的时间，用于节点间的分发、结果聚合和网络传输
+	callTimeoutMargin = 30 * time.Second
+)
+
+// ExecOption 定义单次命令执行的可选参数
+type ExecOption func(*execOptions)
+
+// execOptions 单次命令执行的参数，零值字段不会发送给服务端
+type execOptions struct {
+	timeout   time.Duration
+	soft      time.Duration
+	targets   []string
+	workDir   string
+	env       map[string]string
+	stdin     *string
+	strategy  Strategy
+	normalize *Normalize
+	safe      *bool // 覆盖 RetryPolicy.SafeToRetry 的判断结果
+}
+
+// Normalize 各节点对命令输出的规范化处理，对应 execute_command 的 normalize 参数
+// 不是有效 UTF-8 的输出总是以 base64 编码返回，见 AggregatedGroup.Encoding
+type Normalize struct {
+	StripANSI  bool // 去掉 ANSI 转义序列和控制字符（换行、制表符、回车除外）
+	CollapseCR bool // 每行只保留最后一次回车之后的内容，如进度条的最终状态
+}
+
+// WithExecTimeout 设置每个节点的执行超时，按秒向上取整，服务端默认 5 秒
+// 客户端最多等待执行超时再加 30 秒，串行策略只受 ctx 限制
+func WithExecTimeout(timeout time.Duration) ExecOption {
+	return func(o *execOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithSoftTimeout 设置软超时，按秒向上取整：到达后返回已有结果，仍在执行的节点在后台作业中继续执行，
+// 作业 ID 见 Result.JobID；需要小于执行超时，不支持串行策略
+// timeout 为负数时不使用服务端默认的软超时（jobs.soft_timeout），等待所有节点结束
+func WithSoftTimeout(timeout time.Duration) ExecOption {
+	return func(o *execOptions) {
+		o.soft = timeout
+	}
+}
+
+// WithTargets 设置执行命令的目标节点（节点名称或 URL，名称支持 web-* 形式的通配符），默认所有节点
+func WithTargets(targets ...string) ExecOption {
+	return func(o *execOptions) {
+		o.targets = append(o.targets, targets...)
+	}
+}
+
+// WithWorkDir 设置命令的工作目录
+func WithWorkDir(dir string) ExecOption {
+	return func(o *execOptions) {
+		o.workDir = dir
+	}
+}
+
+// WithEnv 设置命令的环境变量，多次调用会合并
+func WithEnv(env map[string]string) ExecOption {
+	return func(o *execOptions) {
+		if o.env == nil {
+			o.env = make(map[string]string, len(env))
+		}
+		for k, v := range env {
+			o.env[k] = v
+		}
+	}
+}
+
+// WithStdin 设置写入命令标准输入的内容
+func WithStdin(stdin string) ExecOption {
+	return func(o *execOptions) {
+		o.stdin = &stdin
+	}
+}
+
+// WithStrategy 设置命令的分发策略
+func WithStrategy(strategy Strategy) ExecOption {
+	return func(o *execOptions) {
+		o.strategy = strategy
+	}
+}
+
+// WithNormalize 设置各节点对命令输出的规范化处理，覆盖服务端的 executor.normalize 配置
+func WithNormalize(n Normalize) ExecOption {
+	return func(o *execOptions) {
+		o.normalize = &n
+	}
+}
+
+// WithSafeToRetry 标记本次命令是否可安全重试（幂等），优先于 RetryPolicy.SafeToRetry
+func WithSafeToRetry(safe bool) ExecOption {
+	return func(o *execOptions) {
+		o.safe = &safe
+	}
+}
+
+// newExecOptions 应用所有 ExecOption
+func newExecOptions(opts []ExecOption) *execOptions {
+	o := &execOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// arguments 将命令和执行参数序列化为 execute_command tool 的参数
+func (o *execOptions) arguments(command string) map[string]any {
+	args := map[string]any{
+		"command": command,
+	}
+	if o.timeout > 0 {
+		args["timeout"] = int((o.timeout + time.Second - 1) / time.Second)
+	}
+	switch {
+	case o.soft > 0:
+		args["soft_timeout"] = int((o.soft + time.Second - 1) / time.Second)
+	case o.soft < 0:
+		args["soft_timeout"] = -1
+	}
+	if len(o.targets) > 0 {
+		args["targets"] = o.targets
+	}
+	if o.workDir != "" {
+		args["cwd"] = o.workDir
+	}
+	if len(o.env) > 0 {
+		args["env"] = o.env
+	}
+	if o.stdin != nil {
+		args["stdin"] = *o.stdin
+	}
+	if o.strategy != "" {
+		args["strategy"] = string(o.strategy)
+	}
+	if o.normalize != nil {
+		args["normalize"] = map[string]bool{
+			"strip_ansi":  o.normalize.StripANSI,
+			"collapse_cr": o.normalize.CollapseCR,
+		}
+	}
+	return args
+}
+
+// callTimeout 返回一次 Tool 调用等待结果的时间上限：参数中的 timeout（未指定时为服务端默认的 5 秒）加上 callTimeoutMargin
+// 串行策略逐个节点执行，总耗时取决于节点数量，返回 0 表示只受调用方 ctx 限制
+// args 可以是 map、可 JSON 序列化的结构体或 json.RawMessage（mcp-proxy 转发的参数）
+func callTimeout(args any) time.Duration {
+	var params struct {
+		Timeout  float64 `json:"timeout"`
+		Strategy string  `json:"strategy"`
+	}
+	data, ok := args.(json.RawMessage)
+	if !ok && args != nil {
+		data, _ = json.Marshal(args)
+	}
+	// 解析失败时按未指定处理，参数错误由服务端返回
+	_ = json.Unmarshal(data, &params)
+
+	if params.Strategy == string(StrategySerial) {
+		return 0
+	}
+	timeout := serverDefaultTimeout
+	if params.Timeout > 0 {
+		timeout = time.Duration(params.Timeout * float64(time.Second))
+	}
+	return timeout + callTimeoutMargin
+}
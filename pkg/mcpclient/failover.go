@@ -0,0 +1,142 @@
+package mcpclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/AceDarkknight/shell-executor-mcp/pkg/configs"
+)
+
+// DefaultServerCooldown 服务器连接失败后的默认冷却时间
+const DefaultServerCooldown = 30 * time.Second
+
+// ConnectionState 表示客户端的连接状态
+type ConnectionState string
+
+const (
+	StateDisconnected ConnectionState = "disconnected" // 未连接或连接已断开
+	StateConnecting   ConnectionState = "connecting"   // 正在尝试连接某个服务器
+	StateConnected    ConnectionState = "connected"    // 已连接到某个服务器
+	StateReconnecting ConnectionState = "reconnecting" // 连接失效（心跳连续失败或调用时连接中断），即将重连或切换服务器
+)
+
+// 心跳默认配置
+const (
+	DefaultHeartbeatInterval  = 30 * time.Second // 默认心跳间隔
+	DefaultHeartbeatThreshold = 3                // 默认连续失败多少次心跳后重连
+)
+
+// StateChange 描述一次连接状态变化
+type StateChange struct {
+	State  ConnectionState      // 新状态
+	Server configs.ServerConfig // 相关的服务器（全部失败或主动关闭时为最后一个活跃服务器，可能为空）
+	Err    error                // 导致状态变化的错误（如连接失败），可能为 nil
+}
+
+// serverEntry 记录单个服务器的故障转移状态
+type serverEntry struct {
+	config        configs.ServerConfig
+	failures      int       // 连续失败次数
+	cooldownUntil time.Time // 冷却结束时间，冷却期内优先尝试其他服务器
+	lastErr       error     // 最近一次失败的错误
+}
+
+// buildServerList 根据配置和 WithServerURL 构建故障转移列表
+// WithServerURL 覆盖配置中的服务器列表
+func buildServerList(cfg *configs.ClientConfig, overrideURL string) []*serverEntry {
+	if overrideURL != "" {
+		return []*serverEntry{{config: configs.ServerConfig{Name: overrideURL, URL: overrideURL}}}
+	}
+	entries := make([]*serverEntry, 0, len(cfg.Servers))
+	for _, s := range cfg.Servers {
+		entries = append(entries, &serverEntry{config: s})
+	}
+	return entries
+}
+
+// candidatesLocked 返回本次连接要依次尝试的服务器
+// 不在冷却期的服务器按配置顺序排在前面，冷却期内的服务器按冷却结束时间排在后面，
+// 保证所有服务器都在冷却期时仍会尝试连接
+// 注意：调用此方法前必须已持有 c.mu 锁
+func (c *Client) candidatesLocked(now time.Time) []*serverEntry {
+	var ready, cooling []*serverEntry
+	for _, s := range c.servers {
+		if now.Before(s.cooldownUntil) {
+			cooling = append(cooling, s)
+		} else {
+			ready = append(ready, s)
+		}
+	}
+	sort.SliceStable(cooling, func(i, j int) bool {
+		return cooling[i].cooldownUntil.Before(cooling[j].cooldownUntil)
+	})
+	return append(ready, cooling...)
+}
+
+// markFailedLocked 标记服务器连接失败并进入冷却期
+// 注意：调用此方法前必须已持有 c.mu 锁
+func (c *Client) markFailedLocked(s *serverEntry, err error) {
+	s.failures++
+	s.lastErr = err
+	s.cooldownUntil = time.Now().Add(c.cooldown)
+	if c.active == s {
+		c.active = nil
+	}
+}
+
+// markHealthyLocked 标记服务器连接成功并设置为当前活跃服务器
+// 注意：调用此方法前必须已持有 c.mu 锁
+func (c *Client) markHealthyLocked(s *serverEntry) {
+	s.failures = 0
+	s.lastErr = nil
+	s.cooldownUntil = time.Time{}
+	c.active = s
+}
+
+// CurrentServer 返回当前连接的服务器，未连接时第二个返回值为 false
+func (c *Client) CurrentServer() (configs.ServerConfig, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active == nil || c.session == nil {
+		return configs.ServerConfig{}, false
+	}
+	return c.active.config, true
+}
+
+// Servers 返回配置的服务器列表（按配置顺序）
+func (c *Client) Servers() []configs.ServerConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	servers := make([]configs.ServerConfig, 0, len(c.servers))
+	for _, s := range c.servers {
+		servers = append(servers, s.config)
+	}
+	return servers
+}
+
+// SwitchServer 切换到指定名称的服务器，不受冷却期限制
+// 连接成功后该服务器成为当前服务器并关闭原有会话；连接失败时保留原有连接并返回错误
+func (c *Client) SwitchServer(ctx context.Context, name string) error {
+	c.mu.Lock()
+	var target *serverEntry
+	for _, s := range c.servers {
+		if s.config.Name == name {
+			target = s
+			break
+		}
+	}
+	c.mu.Unlock()
+	if target == nil {
+		return fmt.Errorf("未找到服务器: %s", name)
+	}
+	return c.connect(ctx, target)
+}
+
+// notifyState 在不持有锁的情况下调用状态变化回调
+func (c *Client) notifyState(change StateChange) {
+	if c.onStateChange != nil {
+		c.onStateChange(change)
+	}
+}
@@ -0,0 +1,56 @@
+package mcpclient
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// clientOptions 根据设置的通知回调创建 MCP Client 选项，没有回调时返回 nil
+func (c *Client) clientOptions() *mcp.ClientOptions {
+	if c.onProgress == nil && c.onLog == nil {
+		return nil
+	}
+
+	opts := &mcp.ClientOptions{}
+	if c.onProgress != nil {
+		opts.ProgressNotificationHandler = func(ctx context.Context, req *mcp.ProgressNotificationClientRequest) {
+			c.onProgress(ctx, req.Params)
+		}
+	}
+	if c.onLog != nil {
+		opts.LoggingMessageHandler = func(ctx context.Context, req *mcp.LoggingMessageRequest) {
+			c.onLog(ctx, req.Params)
+		}
+	}
+	return opts
+}
+
+// SetLoggingLevel 设置服务端发送日志通知的最低级别
+// 级别会被记住，重连或切换服务器后自动在新的会话上重新设置
+func (c *Client) SetLoggingLevel(ctx context.Context, level mcp.LoggingLevel) error {
+	c.mu.Lock()
+	c.logLevel = level
+	c.mu.Unlock()
+
+	return c.withSession(ctx, "设置日志级别", true, callTimeoutMargin, func(ctx context.Context, session *mcp.ClientSession) error {
+		return session.SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: level})
+	})
+}
+
+// restoreLoggingLevel 在新建立的会话上重新设置日志级别，失败时只记录日志
+// 使用独立的上下文：后台重连时调用方的上下文属于旧的心跳协程，连接成功后即被取消
+func (c *Client) restoreLoggingLevel(session *mcp.ClientSession) {
+	c.mu.Lock()
+	level := c.logLevel
+	c.mu.Unlock()
+	if level == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeoutMargin)
+	defer cancel()
+	if err := session.SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: level}); err != nil {
+		c.logger.Warnf("重新设置日志级别 %s 失败: %v", level, err)
+	}
+}
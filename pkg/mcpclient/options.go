@@ -1,9 +1,12 @@
 package mcpclient
 
 import (
+	"context"
 	"crypto/tls"
 	"net/http"
 	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 // Option 定义客户端的可选配置参数
@@ -16,7 +19,8 @@ func WithLogger(l Logger) Option {
 	}
 }
 
-// WithTimeout 设置连接和请求超时时间
+// WithTimeout 设置连接服务器的超时时间，默认 30 秒
+// Tool 调用等待结果的时间由执行超时决定，不受此设置限制，见 WithExecTimeout
 func WithTimeout(timeout time.Duration) Option {
 	return func(c *Client) {
 		c.timeout = timeout
@@ -47,6 +51,53 @@ func WithHeader(key, value string) Option {
 	}
 }
 
+// WithServerCooldown 设置服务器连接失败后的冷却时间，冷却期内优先尝试其他服务器
+func WithServerCooldown(d time.Duration) Option {
+	return func(c *Client) {
+		c.cooldown = d
+	}
+}
+
+// WithOnStateChange 设置连接状态变化回调
+// 回调在状态变化的协程中同步调用，不应长时间阻塞
+func WithOnStateChange(fn func(StateChange)) Option {
+	return func(c *Client) {
+		c.onStateChange = fn
+	}
+}
+
+// WithProgressHandler 设置服务端进度通知（notifications/progress）的回调
+func WithProgressHandler(fn func(context.Context, *mcp.ProgressNotificationParams)) Option {
+	return func(c *Client) {
+		c.onProgress = fn
+	}
+}
+
+// WithLoggingHandler 设置服务端日志通知（notifications/message）的回调
+// 服务端只在客户端调用 SetLoggingLevel 之后发送日志通知
+func WithLoggingHandler(fn func(context.Context, *mcp.LoggingMessageParams)) Option {
+	return func(c *Client) {
+		c.onLog = fn
+	}
+}
+
+// WithHeartbeat 设置心跳间隔和失败阈值，默认每 30 秒一次、连续失败 3 次后重连
+// 连续 threshold 次心跳失败后，客户端在后台重连或切换到下一个服务器；interval 为 0 时关闭心跳
+func WithHeartbeat(interval time.Duration, threshold int) Option {
+	return func(c *Client) {
+		c.heartbeatInterval = interval
+		c.heartbeatThreshold = threshold
+	}
+}
+
+// WithRetryPolicy 设置命令执行失败时的重试策略，默认为 DefaultRetryPolicy()
+// 使用 RetryPolicy{MaxAttempts: 1} 可关闭重试
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
 // WithServerURL 设置服务器地址（覆盖配置中的服务器列表）
 func WithServerURL(url string) Option {
 	return func(c *Client) {
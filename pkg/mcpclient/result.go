@@ -1,16 +1,36 @@
 package mcpclient
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+// 节点执行状态
+const (
+	StatusSuccess = "success" // 命令执行成功（退出码为 0）
+	StatusFailed  = "failed"  // 命令执行失败或节点不可达
+	StatusTimeout = "timeout" // 命令执行超时
+	StatusSkipped = "skipped" // 串行执行时因前面的节点失败而未执行
+	StatusRunning = "running" // 软超时到达时仍在执行，后续结果通过 JobID 查询
+)
+
+// securityViolationPrefix 服务端安全检查拒绝命令时错误结果的文本前缀
+const securityViolationPrefix = "security violation"
+
 // Result 表示命令执行的结果
+// Summary 和 Groups 从服务端的结构化输出（StructuredContent）解码，Content 为原始内容块
 type Result struct {
 	IsError bool                // 是否为错误结果
+	Summary string              // 执行摘要
+	Groups  []AggregatedGroup   // 按输出分组的执行结果
+	JobID   string              // 软超时到达时仍有节点在执行的后台作业 ID，通过 get_job 查询后续结果
+	Pending []string            // 软超时到达时仍在执行的节点
 	Content []Content           // 内容列表
 	Raw     *mcp.CallToolResult // 原始 MCP 结果
 }
@@ -30,10 +50,63 @@ func (tc *TextContent) Type() string {
 	return "text"
 }
 
+// ImageContent 表示图片内容
+type ImageContent struct {
+	Data     []byte // 图片的原始数据
+	MIMEType string
+}
+
+// Type 返回内容类型
+func (ic *ImageContent) Type() string {
+	return "image"
+}
+
+// AudioContent 表示音频内容
+type AudioContent struct {
+	Data     []byte // 音频的原始数据
+	MIMEType string
+}
+
+// Type 返回内容类型
+func (ac *AudioContent) Type() string {
+	return "audio"
+}
+
+// ResourceContent 表示内嵌的资源内容，文本资源使用 Text，二进制资源使用 Blob
+type ResourceContent struct {
+	URI      string
+	MIMEType string
+	Text     string
+	Blob     []byte
+}
+
+// Type 返回内容类型
+func (rc *ResourceContent) Type() string {
+	return "resource"
+}
+
+// ResourceLinkContent 表示指向资源的链接，内容需要通过 URI 另行读取
+type ResourceLinkContent struct {
+	URI         string
+	Name        string
+	Title       string
+	Description string
+	MIMEType    string
+	Size        *int64
+}
+
+// Type 返回内容类型
+func (rl *ResourceLinkContent) Type() string {
+	return "resource_link"
+}
+
 // AggregatedResult 表示聚合结果（JSON 格式）
+// Deprecated: 使用 Result.Summary 和 Result.Groups
 type AggregatedResult struct {
-	Summary string            `json:"summary"` // 摘要
-	Groups  []AggregatedGroup `json:"groups"`  // 组列表
+	Summary string            `json:"summary"`           // 摘要
+	Groups  []AggregatedGroup `json:"groups"`            // 组列表
+	JobID   string            `json:"job_id,omitempty"`  // 后台作业 ID
+	Pending []string          `json:"pending,omitempty"` // 仍在执行的节点
 }
 
 // Type 返回内容类型
@@ -41,17 +114,65 @@ func (ar *AggregatedResult) Type() string {
 	return "aggregated"
 }
 
-// AggregatedGroup 表示聚合结果中的一个组
+// AggregatedGroup 表示聚合结果中的一个组：输出、错误、状态和退出码完全相同的节点
 type AggregatedGroup struct {
-	Count  int      `json:"count"`  // 节点数量
-	Status string   `json:"status"` // 状态
-	Output string   `json:"output"` // 输出内容
-	Error  string   `json:"error"`  // 错误信息
-	Nodes  []string `json:"nodes"`  // 节点列表
+	Count     int    `json:"count"`               // 节点数量
+	Status    string `json:"status"`              // 状态
+	ExitCode  int    `json:"exit_code"`           // 退出码，没有退出码（请求失败、被跳过）时为 -1
+	Output    string `json:"output"`              // 输出内容
+	Error     string `json:"error"`               // 错误信息
+	Encoding  string `json:"encoding,omitempty"`  // Output 的编码：为空表示文本，base64 表示输出不是有效的 UTF-8，Output 为其 base64 编码
+	KilledBy  string `json:"killed_by,omitempty"` // 因超时或资源限制被终止时的原因：timeout、cpu、file_size、memory
+	Sandboxed bool   `json:"sandboxed,omitempty"` // 是否在沙箱中运行
+	// Truncated 输出超过节点的输出上限或服务端的响应大小上限，只保留了开头和结尾
+	Truncated   bool              `json:"truncated,omitempty"`
+	OutputBytes int64             `json:"output_bytes,omitempty"` // 截断前标准输出的字节数
+	ErrorBytes  int64             `json:"error_bytes,omitempty"`  // 截断前标准错误的字节数
+	Artifacts   map[string]string `json:"artifacts,omitempty"`    // 每个节点上保存完整输出的制品 ID，可通过 get_artifact 读取
+	Nodes       []string          `json:"nodes"`                  // 节点列表
+	Durations   map[string]int64  `json:"durations_ms,omitempty"` // 每个节点的执行耗时（毫秒）
+}
+
+// NodeResult 表示单个节点的执行结果
+type NodeResult struct {
+	Node      string        // 节点名称或地址
+	Status    string        // 执行状态
+	ExitCode  int           // 退出码，没有退出码时为 -1
+	Output    string        // 输出内容
+	Error     string        // 错误信息
+	Encoding  string        // Output 的编码，见 AggregatedGroup.Encoding
+	KilledBy  string        // 因超时或资源限制被终止时的原因
+	Sandboxed bool          // 是否在沙箱中运行
+	Truncated bool          // 输出是否被截断
+	Artifact  string        // 节点上保存完整输出的制品 ID，没有时为空
+	Duration  time.Duration // 执行耗时，服务端未返回时为 0
+}
+
+// ParseResult 解析 execute_command 返回的结果
+// 结构化输出解码到 Summary 和 Groups，结构化输出格式不匹配时返回错误
+func ParseResult(result *mcp.CallToolResult) (*Result, error) {
+	r := parseContent(result)
+	if result.StructuredContent == nil || result.IsError {
+		return r, nil
+	}
+
+	data, err := json.Marshal(result.StructuredContent)
+	if err != nil {
+		return r, fmt.Errorf("序列化结构化输出失败: %w", err)
+	}
+	var out AggregatedResult
+	if err := json.Unmarshal(data, &out); err != nil {
+		return r, fmt.Errorf("解码结构化输出失败: %w", err)
+	}
+	r.Summary = out.Summary
+	r.Groups = out.Groups
+	r.JobID = out.JobID
+	r.Pending = out.Pending
+	return r, nil
 }
 
-// ParseResult 解析 MCP Tool 返回的结果
-func ParseResult(result *mcp.CallToolResult) *Result {
+// parseContent 将 MCP 内容块转换为对应的 Content 类型
+func parseContent(result *mcp.CallToolResult) *Result {
 	r := &Result{
 		IsError: result.IsError,
 		Raw:     result,
@@ -60,19 +181,29 @@ func ParseResult(result *mcp.CallToolResult) *Result {
 	for _, content := range result.Content {
 		switch v := content.(type) {
 		case *mcp.TextContent:
-			// 尝试解析为聚合结果
-			var aggregatedResult AggregatedResult
-			if err := json.Unmarshal([]byte(v.Text), &aggregatedResult); err == nil {
-				// 成功解析为聚合结果
-				r.Content = append(r.Content, &aggregatedResult)
-			} else {
-				// 作为纯文本内容
-				r.Content = append(r.Content, &TextContent{Text: v.Text})
+			r.Content = append(r.Content, &TextContent{Text: v.Text})
+		case *mcp.ImageContent:
+			r.Content = append(r.Content, &ImageContent{Data: v.Data, MIMEType: v.MIMEType})
+		case *mcp.AudioContent:
+			r.Content = append(r.Content, &AudioContent{Data: v.Data, MIMEType: v.MIMEType})
+		case *mcp.EmbeddedResource:
+			if v.Resource != nil {
+				r.Content = append(r.Content, &ResourceContent{
+					URI:      v.Resource.URI,
+					MIMEType: v.Resource.MIMEType,
+					Text:     v.Resource.Text,
+					Blob:     v.Resource.Blob,
+				})
 			}
-		default:
-			// 其他类型的内容，转换为文本
-			// 使用 fmt.Sprintf 来转换
-			r.Content = append(r.Content, &TextContent{Text: fmt.Sprintf("%v", v)})
+		case *mcp.ResourceLink:
+			r.Content = append(r.Content, &ResourceLinkContent{
+				URI:         v.URI,
+				Name:        v.Name,
+				Title:       v.Title,
+				Description: v.Description,
+				MIMEType:    v.MIMEType,
+				Size:        v.Size,
+			})
 		}
 	}
 
@@ -90,18 +221,120 @@ func (r *Result) GetTextContents() []string {
 	return texts
 }
 
+// IsSecurityViolation 判断结果是否为服务端安全检查拒绝执行命令
+func (r *Result) IsSecurityViolation() bool {
+	if !r.IsError {
+		return false
+	}
+	for _, text := range r.GetTextContents() {
+		if strings.HasPrefix(text, securityViolationPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetAggregatedResults 获取所有聚合结果
+// Deprecated: 使用 Result.Summary 和 Result.Groups
 func (r *Result) GetAggregatedResults() []*AggregatedResult {
-	var results []*AggregatedResult
-	for _, content := range r.Content {
-		if ar, ok := content.(*AggregatedResult); ok {
-			results = append(results, ar)
+	if r.Groups == nil && r.Summary == "" {
+		return nil
+	}
+	return []*AggregatedResult{{Summary: r.Summary, Groups: r.Groups}}
+}
+
+// Majority 返回节点数最多的组，节点数相同时优先返回成功的组，没有结果时返回 nil
+func (r *Result) Majority() *AggregatedGroup {
+	var majority *AggregatedGroup
+	for i := range r.Groups {
+		g := &r.Groups[i]
+		if majority == nil || g.Count > majority.Count ||
+			(g.Count == majority.Count && g.Status == StatusSuccess && majority.Status != StatusSuccess) {
+			majority = g
+		}
+	}
+	return majority
+}
+
+// Outliers 返回除多数组以外的所有组，即结果与大多数节点不一致的节点
+func (r *Result) Outliers() []AggregatedGroup {
+	majority := r.Majority()
+	var outliers []AggregatedGroup
+	for i := range r.Groups {
+		if &r.Groups[i] != majority {
+			outliers = append(outliers, r.Groups[i])
 		}
 	}
-	return results
+	return outliers
+}
+
+// FailedNodes 返回执行状态不是 success 的节点（包括失败、超时和被跳过的节点，不含仍在执行的节点），按名称排序
+func (r *Result) FailedNodes() []string {
+	var nodes []string
+	for _, g := range r.Groups {
+		if g.Status != StatusSuccess && g.Status != StatusRunning {
+			nodes = append(nodes, g.Nodes...)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// ByNode 返回指定节点的执行结果
+func (r *Result) ByNode(name string) (NodeResult, bool) {
+	for _, g := range r.Groups {
+		for _, node := range g.Nodes {
+			if node == name {
+				return g.nodeResult(node), true
+			}
+		}
+	}
+	return NodeResult{}, false
+}
+
+// Nodes 将分组结果展开为每个节点的结果，按节点名称排序
+func (r *Result) Nodes() []NodeResult {
+	var nodes []NodeResult
+	for _, g := range r.Groups {
+		for _, node := range g.Nodes {
+			nodes = append(nodes, g.nodeResult(node))
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+	return nodes
+}
+
+// nodeResult 返回组内指定节点的结果
+func (g AggregatedGroup) nodeResult(node string) NodeResult {
+	return NodeResult{
+		Node:      node,
+		Status:    g.Status,
+		ExitCode:  g.ExitCode,
+		Output:    g.Output,
+		Error:     g.Error,
+		Encoding:  g.Encoding,
+		KilledBy:  g.KilledBy,
+		Sandboxed: g.Sandboxed,
+		Truncated: g.Truncated,
+		Artifact:  g.Artifacts[node],
+		Duration:  time.Duration(g.Durations[node]) * time.Millisecond,
+	}
+}
+
+// EncodingBase64 Encoding 的取值：输出不是有效的 UTF-8，Output 为原始字节的 base64 编码
+const EncodingBase64 = "base64"
+
+// OutputData 返回节点标准输出的原始字节，Encoding 为 base64 时解码
+// 节点截断的输出解码后包含截断标记；为满足服务端响应大小上限而截断的 base64 输出无法整体解码，完整输出可通过 get_artifact 读取
+func (n NodeResult) OutputData() ([]byte, error) {
+	if n.Encoding != EncodingBase64 {
+		return []byte(n.Output), nil
+	}
+	return base64.StdEncoding.DecodeString(n.Output)
 }
 
 // String 返回结果的字符串表示
+// 有结构化输出时展示分组结果，否则展示文本内容；非文本内容展示类型和元数据
 func (r *Result) String() string {
 	var sb strings.Builder
 
@@ -111,32 +344,53 @@ func (r *Result) String() string {
 		sb.WriteString("Success Result:\n")
 	}
 
+	structured := r.Summary != "" || len(r.Groups) > 0
+	if structured {
+		sb.WriteString(fmt.Sprintf("Summary: %s\n", r.Summary))
+		for j, group := range r.Groups {
+			sb.WriteString(fmt.Sprintf("  Group [%d]: count=%d, status=%s\n", j+1, group.Count, group.Status))
+			if group.Output != "" {
+				sb.WriteString(fmt.Sprintf("  Output:\n%s\n", group.Output))
+			}
+			if group.Error != "" {
+				sb.WriteString(fmt.Sprintf("  Error: %s\n", group.Error))
+			}
+			if len(group.Nodes) > 0 {
+				nodesStr := strings.Join(group.Nodes, ", ")
+				if len(nodesStr) > 100 {
+					nodesStr = nodesStr[:100] + "..."
+				}
+				sb.WriteString(fmt.Sprintf("  Nodes: %s\n", nodesStr))
+			}
+		}
+	}
+
 	for i, content := range r.Content {
-		sb.WriteString(fmt.Sprintf("Content [%d]:\n", i))
 		switch v := content.(type) {
 		case *TextContent:
+			// 结构化输出的文本内容是同一份数据的 JSON 序列化，不重复展示
+			if structured {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("Content [%d]:\n", i))
 			sb.WriteString(v.Text)
 			if !strings.HasSuffix(v.Text, "\n") {
 				sb.WriteString("\n")
 			}
-		case *AggregatedResult:
-			sb.WriteString(fmt.Sprintf("Summary: %s\n", v.Summary))
-			for j, group := range v.Groups {
-				sb.WriteString(fmt.Sprintf("  Group [%d]: count=%d, status=%s\n", j+1, group.Count, group.Status))
-				if group.Output != "" {
-					sb.WriteString(fmt.Sprintf("  Output:\n%s\n", group.Output))
-				}
-				if group.Error != "" {
-					sb.WriteString(fmt.Sprintf("  Error: %s\n", group.Error))
-				}
-				if len(group.Nodes) > 0 {
-					nodesStr := strings.Join(group.Nodes, ", ")
-					if len(nodesStr) > 100 {
-						nodesStr = nodesStr[:100] + "..."
-					}
-					sb.WriteString(fmt.Sprintf("  Nodes: %s\n", nodesStr))
+		case *ImageContent:
+			sb.WriteString(fmt.Sprintf("Content [%d]: image (%s, %d bytes)\n", i, v.MIMEType, len(v.Data)))
+		case *AudioContent:
+			sb.WriteString(fmt.Sprintf("Content [%d]: audio (%s, %d bytes)\n", i, v.MIMEType, len(v.Data)))
+		case *ResourceContent:
+			sb.WriteString(fmt.Sprintf("Content [%d]: resource %s\n", i, v.URI))
+			if v.Text != "" {
+				sb.WriteString(v.Text)
+				if !strings.HasSuffix(v.Text, "\n") {
+					sb.WriteString("\n")
 				}
 			}
+		case *ResourceLinkContent:
+			sb.WriteString(fmt.Sprintf("Content [%d]: resource link %s (%s)\n", i, v.URI, v.Name))
 		}
 	}
 
@@ -0,0 +1,98 @@
+package mcpclient
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TestParseResult 验证结构化输出解码为分组结果，以及各种内容类型的转换
+func TestParseResult(t *testing.T) {
+	raw := &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: `{"summary":"ignored"}`},
+			&mcp.ImageContent{Data: []byte{1, 2, 3}, MIMEType: "image/png"},
+			&mcp.AudioContent{Data: []byte{4}, MIMEType: "audio/wav"},
+			&mcp.EmbeddedResource{Resource: &mcp.ResourceContents{URI: "file:///tmp/out.log", Text: "log"}},
+			&mcp.ResourceLink{URI: "artifact://1", Name: "output"},
+		},
+		StructuredContent: map[string]any{
+			"summary": "Executed on 4 nodes, 3 groups found",
+			"groups": []any{
+				map[string]any{"count": 2, "status": "success", "output": "v1\n", "nodes": []any{"node-02", "node-01"}},
+				map[string]any{"count": 1, "status": "success", "output": "v2\n", "nodes": []any{"node-03"}, "durations_ms": map[string]any{"node-03": 1500}},
+				map[string]any{"count": 1, "status": "failed", "exit_code": -1, "error": "connection refused", "nodes": []any{"node-04"}},
+			},
+		},
+	}
+
+	r, err := ParseResult(raw)
+	if err != nil {
+		t.Fatalf("ParseResult 失败: %v", err)
+	}
+	if r.Summary != "Executed on 4 nodes, 3 groups found" || len(r.Groups) != 3 {
+		t.Fatalf("结构化输出解码错误: %+v", r)
+	}
+
+	var types []string
+	for _, c := range r.Content {
+		types = append(types, c.Type())
+	}
+	if want := []string{"text", "image", "audio", "resource", "resource_link"}; !slices.Equal(types, want) {
+		t.Errorf("内容类型 = %v, 预期 %v", types, want)
+	}
+	if img := r.Content[1].(*ImageContent); len(img.Data) != 3 || img.MIMEType != "image/png" {
+		t.Errorf("图片内容错误: %+v", img)
+	}
+
+	if m := r.Majority(); m == nil || m.Output != "v1\n" {
+		t.Errorf("Majority() = %+v, 预期输出 v1 的组", m)
+	}
+	if outliers := r.Outliers(); len(outliers) != 2 {
+		t.Errorf("Outliers() 数量 = %d, 预期 2", len(outliers))
+	}
+	if failed := r.FailedNodes(); !slices.Equal(failed, []string{"node-04"}) {
+		t.Errorf("FailedNodes() = %v", failed)
+	}
+	if n, ok := r.ByNode("node-03"); !ok || n.Output != "v2\n" || n.Status != StatusSuccess || n.Duration != 1500*time.Millisecond {
+		t.Errorf("ByNode(node-03) = %+v, %v", n, ok)
+	}
+	if _, ok := r.ByNode("node-99"); ok {
+		t.Error("ByNode 对不存在的节点应返回 false")
+	}
+
+	var names []string
+	for _, n := range r.Nodes() {
+		names = append(names, n.Node)
+	}
+	if want := []string{"node-01", "node-02", "node-03", "node-04"}; !slices.Equal(names, want) {
+		t.Errorf("Nodes() = %v, 预期 %v", names, want)
+	}
+}
+
+// TestParseResultInvalidStructured 验证结构化输出格式不匹配时返回错误而不是静默忽略
+func TestParseResultInvalidStructured(t *testing.T) {
+	_, err := ParseResult(&mcp.CallToolResult{StructuredContent: map[string]any{"groups": "not-a-list"}})
+	if err == nil {
+		t.Error("结构化输出格式不匹配时应返回错误")
+	}
+}
+
+// TestIsSecurityViolation 验证只有安全检查拒绝的错误结果才被识别为安全违规
+func TestIsSecurityViolation(t *testing.T) {
+	tests := []struct {
+		raw  *mcp.CallToolResult
+		want bool
+	}{
+		{&mcp.CallToolResult{IsError: true, Content: []mcp.Content{&mcp.TextContent{Text: "security violation: command 'rm' is blacklisted"}}}, true},
+		{&mcp.CallToolResult{IsError: true, Content: []mcp.Content{&mcp.TextContent{Text: `target "x" matches no node`}}}, false},
+		{&mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "security violation"}}}, false},
+	}
+	for _, tt := range tests {
+		if got := parseContent(tt.raw).IsSecurityViolation(); got != tt.want {
+			t.Errorf("IsSecurityViolation(%v) = %v, 预期 %v", tt.raw.Content[0], got, tt.want)
+		}
+	}
+}
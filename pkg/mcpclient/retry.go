@@ -0,0 +1,291 @@
+package mcpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrNotConnected 表示客户端当前没有可用的会话
+var ErrNotConnected = errors.New("客户端未连接")
+
+// JSON-RPC 错误码，参见 go-sdk internal/jsonrpc2/wire.go
+const (
+	codeClientClosing       = -32003 // 客户端连接正在关闭
+	codeServerClosing       = -32004 // 服务端连接正在关闭
+	codeRejectedByTransport = -32005 // 请求在传输层被拒绝，未送达服务端
+)
+
+// ErrorClass 表示错误的重试分类
+type ErrorClass int
+
+const (
+	// ErrorClassPermanent 服务端已经给出明确响应（如 JSON-RPC 错误）或调用方主动取消，不应重试
+	ErrorClassPermanent ErrorClass = iota
+	// ErrorClassNotSent 请求未送达服务端（未连接、拨号失败、传输层拒绝），重试不会导致命令重复执行
+	ErrorClassNotSent
+	// ErrorClassConnection 请求发出后连接中断，命令可能已经在服务端执行
+	ErrorClassConnection
+)
+
+// String 返回错误分类的名称
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassNotSent:
+		return "not_sent"
+	case ErrorClassConnection:
+		return "connection"
+	default:
+		return "permanent"
+	}
+}
+
+// ClassifyError 根据错误类型判断错误的重试分类
+// 只使用 errors.Is / errors.As 判断，不依赖错误信息文本
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassPermanent
+	}
+
+	// 带有送达信息的错误以实际的送达情况为准
+	var ce *callError
+	if errors.As(err, &ce) {
+		switch {
+		case ClassifyError(ce.err) == ErrorClassPermanent:
+			return ErrorClassPermanent
+		case ce.delivered:
+			return ErrorClassConnection
+		default:
+			return ErrorClassNotSent
+		}
+	}
+
+	// 调用方取消或超时
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassPermanent
+	}
+
+	if errors.Is(err, ErrNotConnected) {
+		return ErrorClassNotSent
+	}
+
+	// 连接已关闭时 SDK 会返回 ErrConnectionClosed，此时无法确定请求是否已送达
+	if errors.Is(err, mcp.ErrConnectionClosed) {
+		return ErrorClassConnection
+	}
+
+	var wireErr *jsonrpc.Error
+	if errors.As(err, &wireErr) {
+		switch wireErr.Code {
+		case codeRejectedByTransport:
+			return ErrorClassNotSent
+		case codeClientClosing, codeServerClosing:
+			return ErrorClassConnection
+		default:
+			return ErrorClassPermanent
+		}
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ErrorClassNotSent
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorClassNotSent
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, net.ErrClosed) {
+		return ErrorClassConnection
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorClassConnection
+	}
+
+	return ErrorClassPermanent
+}
+
+// IsConnectionError 判断错误是否由连接问题导致（需要重连或切换服务器）
+func IsConnectionError(err error) bool {
+	return ClassifyError(err) != ErrorClassPermanent
+}
+
+// DefaultRetryable 默认的可重试判断
+// 未送达服务端的请求总是可以重试；连接中断的请求仅在命令可安全重试时重试
+func DefaultRetryable(err error, safe bool) bool {
+	switch ClassifyError(err) {
+	case ErrorClassNotSent:
+		return true
+	case ErrorClassConnection:
+		return safe
+	default:
+		return false
+	}
+}
+
+// RetryPolicy 命令执行失败时的重试策略
+// 调用方的 ctx 被取消后不会再重试
+type RetryPolicy struct {
+	MaxAttempts    int           // 最大尝试次数（包含第一次），小于等于 1 表示不重试
+	InitialBackoff time.Duration // 第一次重试前的等待时间
+	MaxBackoff     time.Duration // 单次等待时间上限，0 表示不限制
+	Multiplier     float64       // 每次重试等待时间的增长倍数，小于 1 时按 1 处理
+	Jitter         float64       // 等待时间随机浮动比例（0~1），避免多个客户端同时重试
+
+	// Retryable 判断错误是否可以重试，safe 表示命令是否可安全重试，为 nil 时使用 DefaultRetryable
+	Retryable func(err error, safe bool) bool
+	// SafeToRetry 判断命令是否可安全重试（幂等），为 nil 时所有命令都视为不可安全重试
+	SafeToRetry func(command string) bool
+}
+
+// DefaultRetryPolicy 返回默认的重试策略：最多尝试 3 次，指数退避 500ms、1s，上下浮动 20%
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Backoff 返回第 retry 次重试（从 1 开始）前的等待时间
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if retry < 1 || p.InitialBackoff <= 0 {
+		return 0
+	}
+	multiplier := math.Max(p.Multiplier, 1)
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 {
+		backoff = math.Min(backoff, float64(p.MaxBackoff))
+	}
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		backoff *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(backoff)
+}
+
+// wait 等待第 retry 次重试的退避时间，ctx 取消时立即返回
+func (p RetryPolicy) wait(ctx context.Context, retry int) error {
+	d := p.Backoff(retry)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryable 判断错误是否可以重试
+func (p RetryPolicy) retryable(err error, safe bool) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err, safe)
+	}
+	return DefaultRetryable(err, safe)
+}
+
+// safeToRetry 判断命令是否可安全重试
+func (p RetryPolicy) safeToRetry(command string) bool {
+	return p.SafeToRetry != nil && p.SafeToRetry(command)
+}
+
+// callError 为调用错误附加请求是否可能已送达服务端的信息
+// SDK 会把所有 HTTP 请求错误都包装为"传输层拒绝"，无法区分请求是否已送达，
+// 因此由 deliveryTracker 在 HTTP 层记录实际的送达情况
+type callError struct {
+	err       error
+	delivered bool
+}
+
+func (e *callError) Error() string { return e.err.Error() }
+func (e *callError) Unwrap() error { return e.err }
+
+// deliveryTrackerKey 用于在 context 中保存 deliveryTracker
+type deliveryTrackerKey struct{}
+
+// deliveryTracker 记录一次调用的 HTTP 请求是否可能已送达服务端
+type deliveryTracker struct {
+	mu        sync.Mutex
+	delivered bool
+}
+
+// withDeliveryTracker 返回带有 deliveryTracker 的 context
+func withDeliveryTracker(ctx context.Context) (context.Context, *deliveryTracker) {
+	t := &deliveryTracker{}
+	return context.WithValue(ctx, deliveryTrackerKey{}, t), t
+}
+
+// record 根据 HTTP 请求结果记录送达情况
+// 建立连接阶段的错误和非 2xx 响应说明服务端没有处理请求，其他错误（如读取响应时连接断开）视为可能已送达
+func (t *deliveryTracker) record(resp *http.Response, err error) {
+	var delivered bool
+	if err != nil {
+		delivered = !isDialError(err)
+	} else {
+		delivered = resp.StatusCode >= 200 && resp.StatusCode < 300
+	}
+	if delivered {
+		t.mu.Lock()
+		t.delivered = true
+		t.mu.Unlock()
+	}
+}
+
+// wrap 为错误附加送达信息
+func (t *deliveryTracker) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &callError{err: err, delivered: t.delivered}
+}
+
+// isDialError 判断错误是否发生在请求发出之前（拨号、DNS 解析、TLS 证书校验）
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// trackingRoundTripper 将 HTTP 请求结果记录到请求 context 中的 deliveryTracker
+type trackingRoundTripper struct {
+	base http.RoundTripper
+}
+
+func (t *trackingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if tracker, ok := req.Context().Value(deliveryTrackerKey{}).(*deliveryTracker); ok {
+		tracker.record(resp, err)
+	}
+	return resp, err
+}
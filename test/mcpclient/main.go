@@ -120,9 +120,8 @@ func main() {
 			texts := result.GetTextContents()
 			fmt.Printf("文本内容数量: %d\n", len(texts))
 
-			// 测试获取聚合结果
-			aggregatedResults := result.GetAggregatedResults()
-			fmt.Printf("聚合结果数量: %d\n", len(aggregatedResults))
+			// 测试获取分组结果
+			fmt.Printf("分组数量: %d, 失败节点: %v\n", len(result.Groups), result.FailedNodes())
 		}
 	}
 